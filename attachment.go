@@ -0,0 +1,175 @@
+package sahar
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Relationship is how an attached file relates to the document, PDF/A-3 requires it
+type Relationship string
+
+const (
+	RelationUnspecified Relationship = "Unspecified"
+	RelationSource      Relationship = "Source"      // The document was made from the file
+	RelationData        Relationship = "Data"        // The file holds the data of the document, as in Factur-X
+	RelationAlternative Relationship = "Alternative" // The file is another form of the document, as in XRechnung
+	RelationSupplement  Relationship = "Supplement"  // The file adds to the document
+)
+
+// Attachment is a file embedded in a PDF, such as the XML of an electronic invoice.
+// Pass it to RenderPDF like the pages to attach it to the document, or to a node
+// with Attach
+type Attachment struct {
+	Name             string // The file name shown by viewers, such as factur-x.xml
+	MIMEType         string // Such as text/xml, application/octet-stream when empty
+	Description      string
+	Relationship     Relationship // RelationUnspecified when empty
+	Data             []byte
+	ModificationDate time.Time // The time of the render when zero
+}
+
+var _ RenderOption = Attachment{}
+
+func (a Attachment) configureRender(c *renderConfig) {
+	c.attachments = append(c.attachments, a)
+}
+
+// Attach attaches a file to the node. Viewers open it from the border box of the node,
+// which is drawn as usual
+func Attach(file Attachment) commonOpt {
+	return commonOptFunc(func(n *Node) {
+		n.Attachment = &file
+	})
+}
+
+// check reports an attachment without a name or with an unknown relationship
+func (a Attachment) check() error {
+	if a.Name == "" {
+		return errors.New("attachment without a name")
+	}
+	switch a.Relationship {
+	case "", RelationUnspecified, RelationSource, RelationData, RelationAlternative, RelationSupplement:
+		return nil
+	}
+	return fmt.Errorf("attachment %q has the unknown relationship %q", a.Name, a.Relationship)
+}
+
+// checkAttachments reports invalid attachments of the document and of the nodes, and
+// files attached twice to the document, before anything is drawn
+func checkAttachments(pages []*Node, attachments []Attachment) error {
+	names := map[string]bool{}
+	for _, a := range attachments {
+		if err := a.check(); err != nil {
+			return err
+		}
+		if names[a.Name] {
+			return fmt.Errorf("attachment %q is attached twice to the document", a.Name)
+		}
+		names[a.Name] = true
+	}
+
+	var walk func(node *Node) error
+	walk = func(node *Node) error {
+		if node == nil {
+			return nil
+		}
+		if node.Attachment != nil {
+			if err := node.Attachment.check(); err != nil {
+				return err
+			}
+		}
+		for _, child := range node.Children {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, page := range pages {
+		if err := walk(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pdfAttachment is a file attached to a node, written by attach once the pages are
+// written
+type pdfAttachment struct {
+	page int  // The number of the page, starting at 1
+	rect Rect // In PDF coordinates, from the bottom left corner
+	file Attachment
+}
+
+// attach embeds the files attached to the document, listed by the catalog, and adds
+// the annotations of the files attached to nodes to their pages. Files without a
+// modification date get date
+func (f *pdfFile) attach(attachments []Attachment, annotations []pdfAttachment, date time.Time) error {
+	if len(attachments) > 0 {
+		sorted := append([]Attachment(nil), attachments...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+		var names, files []string
+		for _, a := range sorted {
+			spec := f.embed(a, date)
+			names = append(names, fmt.Sprintf("%s %d 0 R", pdfLiteral(a.Name), spec))
+			files = append(files, fmt.Sprintf("%d 0 R", spec))
+		}
+
+		// fpdf always writes an empty tree of embedded files
+		tree := fmt.Sprintf("/EmbeddedFiles << /Names [%s] >>", strings.Join(names, " "))
+		if !f.edit(f.root, "/EmbeddedFiles << /Names [\n  \n] >>", tree) {
+			return errors.New("failed to attach files: unexpected catalog")
+		}
+		f.catalog(fmt.Sprintf("/AF [%s]", strings.Join(files, " ")))
+	}
+
+	pages := f.pages()
+	for _, a := range annotations {
+		if a.page < 1 || a.page > len(pages) {
+			return fmt.Errorf("failed to attach %q: page %d not found", a.file.Name, a.page)
+		}
+
+		spec := f.embed(a.file, date)
+		appearance := f.add(fmt.Sprintf("<</Type /XObject /Subtype /Form /BBox [0 0 %.2f %.2f] /Length 0>>", a.rect.Width, a.rect.Height), []byte{})
+		contents := a.file.Description
+		if contents == "" {
+			contents = a.file.Name
+		}
+		annotation := f.add(fmt.Sprintf("<</Type /Annot /Subtype /FileAttachment /Rect [%.2f %.2f %.2f %.2f] /F 4 /Contents %s /Name /Paperclip /FS %d 0 R /AF [%d 0 R] /AP <</N %d 0 R>>>>",
+			a.rect.X, a.rect.Y, a.rect.X+a.rect.Width, a.rect.Y+a.rect.Height, pdfLiteral(contents), spec, spec, appearance), nil)
+		f.annotate(pages[a.page-1], annotation)
+	}
+	return nil
+}
+
+// embed adds an embedded file and its file specification, returning the number of
+// the specification
+func (f *pdfFile) embed(a Attachment, date time.Time) int {
+	mimeType := a.MIMEType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	relationship := a.Relationship
+	if relationship == "" {
+		relationship = RelationUnspecified
+	}
+	if !a.ModificationDate.IsZero() {
+		date = a.ModificationDate
+	}
+
+	data := deflate(a.Data)
+	stream := f.add(fmt.Sprintf("<</Type /EmbeddedFile /Subtype %s /Params <</Size %d /ModDate %s /CheckSum <%X>>> /Filter /FlateDecode /Length %d>>",
+		pdfName(mimeType), len(a.Data), pdfLiteral(pdfDate(date)), md5.Sum(a.Data), len(data)), data)
+
+	description := ""
+	if a.Description != "" {
+		description = " /Desc " + pdfLiteral(a.Description)
+	}
+	return f.add(fmt.Sprintf("<</Type /Filespec /F %s /UF %s%s /AFRelationship /%s /EF <</F %d 0 R /UF %d 0 R>>>>",
+		pdfLiteral(a.Name), pdfLiteral(a.Name), description, relationship, stream, stream), nil)
+}
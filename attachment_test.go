@@ -0,0 +1,216 @@
+package sahar
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// embeddedFile returns the content of the first embedded file of a PDF
+func embeddedFile(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	match := regexp.MustCompile(`/Type /EmbeddedFile [^\n]*/Length (\d+)>>\nstream\n`).FindSubmatchIndex(data)
+	if match == nil {
+		t.Fatal("expected an embedded file")
+	}
+	length, _ := strconv.Atoi(string(data[match[2]:match[3]]))
+	r, err := zlib.NewReader(bytes.NewReader(data[match[1] : match[1]+length]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestAttachment(t *testing.T) {
+	invoice := Attachment{
+		Name:         "factur-x.xml",
+		MIMEType:     "text/xml",
+		Description:  "Invoice data",
+		Relationship: RelationData,
+		Data:         []byte(`<?xml version="1.0"?><Invoice/>`),
+	}
+
+	t.Run("attaches files to the document", func(t *testing.T) {
+		page := Layout(Box(Sizing(Fixed(100), Fixed(100))))
+		notes := Attachment{Name: "notes.txt", Data: []byte("notes")}
+
+		var buf bytes.Buffer
+		if err := RenderPDF(&buf, page, notes, invoice, Deterministic()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		data := buf.Bytes()
+		checkXref(t, data)
+		output := buf.String()
+		for _, expected := range []string{
+			"/Type /EmbeddedFile /Subtype /text#2Fxml /Params <</Size 31 /ModDate (D:19700101000000Z) /CheckSum <",
+			"/Subtype /application#2Foctet-stream",
+			"/F (factur-x.xml) /UF (factur-x.xml) /Desc (Invoice data) /AFRelationship /Data",
+			"/AFRelationship /Unspecified",
+			"/Type /Catalog\n/AF [",
+		} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("expected %s in the PDF", expected)
+			}
+		}
+		if !regexp.MustCompile(`/EmbeddedFiles << /Names \[\(factur-x.xml\) \d+ 0 R \(notes.txt\) \d+ 0 R\] >>`).MatchString(output) {
+			t.Error("expected the files listed by name in the catalog")
+		}
+		if content := embeddedFile(t, data); !bytes.Equal(content, invoice.Data) {
+			t.Errorf("expected the attached data, got %q", content)
+		}
+	})
+
+	t.Run("attaches files to nodes", func(t *testing.T) {
+		if err := LoadFonts("AttachmentArial", "./examples/basic/Arial.ttf"); err != nil {
+			t.Skip("Arial.ttf not found in examples/basic")
+		}
+
+		pages := func() []RenderOption {
+			return []RenderOption{
+				Layout(Box(Sizing(Fixed(100), Fixed(100)), Children(Text("Cover", FontType("AttachmentArial"), FontSize(10))))),
+				Layout(Box(
+					Sizing(Fixed(100), Fixed(80)),
+					Padding(10, 10, 10, 10),
+					Children(Box(Sizing(Fixed(20), Fixed(10)), Attach(invoice))),
+				)),
+				PDFA3B,
+				Deterministic(),
+			}
+		}
+
+		var first, second bytes.Buffer
+		if err := RenderPDF(&first, pages()...); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := RenderPDF(&second, pages()...); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Error("expected identical output in deterministic mode")
+		}
+
+		data := first.Bytes()
+		checkXref(t, data)
+		if !strings.Contains(first.String(), "/Subtype /FileAttachment /Rect [10.00 60.00 30.00 70.00] /F 4 /Contents (Invoice data)") {
+			t.Error("expected an annotation over the node")
+		}
+
+		f, err := parsePDF(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pageObjects := f.pages()
+		if len(pageObjects) != 2 {
+			t.Fatalf("expected 2 pages, got %d", len(pageObjects))
+		}
+		if bytes.Contains(f.objects[pageObjects[0]], []byte("/Annots")) || !bytes.Contains(f.objects[pageObjects[1]], []byte("/Annots [")) {
+			t.Error("expected the annotation on the second page only")
+		}
+		if content := embeddedFile(t, data); !bytes.Equal(content, invoice.Data) {
+			t.Errorf("expected the attached data, got %q", content)
+		}
+	})
+
+	t.Run("is set by PDFOptions", func(t *testing.T) {
+		options := DefaultPDFOptions()
+		options.Attachments = []Attachment{invoice}
+
+		var buf bytes.Buffer
+		if err := RenderToPDFWithOptions(Layout(Box(Sizing(Fixed(100), Fixed(100)))), &buf, options); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		checkXref(t, buf.Bytes())
+		if !strings.Contains(buf.String(), "/AFRelationship /Data") {
+			t.Error("expected the attachment in the PDF")
+		}
+	})
+
+	t.Run("draws attachments on canvases", func(t *testing.T) {
+		page := Layout(Box(Sizing(Fixed(50), Fixed(50)), Padding(5, 5, 5, 5), Children(Box(Sizing(Fixed(10), Fixed(10)), Attach(invoice)))))
+
+		canvas := &recordingCanvas{}
+		if err := Render(canvas, page); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(strings.Join(canvas.calls, "\n"), "attach factur-x.xml 5,5 10x10") {
+			t.Errorf("expected the attachment, got:\n%s", strings.Join(canvas.calls, "\n"))
+		}
+
+		var svg bytes.Buffer
+		if err := RenderToSVG(&svg, page); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(svg.String(), `<a href="data:text/xml;base64,PD94bWwg`) || !strings.Contains(svg.String(), `download="factur-x.xml"`) {
+			t.Errorf("expected a download link in:\n%s", svg.String())
+		}
+	})
+
+	t.Run("reports invalid attachments", func(t *testing.T) {
+		tests := []struct {
+			name string
+			opts []RenderOption
+			err  string
+		}{
+			{
+				name: "without name",
+				opts: []RenderOption{Attachment{Data: []byte("x")}},
+				err:  "attachment without a name",
+			},
+			{
+				name: "unknown relationship",
+				opts: []RenderOption{Attachment{Name: "a.xml", Relationship: "Related"}},
+				err:  `attachment "a.xml" has the unknown relationship "Related"`,
+			},
+			{
+				name: "attached twice",
+				opts: []RenderOption{invoice, invoice},
+				err:  `attachment "factur-x.xml" is attached twice to the document`,
+			},
+			{
+				name: "invalid node attachment",
+				opts: []RenderOption{Layout(Box(Sizing(Fixed(10), Fixed(10)), Children(Box(Attach(Attachment{})))))},
+				err:  "attachment without a name",
+			},
+			{
+				name: "PDF/A-2b",
+				opts: []RenderOption{invoice, PDFA2B},
+				err:  `attachment "factur-x.xml" can't be checked to be PDF/A, PDF/A-3b allows attaching any file`,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				opts := append([]RenderOption{Layout(Box(Sizing(Fixed(10), Fixed(10))))}, tt.opts...)
+				err := RenderPDF(&bytes.Buffer{}, opts...)
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected %q, got %v", tt.err, err)
+				}
+			})
+		}
+	})
+}
+
+func TestPDFStrings(t *testing.T) {
+	if name := pdfName("text/xml"); name != "/text#2Fxml" {
+		t.Errorf("unexpected name %s", name)
+	}
+	if name := pdfName("a b#c"); name != "/a#20b#23c" {
+		t.Errorf("unexpected name %s", name)
+	}
+	if literal := pdfLiteral(`a(b)\c`); literal != `(a\(b\)\\c)` {
+		t.Errorf("unexpected literal %s", literal)
+	}
+	if literal := pdfLiteral("é"); literal != "(\xfe\xff\x00\xe9)" {
+		t.Errorf("unexpected literal %q", literal)
+	}
+}
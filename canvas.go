@@ -0,0 +1,554 @@
+package sahar
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Canvas is a drawing surface for laid-out pages. Render walks the node tree and draws
+// every node with these calls, so an output only has to implement Canvas to be rendered
+// like RenderToPDF, RenderToImage and RenderToSVG do. Positions and sizes are in points
+// from the top left corner of the page. A canvas can also implement LinkCanvas,
+// AttachCanvas, FormCanvas and TagCanvas, the nodes they are for are drawn without
+// them otherwise
+type Canvas interface {
+	// BeginPage starts a page of the given size, the other calls draw on it until EndPage
+	BeginPage(width, height float64) error
+	EndPage() error
+
+	// Rect fills and strokes a rectangle, the stroke is centered on its edges
+	Rect(rect Rect, style Style) error
+	// Path fills and strokes straight segments between the points, a closed path
+	// also connects the last point to the first one
+	Path(points []Point, closed bool, style Style) error
+	// MeasureText returns the width of a line of text in the font, it is used to align
+	// the runs passed to Text
+	MeasureText(text string, font Font) float64
+	// Text draws a single line of text
+	Text(run TextRun) error
+	// Image draws an image file stretched over the rectangle
+	Image(src string, rect Rect) error
+
+	// PushClip restricts drawing to the rectangle, within the current clip, until the
+	// matching PopClip
+	PushClip(rect Rect) error
+	PopClip() error
+	// Link makes the rectangle a link to the URL, or to the anchor called name when
+	// the URL is #name, which may be on a later page
+	Link(rect Rect, url string) error
+}
+
+// LinkCanvas is a Canvas with positions links and the outline of the document can
+// point to
+type LinkCanvas interface {
+	Canvas
+	// Anchor names a position of the page, links to #name jump to it
+	Anchor(name string, at Point) error
+	// Bookmark adds an entry pointing to a position of the page to the outline of the
+	// document, nested in the last entry of the level above
+	Bookmark(title string, level int, at Point) error
+}
+
+// AttachCanvas is a Canvas with files attached to its pages
+type AttachCanvas interface {
+	Canvas
+	// Attach attaches a file to the rectangle, viewers open it from there
+	Attach(rect Rect, file Attachment) error
+}
+
+// FormCanvas is a Canvas with fillable form fields
+type FormCanvas interface {
+	Canvas
+	// Field puts a fillable form field over the rectangle, aligning its value to align
+	Field(rect Rect, field FormField, align Horizontal) error
+}
+
+// TagCanvas is a Canvas with a structure tree, for documents rendered with Tagged
+type TagCanvas interface {
+	Canvas
+	// BeginTag starts an element of the structure tree with the role and the alt text,
+	// holding what is drawn until the matching EndTag. RoleArtifact marks decoration
+	BeginTag(role Role, alt string) error
+	EndTag() error
+}
+
+// Rect is an area of the page
+type Rect struct {
+	X, Y, Width, Height float64
+}
+
+// Point is a position on the page
+type Point struct {
+	X, Y float64
+}
+
+// Color is an opaque color, it implements color.Color
+type Color struct {
+	R, G, B uint8
+}
+
+// RGBA returns the color with 16 bits per channel, see color.Color
+func (c Color) RGBA() (r, g, b, a uint32) {
+	return uint32(c.R) * 0x101, uint32(c.G) * 0x101, uint32(c.B) * 0x101, 0xffff
+}
+
+// Style is how a shape is painted, a nil color leaves out the fill or the stroke
+type Style struct {
+	Fill        *Color
+	Stroke      *Color
+	StrokeWidth float64
+	Opacity     float64 // Of the fill and the stroke, from 0 to 1, opaque when zero
+}
+
+// translucent reports whether the shape is painted with an opacity below 1
+func (s Style) translucent() bool {
+	return s.Opacity > 0 && s.Opacity < 1
+}
+
+// Font is the font of a text run, Type is the name given to LoadFonts or the name of
+// a standard font such as Arial, Times or Courier
+type Font struct {
+	Type string
+	Size float64
+}
+
+// TextRun is a line of text. X and Y are the start of its baseline, Width is the width
+// returned by MeasureText and Align the side of its node it is aligned to, so a canvas
+// measuring the font differently can keep it aligned
+type TextRun struct {
+	Text  string
+	X, Y  float64
+	Width float64
+	Align Horizontal
+	Font  Font
+	Color Color
+}
+
+// defaultFont is the font of text nodes without FontType or FontSize
+var defaultFont = Font{Type: "Arial", Size: 12}
+
+// Render draws the node tree on a canvas. Every node passed is drawn as a page sized
+// to the node, between a BeginPage and an EndPage, after filling the tables of contents
+// like RenderToPDF
+func Render(canvas Canvas, opts ...RenderOption) error {
+	var config renderConfig
+	for _, opt := range opts {
+		opt.configureRender(&config)
+	}
+
+	if len(config.pages) == 0 {
+		return fmt.Errorf("there is no node to render")
+	}
+	pages, err := fillContents(config.pages)
+	if err != nil {
+		return err
+	}
+	config.pages = pages
+	if err := checkLinks(config.pages); err != nil {
+		return err
+	}
+	if err := checkAttachments(config.pages, config.attachments); err != nil {
+		return err
+	}
+	if err := checkFields(config.pages); err != nil {
+		return err
+	}
+
+	if err := checkTags(config.pages, config); err != nil {
+		return err
+	}
+
+	r := &canvasRenderer{canvas: canvas, font: defaultFont, tagged: config.tagged}
+	for _, page := range config.pages {
+		if err := canvas.BeginPage(page.Width.Value, page.Height.Value); err != nil {
+			return err
+		}
+		if err := r.node(page); err != nil {
+			return fmt.Errorf("failed to render node: %w", err)
+		}
+		if config.debug {
+			if err := r.debugOverlay(page); err != nil {
+				return err
+			}
+		}
+		if err := canvas.EndPage(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// canvasRenderer draws nodes on a canvas
+type canvasRenderer struct {
+	canvas Canvas
+	font   Font   // The font of text without FontType or FontSize
+	tagged bool   // Draw the nodes inside elements of the structure tree, see Tagged
+	roles  []Role // The roles of the open elements
+}
+
+// node recursively draws a node and its children
+func (r *canvasRenderer) node(node *Node) error {
+	if node == nil {
+		return nil
+	}
+
+	if r.tagged {
+		if role := r.role(node); role != "" {
+			if err := r.beginTag(role, node.Alt); err != nil {
+				return err
+			}
+			if err := r.content(node, role); err != nil {
+				return err
+			}
+			return r.endTag()
+		}
+	}
+	return r.content(node, "")
+}
+
+// role returns the role of a node in the structure tree, empty for nodes which only
+// group their children, see Role
+func (r *canvasRenderer) role(node *Node) Role {
+	parent := r.parentRole()
+	switch {
+	case parent == RoleArtifact:
+		// The children of artifacts are artifacts
+		return ""
+	case node.Role != "":
+		return node.Role
+	case parent == RoleTableRow:
+		return RoleTableCell
+	case node.Type == ImageType:
+		return RoleFigure
+	case node.Type == TextType && node.Value != "" && !parent.holdsContent():
+		return RoleParagraph
+	}
+	return ""
+}
+
+// parentRole returns the role of the innermost open element
+func (r *canvasRenderer) parentRole() Role {
+	if len(r.roles) == 0 {
+		return ""
+	}
+	return r.roles[len(r.roles)-1]
+}
+
+// content draws a node of the role and its children
+func (r *canvasRenderer) content(node *Node, role Role) error {
+	var err error
+	switch node.Type {
+	case BoxType, GridType:
+		err = r.box(node)
+	case TextType:
+		err = r.text(node)
+	case ImageType:
+		err = r.image(node)
+	}
+	if err != nil {
+		return err
+	}
+	if err := r.marks(node); err != nil {
+		return err
+	}
+
+	// Children which don't fit in the node are cut at its border box
+	clipped := len(node.Children) > 0 && (node.overflow[0] > layoutEpsilon || node.overflow[1] > layoutEpsilon)
+	if clipped {
+		if err := r.canvas.PushClip(nodeRect(node)); err != nil {
+			return err
+		}
+	}
+
+	if r.tagged && role == RoleTable && node.Type == GridType {
+		err = r.rows(node)
+	} else {
+		err = r.children(node)
+	}
+	if err != nil || !clipped {
+		return err
+	}
+	return r.canvas.PopClip()
+}
+
+// children draws the children of a node in order
+func (r *canvasRenderer) children(node *Node) error {
+	for _, child := range node.Children {
+		if err := r.node(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rows draws the children of a Grid table inside an element for every row, a new row
+// starts with a child placed on a later row than the previous one
+func (r *canvasRenderer) rows(node *Node) error {
+	rows := make(map[*Node]int, len(node.gridCells))
+	for _, cell := range node.gridCells {
+		rows[cell.child] = cell.row
+	}
+
+	open := false
+	row := -1
+	for _, child := range node.Children {
+		if child.Role == "" || child.Role == RoleTableHeader || child.Role == RoleTableCell {
+			if !open || rows[child] > row {
+				if open {
+					if err := r.endTag(); err != nil {
+						return err
+					}
+				}
+				if err := r.beginTag(RoleTableRow, ""); err != nil {
+					return err
+				}
+				open = true
+			}
+			row = rows[child]
+		} else if open {
+			if err := r.endTag(); err != nil {
+				return err
+			}
+			open = false
+		}
+
+		if err := r.node(child); err != nil {
+			return err
+		}
+	}
+	if open {
+		return r.endTag()
+	}
+	return nil
+}
+
+// beginTag starts an element of the structure tree, which endTag ends. Canvases
+// which are not a TagCanvas are drawn without the elements
+func (r *canvasRenderer) beginTag(role Role, alt string) error {
+	r.roles = append(r.roles, role)
+	if canvas, ok := r.canvas.(TagCanvas); ok {
+		return canvas.BeginTag(role, alt)
+	}
+	return nil
+}
+
+func (r *canvasRenderer) endTag() error {
+	r.roles = r.roles[:len(r.roles)-1]
+	if canvas, ok := r.canvas.(TagCanvas); ok {
+		return canvas.EndTag()
+	}
+	return nil
+}
+
+// box draws the background of a node as a rectangle and its border as a closed path
+func (r *canvasRenderer) box(node *Node) error {
+	if node.Border <= 0 && node.BackgroundColor == "" {
+		return nil
+	}
+
+	// Backgrounds and borders are decoration
+	if r.tagged && r.parentRole() != RoleArtifact {
+		if err := r.beginTag(RoleArtifact, ""); err != nil {
+			return err
+		}
+		if err := r.decoration(node); err != nil {
+			return err
+		}
+		return r.endTag()
+	}
+	return r.decoration(node)
+}
+
+func (r *canvasRenderer) decoration(node *Node) error {
+	rect := nodeRect(node)
+	if node.BackgroundColor != "" {
+		fill, err := parseColor(node.BackgroundColor, "")
+		if err != nil {
+			return fmt.Errorf("invalid background color: %w", err)
+		}
+		if err := r.canvas.Rect(rect, Style{Fill: &fill}); err != nil {
+			return err
+		}
+	}
+	if node.Border > 0 {
+		stroke, err := parseColor(node.BorderColor, "#000000")
+		if err != nil {
+			return fmt.Errorf("invalid border color: %w", err)
+		}
+		return r.canvas.Path(rect.corners(), true, Style{Stroke: &stroke, StrokeWidth: node.Border})
+	}
+	return nil
+}
+
+// text draws the lines of a text node, aligned inside the node
+func (r *canvasRenderer) text(node *Node) error {
+	if node.Value == "" {
+		return nil
+	}
+
+	if node.Border > 0 {
+		if err := r.box(node); err != nil {
+			return err
+		}
+	}
+
+	color, err := parseColor(node.FontColor, "#000000")
+	if err != nil {
+		return fmt.Errorf("invalid font color: %w", err)
+	}
+
+	font := Font{Type: node.FontType, Size: node.FontSize}
+	if font.Type == "" || font.Size <= 0 {
+		font = r.font
+	}
+
+	// The baseline is at the ascender, about 75% of the em-square for most fonts
+	lines := strings.Split(node.Value, "\n")
+	lineSpacing := font.Size * 1.2
+	startY := calculateVerticalPosition(node, lines, lineSpacing, font.Size*0.75, font.Size)
+
+	for i, line := range lines {
+		// Empty lines are skipped but keep their position
+		if line == "" {
+			continue
+		}
+
+		width := r.canvas.MeasureText(line, font)
+		err := r.canvas.Text(TextRun{
+			Text:  line,
+			X:     calculateHorizontalPosition(node, width),
+			Y:     startY + float64(i)*lineSpacing,
+			Width: width,
+			Align: node.Horizontal,
+			Font:  font,
+			Color: color,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// image draws an image node stretched over the node
+func (r *canvasRenderer) image(node *Node) error {
+	if node.Border > 0 {
+		if err := r.box(node); err != nil {
+			return err
+		}
+	}
+
+	return r.canvas.Image(node.Value, nodeRect(node))
+}
+
+// marks adds the link, the anchor, the bookmark, the attachment and the form field of
+// a node, the ones the canvas doesn't support are left out
+func (r *canvasRenderer) marks(node *Node) error {
+	at := Point{X: node.Position.X, Y: node.Position.Y}
+
+	if node.Link != "" {
+		if err := r.canvas.Link(nodeRect(node), node.Link); err != nil {
+			return err
+		}
+	}
+	if canvas, ok := r.canvas.(LinkCanvas); ok {
+		if node.Anchor != "" {
+			if err := canvas.Anchor(node.Anchor, at); err != nil {
+				return err
+			}
+		}
+		if node.Bookmark != "" {
+			if err := canvas.Bookmark(node.Bookmark, node.BookmarkLevel, at); err != nil {
+				return err
+			}
+		}
+	}
+	if canvas, ok := r.canvas.(AttachCanvas); ok && node.Attachment != nil {
+		if err := canvas.Attach(nodeRect(node), *node.Attachment); err != nil {
+			return err
+		}
+	}
+	if canvas, ok := r.canvas.(FormCanvas); ok && node.Field != nil {
+		if err := canvas.Field(nodeRect(node), *node.Field, node.Horizontal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodeRect returns the border box of a node
+func nodeRect(node *Node) Rect {
+	return Rect{X: node.Position.X, Y: node.Position.Y, Width: node.Width.Value, Height: node.Height.Value}
+}
+
+// corners returns the corners of the rectangle clockwise from the top left one
+func (r Rect) corners() []Point {
+	return []Point{{r.X, r.Y}, {r.X + r.Width, r.Y}, {r.X + r.Width, r.Y + r.Height}, {r.X, r.Y + r.Height}}
+}
+
+// parseColor converts a hex color, using defaultColor when it is empty
+func parseColor(hex, defaultColor string) (Color, error) {
+	red, green, blue, err := hexToRGB(hex, defaultColor)
+	if err != nil {
+		return Color{}, err
+	}
+	return Color{R: uint8(red), G: uint8(green), B: uint8(blue)}, nil
+}
+
+// calculateVerticalPosition calculates the starting Y position based on vertical alignment
+func calculateVerticalPosition(node *Node, lines []string, lineSpacing, ascender, fontSize float64) float64 {
+	y := node.Position.Y
+	height := node.Height.Value
+
+	// Calculate total text height matching measureTextHeight logic
+	var totalTextHeight float64
+	if len(lines) == 1 {
+		totalTextHeight = fontSize // Single line uses just fontSize
+	} else {
+		totalTextHeight = fontSize + float64(len(lines)-1)*lineSpacing
+	}
+
+	// Baseline offset from top of text block is the ascender
+	switch node.Vertical {
+	case Top:
+		return y + ascender
+	case Middle:
+		return y + (height-totalTextHeight)/2 + ascender
+	case Bottom:
+		return y + height - totalTextHeight + ascender
+	default:
+		return y + ascender
+	}
+}
+
+// calculateHorizontalPosition calculates the X position of a line of the given width
+// based on horizontal alignment
+func calculateHorizontalPosition(node *Node, textWidth float64) float64 {
+	x := node.Position.X
+	width := node.Width.Value
+
+	var lineX float64
+	switch node.Horizontal {
+	case Left:
+		lineX = x + node.Padding[3]
+	case Center:
+		lineX = x + (width-textWidth)/2
+	case Right:
+		lineX = x + width - textWidth - node.Padding[1]
+	default:
+		lineX = x + node.Padding[3]
+	}
+
+	// Ensure text doesn't go outside the node bounds (only clamp if width is positive)
+	if width > 0 {
+		if lineX < x {
+			lineX = x
+		}
+		if textWidth < width && lineX+textWidth > x+width {
+			lineX = x + width - textWidth
+		}
+	}
+
+	return lineX
+}
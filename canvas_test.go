@@ -0,0 +1,349 @@
+package sahar
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// recordingCanvas records the calls of Render, measuring every character as 5 points
+type recordingCanvas struct {
+	calls []string
+	runs  []TextRun
+	err   error // Returned by Rect when set
+}
+
+func (c *recordingCanvas) BeginPage(width, height float64) error {
+	c.calls = append(c.calls, fmt.Sprintf("page %gx%g", width, height))
+	return nil
+}
+
+func (c *recordingCanvas) EndPage() error {
+	c.calls = append(c.calls, "end")
+	return nil
+}
+
+func (c *recordingCanvas) Rect(rect Rect, style Style) error {
+	call := fmt.Sprintf("rect %g,%g %gx%g", rect.X, rect.Y, rect.Width, rect.Height)
+	if style.Fill != nil {
+		call += " fill " + svgColor(*style.Fill)
+	}
+	if style.Stroke != nil {
+		call += fmt.Sprintf(" stroke %s %g", svgColor(*style.Stroke), style.StrokeWidth)
+	}
+	if style.Opacity > 0 {
+		call += fmt.Sprintf(" opacity %g", style.Opacity)
+	}
+	c.calls = append(c.calls, call)
+	return c.err
+}
+
+func (c *recordingCanvas) Path(points []Point, closed bool, style Style) error {
+	call := "path"
+	for _, point := range points {
+		call += fmt.Sprintf(" %g,%g", point.X, point.Y)
+	}
+	if closed {
+		call += " closed"
+	}
+	if style.Stroke != nil {
+		call += fmt.Sprintf(" stroke %s %g", svgColor(*style.Stroke), style.StrokeWidth)
+	}
+	if style.Opacity > 0 {
+		call += fmt.Sprintf(" opacity %g", style.Opacity)
+	}
+	c.calls = append(c.calls, call)
+	return c.err
+}
+
+func (c *recordingCanvas) MeasureText(text string, font Font) float64 {
+	return float64(len(text)) * 5
+}
+
+func (c *recordingCanvas) Text(run TextRun) error {
+	c.calls = append(c.calls, "text "+run.Text)
+	c.runs = append(c.runs, run)
+	return nil
+}
+
+func (c *recordingCanvas) Image(src string, rect Rect) error {
+	c.calls = append(c.calls, fmt.Sprintf("image %s %g,%g %gx%g", src, rect.X, rect.Y, rect.Width, rect.Height))
+	return nil
+}
+
+func (c *recordingCanvas) PushClip(rect Rect) error {
+	c.calls = append(c.calls, fmt.Sprintf("clip %g,%g %gx%g", rect.X, rect.Y, rect.Width, rect.Height))
+	return nil
+}
+
+func (c *recordingCanvas) PopClip() error {
+	c.calls = append(c.calls, "unclip")
+	return nil
+}
+
+func (c *recordingCanvas) Link(rect Rect, url string) error {
+	c.calls = append(c.calls, fmt.Sprintf("link %s %g,%g %gx%g", url, rect.X, rect.Y, rect.Width, rect.Height))
+	return nil
+}
+
+func (c *recordingCanvas) Anchor(name string, at Point) error {
+	c.calls = append(c.calls, fmt.Sprintf("anchor %s %g,%g", name, at.X, at.Y))
+	return nil
+}
+
+func (c *recordingCanvas) Bookmark(title string, level int, at Point) error {
+	c.calls = append(c.calls, fmt.Sprintf("bookmark %s %d %g,%g", title, level, at.X, at.Y))
+	return nil
+}
+
+func (c *recordingCanvas) Attach(rect Rect, file Attachment) error {
+	c.calls = append(c.calls, fmt.Sprintf("attach %s %g,%g %gx%g", file.Name, rect.X, rect.Y, rect.Width, rect.Height))
+	return nil
+}
+
+func (c *recordingCanvas) Field(rect Rect, field FormField, align Horizontal) error {
+	c.calls = append(c.calls, fmt.Sprintf("field %s %s %g,%g %gx%g", field.Kind, field.Name, rect.X, rect.Y, rect.Width, rect.Height))
+	return nil
+}
+
+func (c *recordingCanvas) BeginTag(role Role, alt string) error {
+	c.calls = append(c.calls, strings.TrimSpace(fmt.Sprintf("tag %s %s", role, alt)))
+	return nil
+}
+
+func (c *recordingCanvas) EndTag() error {
+	c.calls = append(c.calls, "untag")
+	return nil
+}
+
+func TestRender(t *testing.T) {
+	t.Run("draws the nodes of every page", func(t *testing.T) {
+		first := Layout(Box(
+			Sizing(Fixed(100), Fixed(50)),
+			BackgroundColor("#EEEEEE"),
+			Padding(5, 5, 5, 5),
+			Children(
+				Box(Sizing(Fixed(20), Fixed(20)), Border(2)),
+				Image("logo.png", Sizing(Fixed(10), Fixed(10))),
+			),
+		))
+		second := Layout(Box(Sizing(Fixed(30), Fixed(30))))
+
+		canvas := &recordingCanvas{}
+		if err := Render(canvas, first, second); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []string{
+			"page 100x50",
+			"rect 0,0 100x50 fill #EEEEEE",
+			"path 5,5 25,5 25,25 5,25 closed stroke #000000 2",
+			"image logo.png 25,5 10x10",
+			"end",
+			"page 30x30",
+			"end",
+		}
+		if strings.Join(canvas.calls, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("expected calls:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(canvas.calls, "\n"))
+		}
+	})
+
+	t.Run("aligns text runs with the canvas measures", func(t *testing.T) {
+		text := Text("abcdefgh", FontType("Times"), FontSize(10), FontColor("#FF0000"))
+		text.Horizontal = Right
+
+		// Layout wraps the text again, the lines are set on the laid-out node
+		page := Layout(Box(Sizing(Fixed(100), Fixed(50)), Children(text)))
+		text = page.Children[0]
+		text.Value = "ab\n\nabcd"
+
+		canvas := &recordingCanvas{}
+		if err := Render(canvas, page); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(canvas.runs) != 2 {
+			t.Fatalf("expected 2 runs for the lines which are not empty, got %d", len(canvas.runs))
+		}
+
+		first, last := canvas.runs[0], canvas.runs[1]
+		right := text.Position.X + text.Width.Value
+		if first.X+first.Width != right || first.Width != 10 || first.Align != Right {
+			t.Errorf("expected the first line aligned right, got %+v", first)
+		}
+		if last.X+last.Width != right || last.Y-first.Y != 2*10*1.2 {
+			t.Errorf("expected the last line two lines below, got %+v", last)
+		}
+		if first.Font != (Font{Type: "Times", Size: 10}) || first.Color != (Color{R: 0xff}) {
+			t.Errorf("expected the font of the node in red, got %+v", first)
+		}
+	})
+
+	t.Run("leaves out the calls of optional interfaces", func(t *testing.T) {
+		page := Layout(Box(
+			Sizing(Fixed(100), Fixed(50)),
+			Anchor("top"),
+			Bookmark("Top", 0),
+			Children(
+				Text("Notes", Link("#top"), Attach(Attachment{Name: "notes.txt", Data: []byte("notes")})),
+				TextField("name", Sizing(Fixed(40), Fixed(20))),
+			),
+		))
+
+		// core only has the methods of Canvas
+		type core struct{ Canvas }
+		recording := &recordingCanvas{}
+		if err := Render(core{recording}, page, Tagged()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		calls := strings.Join(recording.calls, "\n")
+		for _, call := range recording.calls {
+			switch name, _, _ := strings.Cut(call, " "); name {
+			case "anchor", "bookmark", "attach", "field", "tag", "untag":
+				t.Errorf("unexpected %s call in:\n%s", name, calls)
+			}
+		}
+		if !strings.Contains(calls, "link #top ") {
+			t.Errorf("expected the link in:\n%s", calls)
+		}
+	})
+
+	t.Run("clips children overflowing their node", func(t *testing.T) {
+		page := Layout(Box(
+			Sizing(Fixed(100), Fixed(50)),
+			Children(
+				Box(Sizing(Fixed(40), Fixed(20)), Children(Image("wide.png", Sizing(Fixed(60), Fixed(10))))),
+				Box(Sizing(Fixed(40), Fixed(20)), Children(Image("narrow.png", Sizing(Fixed(30), Fixed(10))))),
+			),
+		))
+
+		canvas := &recordingCanvas{}
+		if err := Render(canvas, page); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []string{
+			"page 100x50",
+			"clip 0,0 40x20",
+			"image wide.png 0,0 60x10",
+			"unclip",
+			"image narrow.png 40,0 30x10",
+			"end",
+		}
+		if strings.Join(canvas.calls, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("expected calls:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(canvas.calls, "\n"))
+		}
+	})
+
+	t.Run("draws the debug overlay on top of the page", func(t *testing.T) {
+		page := Layout(Box(Sizing(Fixed(40), Fixed(20)), Padding(5, 5, 5, 5),
+			Box(Sizing(Fixed(10), Fixed(10)), BackgroundColor("#FF0000")),
+		))
+
+		canvas := &recordingCanvas{}
+		if err := Render(canvas, page, DebugOverlay()); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{
+			"page 40x20",
+			"rect 5,5 10x10 fill #FF0000",
+			"rect 0,0 40x5 fill #1E88E5 opacity 0.2",
+			"rect 0,15 40x5 fill #1E88E5 opacity 0.2",
+			"rect 0,5 5x10 fill #1E88E5 opacity 0.2",
+			"rect 35,5 5x10 fill #1E88E5 opacity 0.2",
+			"rect 0,0 40x20 stroke #1E88E5 0.5",
+			"text Box 40.00x20.00",
+			"rect 5,5 10x10 stroke #1E88E5 0.5",
+			"text Box 10.00x10.00",
+			"end",
+		}
+		if strings.Join(canvas.calls, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("expected calls:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(canvas.calls, "\n"))
+		}
+	})
+
+	t.Run("reports errors", func(t *testing.T) {
+		page := Layout(Box(Sizing(Fixed(10), Fixed(10)), Border(1)))
+
+		if err := Render(&recordingCanvas{}); err == nil {
+			t.Error("expected an error without node")
+		}
+
+		failure := errors.New("out of ink")
+		if err := Render(&recordingCanvas{err: failure}, page); !errors.Is(err, failure) {
+			t.Errorf("expected the error of the canvas, got %v", err)
+		}
+	})
+}
+
+func TestColor(t *testing.T) {
+	r, g, b, a := (Color{R: 0xff, G: 0x80}).RGBA()
+	if r != 0xffff || g != 0x8080 || b != 0 || a != 0xffff {
+		t.Errorf("unexpected RGBA %x %x %x %x", r, g, b, a)
+	}
+
+	if c := color.RGBAModel.Convert(Color{B: 0x10}); c != (color.RGBA{B: 0x10, A: 0xff}) {
+		t.Errorf("unexpected conversion %v", c)
+	}
+}
+
+func TestCanvasClip(t *testing.T) {
+	red := Color{R: 0xff}
+
+	t.Run("raster", func(t *testing.T) {
+		canvas := &rasterCanvas{scale: 1}
+		canvas.BeginPage(20, 20)
+		canvas.PushClip(Rect{X: 5, Y: 5, Width: 10, Height: 10})
+		canvas.Rect(Rect{Width: 20, Height: 20}, Style{Fill: &red})
+		if err := canvas.PopClip(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		canvas.Path([]Point{{0, 18}, {20, 18}}, false, Style{Stroke: &red, StrokeWidth: 2})
+		canvas.EndPage()
+
+		if c := canvas.img.RGBAAt(10, 10); c != (color.RGBA{R: 0xff, A: 0xff}) {
+			t.Errorf("expected red inside the clip, got %v", c)
+		}
+		if c := canvas.img.RGBAAt(2, 2); c != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+			t.Errorf("expected white outside the clip, got %v", c)
+		}
+		if c := canvas.img.RGBAAt(2, 18); c != (color.RGBA{R: 0xff, A: 0xff}) {
+			t.Errorf("expected the path to be stroked after the clip, got %v", c)
+		}
+		if err := canvas.PopClip(); err == nil {
+			t.Error("expected an error for a PopClip without PushClip")
+		}
+	})
+
+	t.Run("svg", func(t *testing.T) {
+		canvas := &svgCanvas{fonts: map[string]bool{}}
+		canvas.BeginPage(20, 20)
+		canvas.PushClip(Rect{X: 5, Y: 5, Width: 10, Height: 10})
+		canvas.Link(Rect{Width: 20, Height: 20}, "https://example.com/?a=1&b=2")
+		canvas.PopClip()
+		canvas.Path([]Point{{0, 0}, {20, 0}, {20, 20}}, true, Style{Fill: &red})
+		if err := canvas.EndPage(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		body := canvas.body.String()
+		for _, expected := range []string{
+			`<clipPath id="clip1"><rect x="5" y="5" width="10" height="10"/></clipPath><g clip-path="url(#clip1)">`,
+			`<a href="https://example.com/?a=1&amp;b=2">`,
+			"</g>",
+			`<path d="M0 0 L20 0 L20 20 Z" fill="#FF0000"/>`,
+		} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("expected %s in:\n%s", expected, body)
+			}
+		}
+
+		canvas.PushClip(Rect{Width: 1, Height: 1})
+		if err := canvas.EndPage(); err == nil {
+			t.Error("expected an error for a clip which is not popped")
+		}
+	})
+}
@@ -0,0 +1,242 @@
+// Command sahar renders templates in the JSON or YAML node format to PDF and images.
+//
+// Usage:
+//
+//	sahar render [flags] template.yaml
+//	sahar preview [flags] template.yaml
+//
+// render writes the PDF or the image once, preview serves it on a local page which is refreshed
+// when the template, the data or the fonts change. The flags are:
+//
+//	-data file    JSON or YAML data given to the template, - reads standard input for render
+//	-fonts dir    directory of .ttf fonts, named after their file without extension, can be repeated
+//	-debug        draw the layout debug overlay
+//	-dpi number   resolution of PNG and JPEG images, 72 by default
+//	-o file       output of render, a PDF or a .png, .jpg, .jpeg or .svg image, standard output by default
+//	-strict       fail render when the layout has problems instead of reporting them as warnings
+//	-addr address address of the preview server, localhost:8080 by default
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"ella.to/sahar"
+)
+
+const usage = `Usage:
+  sahar render [flags] template     renders a template to PDF, PNG, JPEG or SVG
+  sahar preview [flags] template    serves a preview refreshed when the files change
+
+Run sahar <command> -h for the flags of a command.
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "sahar: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return errors.New("expected a command")
+	}
+
+	switch args[0] {
+	case "render":
+		return render(args[1:], stdin, stdout, stderr)
+	case "preview":
+		return preview(args[1:], stderr)
+	default:
+		fmt.Fprint(stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// dirs collects the values of a repeated flag
+type dirs []string
+
+func (d *dirs) String() string {
+	return strings.Join(*d, ",")
+}
+
+func (d *dirs) Set(value string) error {
+	*d = append(*d, value)
+	return nil
+}
+
+// document builds a page from the template, the data and the fonts given to a command
+type document struct {
+	template string
+	data     string
+	fonts    dirs
+	debug    bool
+	dpi      float64
+}
+
+// parse parses the flags of a command, the flags of the document are added to the
+// ones already defined
+func (d *document) parse(flags *flag.FlagSet, args []string, stderr io.Writer) error {
+	flags.StringVar(&d.data, "data", "", "JSON or YAML data given to the template")
+	flags.Var(&d.fonts, "fonts", "directory of .ttf fonts, can be repeated")
+	flags.BoolVar(&d.debug, "debug", false, "draw the layout debug overlay")
+	flags.Float64Var(&d.dpi, "dpi", 72, "resolution of PNG and JPEG images")
+
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: sahar %s [flags] template\n\nFlags:\n", flags.Name())
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected a single template")
+	}
+
+	d.template = flags.Arg(0)
+	return nil
+}
+
+// build loads the fonts, executes the template with the data and lays out the page
+func (d *document) build(stdin io.Reader) (*sahar.Node, sahar.Diagnostics, error) {
+	for _, dir := range d.fonts {
+		if err := loadFonts(dir); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	data, err := readData(d.data, stdin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpl, err := sahar.LoadTemplate(d.template)
+	if err != nil {
+		return nil, nil, err
+	}
+	page, err := tmpl.Execute(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute %s: %w", d.template, err)
+	}
+
+	page, diagnostics := sahar.LayoutWithDiagnostics(page)
+	return page, diagnostics, nil
+}
+
+// render writes the page built by build as PDF, or as an image when the extension
+// is .png, .jpg, .jpeg or .svg
+func (d *document) render(writer io.Writer, page *sahar.Node, extension string) error {
+	render := sahar.RenderPDF
+	switch strings.ToLower(extension) {
+	case ".png":
+		render = sahar.RenderToPNG
+	case ".jpg", ".jpeg":
+		render = sahar.RenderToJPEG
+	case ".svg":
+		render = sahar.RenderToSVG
+	}
+
+	opts := []sahar.RenderOption{page, sahar.DPI(d.dpi)}
+	if d.debug {
+		opts = append(opts, sahar.DebugOverlay())
+	}
+	return render(writer, opts...)
+}
+
+func render(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var d document
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	output := flags.String("o", "-", "output PDF, or image with a .png, .jpg, .jpeg or .svg extension, - writes a PDF to standard output")
+	strict := flags.Bool("strict", false, "fail when the layout has problems")
+	if err := d.parse(flags, args, stderr); err != nil {
+		return err
+	}
+
+	page, diagnostics, err := d.build(stdin)
+	if err != nil {
+		return err
+	}
+	if *strict {
+		if err := diagnostics.Err(); err != nil {
+			return err
+		}
+	}
+	for _, d := range diagnostics {
+		fmt.Fprintf(stderr, "warning: %s\n", d)
+	}
+
+	if *output == "-" {
+		return d.render(stdout, page, "")
+	}
+
+	// The output file is removed when rendering fails
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := d.render(file, page, filepath.Ext(*output)); err != nil {
+		file.Close()
+		os.Remove(*output)
+		return err
+	}
+	return file.Close()
+}
+
+// loadFonts loads every .ttf font of dir, named after its file without extension. The
+// files are read again every time, so preview picks up the changes of a font
+func loadFonts(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.ttf"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no .ttf fonts found in %s", dir)
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if err := sahar.ReloadFonts(name, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readData reads the data of the template, - reads stdin. YAML is a superset of JSON,
+// so both are read the same way
+func readData(path string, stdin io.Reader) (any, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	reader := stdin
+	name := "standard input"
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader, name = file, path
+	}
+
+	var data any
+	if err := yaml.NewDecoder(reader).Decode(&data); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid data in %s: %w", name, err)
+	}
+	return data, nil
+}
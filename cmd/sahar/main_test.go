@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testTemplate = `
+type: box
+width: 300
+height: 200
+direction: topToBottom
+children:
+  - type: text
+    text: "Invoice for {{.customer}}"
+    fontType: Arial
+  - type: text
+    repeat: "{{.items}}"
+    text: '{{.name}}: {{currency "$" .price}}'
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	template := writeFile(t, dir, "invoice.yaml", testTemplate)
+	unknownFont := writeFile(t, dir, "unknown.yaml", strings.Replace(testTemplate, "Arial", "Unknown", 1))
+	data := writeFile(t, dir, "data.json", `{"customer": "Ada", "items": [{"name": "Pen", "price": 2.5}]}`)
+
+	t.Run("renders to a file", func(t *testing.T) {
+		output := filepath.Join(dir, "invoice.pdf")
+		var stdout, stderr bytes.Buffer
+
+		err := run([]string{"render", "-data", data, "-fonts", "../../examples/basic", "-o", output, template}, nil, &stdout, &stderr)
+		if err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, stderr.String())
+		}
+
+		pdf, err := os.ReadFile(output)
+		if err != nil || !bytes.HasPrefix(pdf, []byte("%PDF")) {
+			t.Errorf("expected a PDF file, got %v", err)
+		}
+	})
+
+	t.Run("renders to an image", func(t *testing.T) {
+		output := filepath.Join(dir, "invoice.png")
+		var stdout, stderr bytes.Buffer
+
+		err := run([]string{"render", "-data", data, "-dpi", "144", "-o", output, template}, nil, &stdout, &stderr)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		file, err := os.Open(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if config, err := png.DecodeConfig(file); err != nil || config.Width != 600 {
+			t.Errorf("expected a PNG of 600 pixels wide, got %+v %v", config, err)
+		}
+	})
+
+	t.Run("renders to SVG", func(t *testing.T) {
+		output := filepath.Join(dir, "invoice.svg")
+		var stdout, stderr bytes.Buffer
+
+		if err := run([]string{"render", "-data", data, "-o", output, template}, nil, &stdout, &stderr); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if svg, err := os.ReadFile(output); err != nil || !bytes.Contains(svg, []byte("Invoice for Ada")) {
+			t.Errorf("expected an SVG with the text, got %v", err)
+		}
+	})
+
+	t.Run("renders the debug overlay to SVG", func(t *testing.T) {
+		output := filepath.Join(dir, "debug.svg")
+		var stdout, stderr bytes.Buffer
+
+		if err := run([]string{"render", "-debug", "-data", data, "-o", output, template}, nil, &stdout, &stderr); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if svg, err := os.ReadFile(output); err != nil || !bytes.Contains(svg, []byte(`stroke-width="0.5"`)) {
+			t.Errorf("expected an SVG with the overlay, got %v", err)
+		}
+	})
+
+	t.Run("reads data from standard input and writes to standard output", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+
+		err := run([]string{"render", "-data", "-", "-debug", unknownFont}, strings.NewReader("customer: Ada\nitems: []\n"), &stdout, &stderr)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.HasPrefix(stdout.Bytes(), []byte("%PDF")) {
+			t.Error("expected a PDF on standard output")
+		}
+		if !strings.Contains(stderr.String(), "warning:") {
+			t.Errorf("expected a warning for the unknown font, got %q", stderr.String())
+		}
+	})
+
+	t.Run("reports errors", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			args    []string
+			message string
+		}{
+			{"no command", nil, "expected a command"},
+			{"unknown command", []string{"draw"}, "unknown command"},
+			{"no template", []string{"render"}, "expected a single template"},
+			{"missing data field", []string{"render", template}, "$.children[0].text"},
+			{"invalid data", []string{"render", "-data", writeFile(t, dir, "bad.json", `{"customer": `), template}, "invalid data"},
+			{"empty font directory", []string{"render", "-fonts", t.TempDir(), template}, "no .ttf fonts"},
+			{"invalid template", []string{"render", writeFile(t, dir, "bad.yaml", "type: table")}, "$.type"},
+			{"layout problems", []string{"render", "-strict", "-data", data, unknownFont}, "font"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var stdout, stderr bytes.Buffer
+				err := run(tt.args, nil, &stdout, &stderr)
+				if err == nil || !strings.Contains(err.Error(), tt.message) {
+					t.Errorf("expected an error containing %q, got %v", tt.message, err)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often preview checks the files of the document for changes
+const pollInterval = 500 * time.Millisecond
+
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Template}} - sahar preview</title>
+<style>
+body { margin: 0; display: flex; height: 100vh; font-family: sans-serif; }
+iframe { flex: 1; border: 0; }
+aside { width: 360px; overflow: auto; padding: 0 16px; background: #F5F5F5; font-size: 13px; }
+pre { white-space: pre-wrap; color: #B00020; }
+li { margin-bottom: 8px; }
+img { width: 100%; border: 1px solid #CCCCCC; background: #FFFFFF; }
+</style>
+</head>
+<body>
+{{if .Rendered}}<iframe src="/document.pdf?version={{.Version}}"></iframe>{{end}}
+<aside>
+<h3>{{.Template}}</h3>
+{{if .Image}}<a href="/document.png" target="_blank"><img src="/document.png?version={{.Version}}" alt="PNG preview"></a>{{end}}
+{{with .Error}}<pre>{{.}}</pre>{{if $.Rendered}}<p>Showing the last successful render.</p>{{end}}{{end}}
+{{with .Diagnostics}}<h4>Layout diagnostics</h4><ul>{{range .}}<li>{{.}}</li>{{end}}</ul>{{else}}<p>No layout problems.</p>{{end}}
+</aside>
+<script>
+const version = {{.Version}};
+setInterval(async () => {
+  try {
+    const response = await fetch("/version");
+    if ((await response.text()) !== String(version)) location.reload();
+  } catch (e) {}
+}, 1000);
+</script>
+</body>
+</html>
+`))
+
+func preview(args []string, stderr io.Writer) error {
+	var d document
+	flags := flag.NewFlagSet("preview", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:8080", "address of the preview server")
+	if err := d.parse(flags, args, stderr); err != nil {
+		return err
+	}
+	if d.data == "-" {
+		return errors.New("preview needs a data file to watch instead of standard input")
+	}
+
+	p := &previewer{document: d}
+	p.refresh()
+	go func() {
+		for range time.Tick(pollInterval) {
+			p.refresh()
+		}
+	}()
+
+	fmt.Fprintf(stderr, "previewing %s at http://%s\n", d.template, *addr)
+	return http.ListenAndServe(*addr, p)
+}
+
+// previewer renders the document again when its files change and serves the last result
+type previewer struct {
+	document document
+
+	mu          sync.Mutex
+	stamp       string // The state of the files of the last render
+	version     int    // Incremented by every render, so the page knows when to reload
+	pdf         []byte // The last successful render
+	png         []byte // The image of the last successful render, nil when it can't be drawn
+	diagnostics []string
+	err         error
+}
+
+// files returns the state of the template, the data and the fonts, it changes when
+// one of them is written, added or removed
+func (p *previewer) files() string {
+	paths := []string{p.document.template}
+	if p.document.data != "" {
+		paths = append(paths, p.document.data)
+	}
+	for _, dir := range p.document.fonts {
+		fonts, _ := filepath.Glob(filepath.Join(dir, "*.ttf"))
+		paths = append(paths, fonts...)
+	}
+
+	var stamp strings.Builder
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(&stamp, "%s missing\n", path)
+			continue
+		}
+		fmt.Fprintf(&stamp, "%s %d %d\n", path, info.ModTime().UnixNano(), info.Size())
+	}
+	return stamp.String()
+}
+
+// refresh renders the document again when its files changed and reports whether it did.
+// The last successful render is kept when the document has errors
+func (p *previewer) refresh() bool {
+	stamp := p.files()
+
+	p.mu.Lock()
+	changed := stamp != p.stamp
+	p.mu.Unlock()
+	if !changed {
+		return false
+	}
+
+	var pdf, png bytes.Buffer
+	var diagnostics []string
+	page, found, err := p.document.build(nil)
+	if err == nil {
+		err = p.document.render(&pdf, page, "")
+	}
+	// The image is a convenience, it is left out when it can't be drawn
+	if err == nil && p.document.render(&png, page, ".png") != nil {
+		png.Reset()
+	}
+	for _, d := range found {
+		diagnostics = append(diagnostics, d.String())
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stamp = stamp
+	p.version++
+	p.err = err
+	p.diagnostics = diagnostics
+	if err == nil {
+		p.pdf = pdf.Bytes()
+		p.png = nil
+		if png.Len() > 0 {
+			p.png = png.Bytes()
+		}
+	}
+	return true
+}
+
+func (p *previewer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/":
+		data := struct {
+			Template    string
+			Version     int
+			Rendered    bool
+			Image       bool
+			Error       string
+			Diagnostics []string
+		}{
+			Template:    p.document.template,
+			Version:     p.version,
+			Rendered:    p.pdf != nil,
+			Image:       p.png != nil,
+			Diagnostics: p.diagnostics,
+		}
+		if p.err != nil {
+			data.Error = p.err.Error()
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := previewPage.Execute(w, data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	case "/document.pdf":
+		if p.pdf == nil {
+			http.Error(w, "the document has not been rendered yet", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(p.pdf)
+	case "/document.png":
+		if p.png == nil {
+			http.Error(w, "the document has no image", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(p.png)
+	case "/version":
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Cache-Control", "no-store")
+		io.WriteString(w, strconv.Itoa(p.version))
+	default:
+		http.NotFound(w, r)
+	}
+}
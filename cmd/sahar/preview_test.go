@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestPreview(t *testing.T) {
+	dir := t.TempDir()
+	template := writeFile(t, dir, "invoice.yaml", testTemplate)
+	data := writeFile(t, dir, "data.yaml", "customer: Ada\nitems: []\n")
+
+	p := &previewer{document: document{template: template, data: data, dpi: 72}}
+
+	get := func(t *testing.T, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		response := httptest.NewRecorder()
+		p.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+		return response
+	}
+
+	// touch writes the file with a new modification time, so the change is seen even
+	// when the file system has a coarse clock
+	touch := func(t *testing.T, path, content string) {
+		t.Helper()
+		writeFile(t, dir, filepath.Base(path), content)
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("renders the document", func(t *testing.T) {
+		if !p.refresh() {
+			t.Fatal("expected a first render")
+		}
+		if p.refresh() {
+			t.Error("expected no render when nothing changed")
+		}
+
+		if pdf := get(t, "/document.pdf"); pdf.Code != http.StatusOK || !strings.HasPrefix(pdf.Body.String(), "%PDF") {
+			t.Errorf("expected the PDF, got %d", pdf.Code)
+		}
+		if image := get(t, "/document.png"); image.Code != http.StatusOK || image.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("expected the PNG, got %d", image.Code)
+		}
+		if version := get(t, "/version").Body.String(); version != "1" {
+			t.Errorf("expected version 1, got %q", version)
+		}
+
+		page := get(t, "/").Body.String()
+		if !strings.Contains(page, "/document.pdf?version=1") || !strings.Contains(page, "invoice.yaml") {
+			t.Errorf("expected the page to show the PDF of the template, got %s", page)
+		}
+	})
+
+	t.Run("renders again when the data changes", func(t *testing.T) {
+		touch(t, data, "customer: Grace\nitems: [{name: Pen, price: 2}]\n")
+
+		if !p.refresh() {
+			t.Fatal("expected a render after the change")
+		}
+		if version := get(t, "/version").Body.String(); version != "2" {
+			t.Errorf("expected version 2, got %q", version)
+		}
+	})
+
+	t.Run("shows errors and keeps the last render", func(t *testing.T) {
+		touch(t, template, `{"type": "text", "text": "{{.missing}}"}`)
+
+		if !p.refresh() {
+			t.Fatal("expected a render after the change")
+		}
+
+		page := get(t, "/").Body.String()
+		if !strings.Contains(page, "$.text") || !strings.Contains(page, "last successful render") {
+			t.Errorf("expected the error on the page, got %s", page)
+		}
+		if pdf := get(t, "/document.pdf"); pdf.Code != http.StatusOK {
+			t.Errorf("expected the last PDF to be served, got %d", pdf.Code)
+		}
+	})
+
+	t.Run("rejects data from standard input", func(t *testing.T) {
+		if err := run([]string{"preview", "-data", "-", template}, nil, nil, &strings.Builder{}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestPreviewFonts(t *testing.T) {
+	dir := t.TempDir()
+	fonts := filepath.Join(dir, "fonts")
+	if err := os.Mkdir(fonts, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	font := filepath.Join(fonts, "PreviewFont.ttf")
+	if err := os.WriteFile(font, goregular.TTF, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	template := writeFile(t, dir, "invoice.yaml", strings.Replace(testTemplate, "fontType: Arial", "fontType: PreviewFont\n    fontSize: 12", 1))
+	data := writeFile(t, dir, "data.yaml", "customer: Ada\nitems: []\n")
+
+	p := &previewer{document: document{template: template, data: data, fonts: dirs{fonts}, dpi: 72}}
+	if !p.refresh() || p.err != nil {
+		t.Fatalf("expected a first render, got %v", p.err)
+	}
+	before := p.png
+
+	if err := os.WriteFile(font, gomono.TTF, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(font, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if !p.refresh() || p.err != nil {
+		t.Fatalf("expected a render after the font changed, got %v", p.err)
+	}
+	if bytes.Equal(p.png, before) {
+		t.Error("expected the text to be drawn with the changed font")
+	}
+}
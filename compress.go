@@ -0,0 +1,235 @@
+package sahar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"codeberg.org/go-pdf/fpdf"
+	xdraw "golang.org/x/image/draw"
+)
+
+// PDFSize is the size of a rendered PDF by part, see SizeReport
+type PDFSize struct {
+	Total   int // The size of the file, in bytes
+	Content int // The content streams of the pages
+	Images  int // The images, with their transparency masks
+	Fonts   int // The embedded fonts, with their descriptors and encodings
+	Other   int // Everything else: pages, links, attachments, forms and the cross-reference table
+
+	ImagesWritten     int // The images written once, whatever the number of times they are drawn
+	ImagesReused      int // The drawings of an image which was already written
+	ImagesDownsampled int // The images written at a lower resolution than their file, see MaxImageDPI
+}
+
+// SizeReport makes RenderPDF fill report with the size of the document once it is
+// written, to find out what makes a document large
+func SizeReport(report *PDFSize) RenderOption {
+	return renderOptFunc(func(c *renderConfig) {
+		c.size = report
+	})
+}
+
+// Uncompressed makes RenderPDF write the content of the pages uncompressed, to read
+// the drawing operators in a text editor. Images and fonts stay compressed
+func Uncompressed() RenderOption {
+	return renderOptFunc(func(c *renderConfig) {
+		c.uncompressed = true
+	})
+}
+
+// MaxImageDPI makes RenderPDF downsample the images with more pixels per inch than
+// dpi at the size they are drawn. JPEG images are written again as JPEG, with the
+// quality of JPEGQuality, and other images as PNG. Images which are not downsampled
+// keep the bytes of their file
+func MaxImageDPI(dpi float64) RenderOption {
+	return renderOptFunc(func(c *renderConfig) {
+		c.maxDPI = dpi
+	})
+}
+
+// FullFonts makes RenderPDF embed every glyph of the fonts embedded with EmbedFonts or
+// PDF/A, so the document can be edited with them. By default they are subset to the
+// glyphs of the text, which is much smaller
+func FullFonts() RenderOption {
+	return renderOptFunc(func(c *renderConfig) {
+		c.fullFonts = true
+	})
+}
+
+// checkCompression reports a JPEG quality out of range and a negative resolution
+func checkCompression(config renderConfig) error {
+	if config.quality != 0 && (config.quality < 1 || config.quality > 100) {
+		return fmt.Errorf("JPEG quality must be between 1 and 100, got %d", config.quality)
+	}
+	if config.maxDPI < 0 {
+		return fmt.Errorf("the maximum image resolution must be positive, got %g", config.maxDPI)
+	}
+	return nil
+}
+
+// prepareImage registers the image drawn in rect and returns its name. Images are named
+// after their content, so the same image in several files is written once, and only
+// the images too dense for maxDPI, or JPEG images with a quality, are written again;
+// the others keep the bytes of their file
+func (c *pdfCanvas) prepareImage(src string, rect Rect) (string, string, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read image file: %w", err)
+	}
+	imageType, err := imageTypeOf(data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to detect image type: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:12])
+
+	var width int
+	var config image.Config
+	recompress := false
+	if c.maxDPI > 0 || (c.quality > 0 && imageType == "JPG") {
+		if config, _, err = image.DecodeConfig(bytes.NewReader(data)); err != nil {
+			return "", "", fmt.Errorf("failed to read image %q: %w", src, err)
+		}
+		width = config.Width
+		if pixels := int(math.Ceil(rect.Width / 72 * c.maxDPI)); c.maxDPI > 0 && pixels > 0 && pixels < width {
+			width = pixels
+		}
+		recompress = width < config.Width || (c.quality > 0 && imageType == "JPG")
+		if recompress {
+			name = fmt.Sprintf("%s-%d-%d", name, width, c.quality)
+		}
+	}
+
+	if _, ok := c.images[name]; ok {
+		c.reused++
+		return name, imageType, nil
+	}
+	if recompress {
+		if data, imageType, err = recompressImage(data, imageType, width, c.quality); err != nil {
+			return "", "", fmt.Errorf("failed to recompress image %q: %w", src, err)
+		}
+	}
+
+	if c.images == nil {
+		c.images = map[string]bool{}
+	}
+	c.images[name] = width < config.Width
+	c.pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(data))
+	return name, imageType, c.pdf.Error()
+}
+
+// recompressImage scales an image down to width pixels, JPEG images are encoded with
+// quality, the default one when zero, and other images as PNG. A JPEG image which is
+// not scaled is kept when encoding it again makes it larger
+func recompressImage(data []byte, imageType string, width, quality int) ([]byte, string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	bounds := img.Bounds()
+	if width < bounds.Dx() {
+		height := max(int(math.Round(float64(bounds.Dy())*float64(width)/float64(bounds.Dx()))), 1)
+		scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+		xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), img, bounds, xdraw.Src, nil)
+		img = scaled
+	}
+
+	var buf bytes.Buffer
+	if imageType != "JPG" {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "PNG", nil
+	}
+	if quality == 0 {
+		quality = jpeg.DefaultQuality
+	}
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, "", err
+	}
+	if width >= bounds.Dx() && buf.Len() >= len(data) {
+		return data, "JPG", nil
+	}
+	return buf.Bytes(), "JPG", nil
+}
+
+var (
+	// pdfContents matches the content streams of a page
+	pdfContents = regexp.MustCompile(`/Contents (\d+) 0 R`)
+	// pdfFontParts matches the objects a font refers to
+	pdfFontParts = regexp.MustCompile(`/(?:FontFile\d?|ToUnicode|CIDToGIDMap|Widths|FontDescriptor|Encoding) (\d+) 0 R`)
+)
+
+// measurePDF returns the size of every part of a document
+func measurePDF(data []byte) (PDFSize, error) {
+	f, err := parsePDF(data)
+	if err != nil {
+		return PDFSize{}, err
+	}
+
+	parts := map[int]*int{}
+	size := PDFSize{Total: len(data)}
+	for _, page := range f.pages() {
+		for _, match := range pdfContents.FindAllSubmatch(f.objects[page], -1) {
+			n, _ := strconv.Atoi(string(match[1]))
+			parts[n] = &size.Content
+		}
+	}
+	for _, n := range f.order {
+		part := pdfPart(f.objects[n], &size)
+		if part == nil {
+			continue
+		}
+		parts[n] = part
+		if part == &size.Fonts {
+			for _, match := range pdfFontParts.FindAllSubmatch(f.objects[n], -1) {
+				ref, _ := strconv.Atoi(string(match[1]))
+				parts[ref] = &size.Fonts
+			}
+		}
+	}
+
+	size.Other = size.Total
+	for n, part := range parts {
+		if n < len(f.objects) {
+			*part += len(f.objects[n])
+			size.Other -= len(f.objects[n])
+		}
+	}
+	return size, nil
+}
+
+// useAllGlyphs marks every character of a loaded font as used, so fpdf doesn't leave
+// glyphs out of it. The characters are written on a template which is never drawn,
+// the template shares the fonts of the document
+func (c *pdfCanvas) useAllGlyphs(name string) {
+	font := loadedFont(name)
+	if font == nil {
+		return
+	}
+
+	// fpdf reads the characters of the Basic Multilingual Plane only
+	var text strings.Builder
+	for r := rune(0x20); r <= 0xffff; r++ {
+		if utf8.ValidRune(r) && font.Index(r) != 0 {
+			text.WriteRune(r)
+		}
+	}
+	c.pdf.CreateTemplate(func(tpl *fpdf.Tpl) {
+		tpl.SetFont(name, "", 1)
+		tpl.Text(0, 0, text.String())
+	})
+}
@@ -0,0 +1,213 @@
+package sahar
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	dir := t.TempDir()
+	noise := image.NewNRGBA(image.Rect(0, 0, 400, 400))
+	random := rand.New(rand.NewSource(1))
+	for i := range noise.Pix {
+		noise.Pix[i] = byte(random.Intn(256))
+	}
+	for i := 3; i < len(noise.Pix); i += 4 {
+		noise.Pix[i] = 255
+	}
+
+	write := func(name string, encode func(*os.File) error) string {
+		path := filepath.Join(dir, name)
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if err := encode(file); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	photo := write("photo.png", func(f *os.File) error { return png.Encode(f, noise) })
+	copied := write("copy.png", func(f *os.File) error { return png.Encode(f, noise) })
+	scan := write("scan.jpg", func(f *os.File) error { return jpeg.Encode(f, noise, &jpeg.Options{Quality: 100}) })
+
+	render := func(t *testing.T, root *Node, opts ...RenderOption) ([]byte, PDFSize) {
+		t.Helper()
+		var size PDFSize
+		var buf bytes.Buffer
+		if err := RenderPDF(&buf, append([]RenderOption{root, SizeReport(&size)}, opts...)...); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return buf.Bytes(), size
+	}
+	images := func(paths ...string) *Node {
+		var children []*Node
+		for _, path := range paths {
+			children = append(children, Image(path, Sizing(Fixed(72), Fixed(72))))
+		}
+		return Layout(Box(Sizing(Fixed(400), Fixed(100)), Children(children...)))
+	}
+
+	t.Run("writes identical images once", func(t *testing.T) {
+		data, size := render(t, images(photo, copied, photo))
+		if n := strings.Count(string(data), "/Subtype /Image"); n != 1 {
+			t.Errorf("expected a single image, got %d", n)
+		}
+		if size.ImagesWritten != 1 || size.ImagesReused != 2 || size.ImagesDownsampled != 0 {
+			t.Errorf("unexpected image counts %+v", size)
+		}
+	})
+
+	t.Run("downsamples dense images", func(t *testing.T) {
+		original, before := render(t, images(photo))
+		data, after := render(t, images(photo), MaxImageDPI(100))
+		checkXref(t, data)
+		if !strings.Contains(string(original), "/Width 400") || !strings.Contains(string(data), "/Width 100") || !strings.Contains(string(data), "/Height 100") {
+			t.Error("expected the image to be downsampled to 100 pixels")
+		}
+		if after.ImagesDownsampled != 1 || after.Images*4 > before.Images {
+			t.Errorf("expected a smaller image, got %d bytes instead of %d", after.Images, before.Images)
+		}
+
+		_, size := render(t, images(photo), MaxImageDPI(600))
+		if size.ImagesDownsampled != 0 || size.Images != before.Images {
+			t.Error("expected images below the resolution to be kept")
+		}
+	})
+
+	t.Run("recompresses JPEG images", func(t *testing.T) {
+		_, before := render(t, images(scan))
+		_, after := render(t, images(scan), JPEGQuality(20))
+		if after.Images >= before.Images {
+			t.Errorf("expected a smaller image, got %d bytes instead of %d", after.Images, before.Images)
+		}
+
+		// Without a quality, a JPEG image which is not downsampled keeps its bytes
+		original, err := os.ReadFile(scan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, kept := render(t, images(scan), MaxImageDPI(600))
+		if kept.Images != before.Images || kept.ImagesDownsampled != 0 || !bytes.Contains(data, original) {
+			t.Errorf("expected the bytes of the file, got %d bytes instead of %d", kept.Images, before.Images)
+		}
+	})
+
+	t.Run("writes uncompressed content", func(t *testing.T) {
+		root := Layout(Box(Sizing(Fixed(100), Fixed(100)), Children(Text("Total", FontType("Helvetica"), FontSize(10)))))
+		data, _ := render(t, root, Uncompressed())
+		if !strings.Contains(string(data), "(Total) Tj") {
+			t.Error("expected the text in the content of the page")
+		}
+		if data, _ := render(t, root); strings.Contains(string(data), "(Total) Tj") {
+			t.Error("expected the content compressed by default")
+		}
+	})
+
+	t.Run("reports the size of every part", func(t *testing.T) {
+		if err := LoadFonts("CompressionArial", "./examples/basic/Arial.ttf"); err != nil {
+			t.Fatal(err)
+		}
+		root := Layout(Box(
+			Sizing(Fixed(400), Fixed(100)),
+			Children(Text("Total", FontType("CompressionArial"), FontSize(10)), Image(photo, Sizing(Fixed(72), Fixed(72)))),
+		))
+		data, size := render(t, root, EmbedFonts())
+
+		if size.Total != len(data) || size.Content+size.Images+size.Fonts+size.Other != size.Total {
+			t.Errorf("expected the parts to add up to the file, got %+v for %d bytes", size, len(data))
+		}
+		if size.Content == 0 || size.Images == 0 || size.Fonts == 0 || size.Other == 0 {
+			t.Errorf("expected every part to be measured, got %+v", size)
+		}
+		// The font is subset to the glyphs of the text
+		if size.Fonts > 100_000 {
+			t.Errorf("expected a subset font, got %d bytes", size.Fonts)
+		}
+	})
+
+	t.Run("embeds every glyph of the fonts with FullFonts", func(t *testing.T) {
+		if err := LoadFonts("CompressionArial", "./examples/basic/Arial.ttf"); err != nil {
+			t.Fatal(err)
+		}
+		root := Layout(Box(Sizing(Fixed(400), Fixed(100)), Children(Text("Total", FontType("CompressionArial"), FontSize(10)))))
+
+		_, subset := render(t, root, EmbedFonts())
+		data, full := render(t, root, EmbedFonts(), FullFonts())
+		if full.Fonts < 4*subset.Fonts {
+			t.Errorf("expected the whole font to be much larger than the subset, got %d and %d bytes", full.Fonts, subset.Fonts)
+		}
+		// The characters used to embed the glyphs are not drawn
+		if full.Content != subset.Content || bytes.Count(data, []byte("/Type /Page\n")) != 1 {
+			t.Errorf("expected the same content, got %d and %d bytes", full.Content, subset.Content)
+		}
+	})
+
+	t.Run("is set by PDFOptions", func(t *testing.T) {
+		var size PDFSize
+		options := DefaultPDFOptions()
+		options.MaxImageDPI = 100
+		options.SizeReport = &size
+
+		var buf bytes.Buffer
+		if err := RenderToPDFWithOptions(images(photo), &buf, options); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if size.Total != buf.Len() || size.ImagesDownsampled != 1 {
+			t.Errorf("unexpected size %+v", size)
+		}
+	})
+
+	t.Run("reports invalid options", func(t *testing.T) {
+		tests := []struct {
+			opt RenderOption
+			err string
+		}{
+			{JPEGQuality(101), "JPEG quality must be between 1 and 100, got 101"},
+			{MaxImageDPI(-1), "the maximum image resolution must be positive, got -1"},
+		}
+		for _, tt := range tests {
+			err := RenderPDF(&bytes.Buffer{}, images(), tt.opt)
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("expected %q, got %v", tt.err, err)
+			}
+		}
+	})
+}
+
+func TestRecompressImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			img.Set(x, y, color.NRGBA{R: 255, A: 128})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	data, imageType, err := recompressImage(buf.Bytes(), "PNG", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scaled, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imageType != "PNG" || scaled.Bounds().Dx() != 10 || scaled.Bounds().Dy() != 5 {
+		t.Errorf("unexpected %s image of %v", imageType, scaled.Bounds())
+	}
+	if _, _, _, a := scaled.At(5, 2).RGBA(); a>>8 != 128 {
+		t.Errorf("expected the transparency to be kept, got %d", a>>8)
+	}
+}
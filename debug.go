@@ -0,0 +1,162 @@
+package sahar
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	debugLineWidth = 0.5
+	debugLabelSize = 5
+	debugAlpha     = 0.2
+)
+
+// debugColors is the color used for each node type in the debug overlay
+var debugColors = map[Type]Color{
+	BoxType:   {0x1e, 0x88, 0xe5},
+	TextType:  {0x43, 0xa0, 0x47},
+	ImageType: {0xfb, 0x8c, 0x00},
+	GridType:  {0x8e, 0x24, 0xaa},
+}
+
+// debugOverlay draws the overlay of a page on top of it, as an artifact of tagged documents
+func (r *canvasRenderer) debugOverlay(page *Node) error {
+	if !r.tagged {
+		return r.debugNode(page)
+	}
+	if err := r.beginTag(RoleArtifact, ""); err != nil {
+		return err
+	}
+	if err := r.debugNode(page); err != nil {
+		return err
+	}
+	return r.endTag()
+}
+
+// debugNode draws the layout of the node and its children
+func (r *canvasRenderer) debugNode(node *Node) error {
+	color := debugColors[node.Type]
+
+	// Shaded padding and gaps
+	for _, rect := range append(debugPaddingRects(node), debugGapRects(node)...) {
+		if rect.Width > 0 && rect.Height > 0 {
+			if err := r.canvas.Rect(rect, Style{Fill: &color, Opacity: debugAlpha}); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Outline of the border box
+	if err := r.canvas.Rect(nodeRect(node), Style{Stroke: &color, StrokeWidth: debugLineWidth}); err != nil {
+		return err
+	}
+
+	// Baselines of the text lines
+	if node.Type == TextType && node.Value != "" {
+		for _, baseline := range debugBaselines(node) {
+			line := []Point{{node.Position.X, baseline}, {node.Position.X + node.Width.Value, baseline}}
+			if err := r.canvas.Path(line, false, Style{Stroke: &color, StrokeWidth: debugLineWidth / 2}); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Label with the calculated size
+	label := debugLabel(node)
+	font := Font{Type: "Arial", Size: debugLabelSize}
+	err := r.canvas.Text(TextRun{
+		Text:  label,
+		X:     node.Position.X + 1,
+		Y:     node.Position.Y + debugLabelSize,
+		Width: r.canvas.MeasureText(label, font),
+		Font:  font,
+		Color: color,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, child := range node.Children {
+		if err := r.debugNode(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// debugLabel returns the label of the node, for example "Box 120.00x40.00"
+func debugLabel(node *Node) string {
+	return fmt.Sprintf("%s %.2fx%.2f", node.Type, node.Width.Value, node.Height.Value)
+}
+
+// debugPaddingRects returns the four padding areas of the node
+func debugPaddingRects(node *Node) []Rect {
+	x, y := node.Position.X, node.Position.Y
+	width, height := node.Width.Value, node.Height.Value
+	top, right, bottom, left := node.Padding[0], node.Padding[1], node.Padding[2], node.Padding[3]
+
+	return []Rect{
+		{x, y, width, top},
+		{x, y + height - bottom, width, bottom},
+		{x, y + top, left, height - top - bottom},
+		{x + width - right, y + top, right, height - top - bottom},
+	}
+}
+
+// debugGapRects returns the areas between the children of the node
+func debugGapRects(node *Node) []Rect {
+	content := getContentArea(node)
+	var rects []Rect
+
+	if node.Type == GridType {
+		var offset float64
+		for i := 0; i < len(node.gridColumns)-1; i++ {
+			offset += node.gridColumns[i]
+			rects = append(rects, Rect{content.x + offset, content.y, node.ColumnGap, content.height})
+			offset += node.ColumnGap
+		}
+		offset = 0
+		for i := 0; i < len(node.gridRows)-1; i++ {
+			offset += node.gridRows[i]
+			rects = append(rects, Rect{content.x, content.y + offset, content.width, node.RowGap})
+			offset += node.RowGap
+		}
+		return rects
+	}
+
+	if node.ChildGap <= 0 {
+		return nil
+	}
+
+	for i := 0; i < len(node.Children)-1; i++ {
+		child := node.Children[i]
+		if node.Direction == LeftToRight {
+			x := child.Position.X + child.Width.Value + child.Margin[1]
+			rects = append(rects, Rect{x, content.y, node.ChildGap, content.height})
+		} else {
+			y := child.Position.Y + child.Height.Value + child.Margin[2]
+			rects = append(rects, Rect{content.x, y, content.width, node.ChildGap})
+		}
+	}
+
+	return rects
+}
+
+// debugBaselines returns the Y position of the baseline of every line of a text node,
+// using the same metrics as canvasRenderer.text
+func debugBaselines(node *Node) []float64 {
+	fontSize := node.FontSize
+	if node.FontType == "" || fontSize <= 0 {
+		fontSize = 12
+	}
+
+	lines := strings.Split(node.Value, "\n")
+	lineSpacing := fontSize * 1.2
+	startY := calculateVerticalPosition(node, lines, lineSpacing, fontSize*0.75, fontSize)
+
+	baselines := make([]float64, len(lines))
+	for i := range lines {
+		baselines[i] = startY + float64(i)*lineSpacing
+	}
+	return baselines
+}
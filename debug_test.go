@@ -0,0 +1,97 @@
+package sahar
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDebugOverlay(t *testing.T) {
+	newDocument := func() *Node {
+		return Layout(Box(
+			Sizing(Fixed(300), Fixed(200)),
+			Padding(10, 10, 10, 10),
+			ChildGap(5),
+			Children(
+				Box(Sizing(Fixed(40)), Children(Text("Hello World", FontSize(12)))),
+				Grid(
+					Columns(FitTrack(), FitTrack()),
+					ColumnGap(4),
+					Children(Box(Sizing(Fixed(20), Fixed(20))), Box(Sizing(Fixed(20), Fixed(20)))),
+				),
+			),
+		))
+	}
+
+	t.Run("draws on top of the pages", func(t *testing.T) {
+		var plain, debug bytes.Buffer
+		if err := RenderToPDF(&plain, newDocument()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := RenderPDF(&debug, newDocument(), DebugOverlay()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !bytes.HasPrefix(debug.Bytes(), []byte("%PDF")) {
+			t.Error("output does not appear to be a valid PDF")
+		}
+		if debug.Len() <= plain.Len() {
+			t.Errorf("expected the overlay to add content, got %d and %d bytes", debug.Len(), plain.Len())
+		}
+	})
+
+	t.Run("options can be passed in any position", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := RenderPDF(&buf, DebugOverlay(), newDocument(), newDocument()); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("is enabled with the PDF options", func(t *testing.T) {
+		opts := DefaultPDFOptions()
+		opts.Debug = true
+
+		var buf bytes.Buffer
+		if err := RenderToPDFWithOptions(newDocument(), &buf, opts); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("reports padding and gap areas", func(t *testing.T) {
+		root := newDocument()
+
+		padding := debugPaddingRects(root)
+		if padding[0] != (Rect{0, 0, 300, 10}) || padding[3] != (Rect{290, 10, 10, 180}) {
+			t.Errorf("unexpected padding areas: %v", padding)
+		}
+
+		first := root.Children[0]
+		gaps := debugGapRects(root)
+		if len(gaps) != 1 || gaps[0] != (Rect{first.Position.X + first.Width.Value, 10, 5, 180}) {
+			t.Errorf("unexpected gap areas: %v", gaps)
+		}
+
+		grid := root.Children[1]
+		gaps = debugGapRects(grid)
+		if len(gaps) != 1 || gaps[0] != (Rect{grid.Position.X + 20, grid.Position.Y, 4, 20}) {
+			t.Errorf("unexpected grid gap areas: %v", gaps)
+		}
+	})
+
+	t.Run("reports a baseline per text line", func(t *testing.T) {
+		text := newDocument().Children[0].Children[0]
+
+		baselines := debugBaselines(text)
+		if len(baselines) != 2 {
+			t.Fatalf("expected 2 baselines, got %d", len(baselines))
+		}
+		if baselines[0] != text.Position.Y+9 || baselines[1] != baselines[0]+12*1.2 {
+			t.Errorf("unexpected baselines: %v", baselines)
+		}
+	})
+
+	t.Run("labels nodes with their size", func(t *testing.T) {
+		if label := debugLabel(newDocument()); label != "Box 300.00x200.00" {
+			t.Errorf("unexpected label %q", label)
+		}
+	})
+}
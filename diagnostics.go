@@ -0,0 +1,131 @@
+package sahar
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DiagnosticKind represents the kind of problem found in a laid out node tree.
+type DiagnosticKind int
+
+const (
+	// ChildOverflow is reported when the children of a node do not fit in its content area.
+	ChildOverflow DiagnosticKind = iota
+	// TextOverflow is reported when a text line is wider, or the text is taller, than its node.
+	TextOverflow
+	// ZeroGrowSpace is reported when a Grow node was given no space by its parent.
+	ZeroGrowSpace
+	// MissingFont is reported when a text uses a font that is not loaded, so it is
+	// measured with an approximation and the rendered text may not match the layout.
+	MissingFont
+)
+
+func (k DiagnosticKind) String() string {
+	switch k {
+	case ChildOverflow:
+		return "child overflow"
+	case TextOverflow:
+		return "text overflow"
+	case ZeroGrowSpace:
+		return "zero grow space"
+	case MissingFont:
+		return "missing font"
+	default:
+		return fmt.Sprintf("DiagnosticKind(%d)", int(k))
+	}
+}
+
+// Diagnostic describes a single problem found in a laid out node tree.
+type Diagnostic struct {
+	Kind    DiagnosticKind
+	Pass    string // The layout pass which produced the problem
+	Path    string // The node type and child index of every node from the root, for example Box/Grid[1]/Text[0]
+	Node    *Node
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s (%s): %s", d.Path, d.Kind, d.Pass, d.Message)
+}
+
+// Diagnostics is the list of problems found in a laid out node tree.
+type Diagnostics []Diagnostic
+
+// Err returns nil if there are no diagnostics, otherwise an error listing all of them
+func (d Diagnostics) Err() error {
+	if len(d) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(d))
+	for i, diagnostic := range d {
+		errs[i] = errors.New(diagnostic.String())
+	}
+	return errors.Join(errs...)
+}
+
+// LayoutWithDiagnostics performs Layout on the node tree and reports the problems found
+// in the result, such as children overflowing their parent, text wider than its box, grow
+// nodes without any space and fonts that are not loaded
+func LayoutWithDiagnostics(root *Node) (*Node, Diagnostics) {
+	if Layout(root) == nil {
+		return nil, nil
+	}
+
+	var diagnostics Diagnostics
+	diagnose(root, root.Type.String(), &diagnostics)
+	return root, diagnostics
+}
+
+// diagnose checks the node and its children and appends the problems found
+func diagnose(node *Node, path string, diagnostics *Diagnostics) {
+	report := func(kind DiagnosticKind, pass, format string, args ...any) {
+		*diagnostics = append(*diagnostics, Diagnostic{
+			Kind:    kind,
+			Pass:    pass,
+			Path:    path,
+			Node:    node,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if node.Parent != nil {
+		if node.Width.Type == GrowType && node.Width.Value <= 0 {
+			report(ZeroGrowSpace, "grow widths", "grow width was given no space by the parent")
+		}
+		if node.Height.Type == GrowType && node.Height.Value <= 0 {
+			report(ZeroGrowSpace, "grow heights", "grow height was given no space by the parent")
+		}
+	}
+
+	overflowWidth, overflowHeight := node.Overflow()
+	if overflowWidth > layoutEpsilon {
+		report(ChildOverflow, "shrink widths", "children overflow the content width by %.2fpt", overflowWidth)
+	}
+	if overflowHeight > layoutEpsilon {
+		report(ChildOverflow, "shrink heights", "children overflow the content height by %.2fpt", overflowHeight)
+	}
+
+	if node.Type == TextType && node.Value != "" {
+		if loadedFont(node.FontType) == nil {
+			report(MissingFont, "fit widths", "font %q is not loaded, text is measured with an approximation", node.FontType)
+		}
+
+		contentWidth := node.Width.Value - node.Padding[1] - node.Padding[3]
+		for _, line := range strings.Split(node.Value, "\n") {
+			if lineWidth := measureTextWidth(line, node.FontSize, node.FontType); lineWidth-contentWidth > layoutEpsilon {
+				report(TextOverflow, "wrap text", "line %q is %.2fpt wider than the text box", line, lineWidth-contentWidth)
+			}
+		}
+
+		contentHeight := node.Height.Value - node.Padding[0] - node.Padding[2]
+		if textHeight := measureTextHeight(node.Value, node.FontSize, node.FontType); textHeight-contentHeight > layoutEpsilon {
+			report(TextOverflow, "fit heights", "text is %.2fpt taller than the text box", textHeight-contentHeight)
+		}
+	}
+
+	for i, child := range node.Children {
+		diagnose(child, fmt.Sprintf("%s/%s[%d]", path, child.Type, i), diagnostics)
+	}
+}
@@ -0,0 +1,119 @@
+package sahar
+
+import (
+	"strings"
+	"testing"
+)
+
+func findDiagnostic(diagnostics Diagnostics, kind DiagnosticKind) (Diagnostic, bool) {
+	for _, d := range diagnostics {
+		if d.Kind == kind {
+			return d, true
+		}
+	}
+	return Diagnostic{}, false
+}
+
+func TestLayoutWithDiagnostics(t *testing.T) {
+	t.Run("returns nil for nil input", func(t *testing.T) {
+		root, diagnostics := LayoutWithDiagnostics(nil)
+		if root != nil || diagnostics != nil {
+			t.Error("expected nil results for nil input")
+		}
+	})
+
+	t.Run("valid layout has no diagnostics", func(t *testing.T) {
+		root := Box(
+			Sizing(Fixed(200), Fixed(100)),
+			Children(
+				Box(Sizing(Grow(), Fixed(20))),
+				Box(Sizing(Fixed(50), Fixed(20))),
+			),
+		)
+
+		_, diagnostics := LayoutWithDiagnostics(root)
+		if len(diagnostics) != 0 {
+			t.Errorf("expected no diagnostics, got %v", diagnostics)
+		}
+		if diagnostics.Err() != nil {
+			t.Errorf("expected nil error, got %v", diagnostics.Err())
+		}
+	})
+
+	t.Run("reports children overflowing the parent", func(t *testing.T) {
+		root := Box(
+			Sizing(Fixed(100), Fixed(100)),
+			Children(
+				Box(Sizing(Fixed(80), Fixed(20))),
+				Box(Sizing(Fixed(80), Fixed(20))),
+			),
+		)
+
+		_, diagnostics := LayoutWithDiagnostics(root)
+		d, ok := findDiagnostic(diagnostics, ChildOverflow)
+		if !ok {
+			t.Fatalf("expected child overflow diagnostic, got %v", diagnostics)
+		}
+		if d.Node != root || d.Path != "Box" || d.Pass != "shrink widths" {
+			t.Errorf("unexpected diagnostic: %v", d)
+		}
+		if !strings.Contains(d.Message, "60.00pt") {
+			t.Errorf("expected message to contain the overflow, got %q", d.Message)
+		}
+	})
+
+	t.Run("reports text wider than its box", func(t *testing.T) {
+		text := Text("unbreakable", FontSize(12), Shrink(0))
+		root := Box(
+			Sizing(Fixed(50), Fixed(100)),
+			Direction(TopToBottom),
+			Children(Box(Sizing(Grow()), Children(text))),
+		)
+
+		_, diagnostics := LayoutWithDiagnostics(root)
+		if _, ok := findDiagnostic(diagnostics, ChildOverflow); !ok {
+			t.Errorf("expected child overflow diagnostic, got %v", diagnostics)
+		}
+
+		text.Width.Value = 10
+		diagnostics = nil
+		diagnose(text, "Text", &diagnostics)
+		d, ok := findDiagnostic(diagnostics, TextOverflow)
+		if !ok {
+			t.Fatalf("expected text overflow diagnostic, got %v", diagnostics)
+		}
+		if d.Pass != "wrap text" {
+			t.Errorf("expected pass to be wrap text, got %s", d.Pass)
+		}
+	})
+
+	t.Run("reports grow nodes without space", func(t *testing.T) {
+		grow := Box(Sizing(Grow(), Fixed(10)))
+		root := Box(Children(Box(Sizing(Fixed(10), Fixed(10))), grow))
+
+		_, diagnostics := LayoutWithDiagnostics(root)
+		d, ok := findDiagnostic(diagnostics, ZeroGrowSpace)
+		if !ok {
+			t.Fatalf("expected zero grow space diagnostic, got %v", diagnostics)
+		}
+		if d.Path != "Box/Box[1]" || d.Node != grow {
+			t.Errorf("unexpected diagnostic path %q", d.Path)
+		}
+	})
+
+	t.Run("reports missing fonts", func(t *testing.T) {
+		root := Box(Children(Text("Hello", FontType("NotLoaded"), FontSize(12))))
+
+		_, diagnostics := LayoutWithDiagnostics(root)
+		d, ok := findDiagnostic(diagnostics, MissingFont)
+		if !ok {
+			t.Fatalf("expected missing font diagnostic, got %v", diagnostics)
+		}
+		if d.Path != "Box/Text[0]" || !strings.Contains(d.Message, "NotLoaded") {
+			t.Errorf("unexpected diagnostic: %v", d)
+		}
+		if err := diagnostics.Err(); err == nil || !strings.Contains(err.Error(), "missing font") {
+			t.Errorf("expected error mentioning the missing font, got %v", err)
+		}
+	})
+}
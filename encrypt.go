@@ -0,0 +1,445 @@
+package sahar
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// Permission is what readers opening an encrypted document with the user password
+// may do, readers with the owner password may do everything. Readers are trusted to
+// respect them
+type Permission int
+
+const (
+	PermitPrint     Permission = 1 << iota // Print the document, in high quality
+	PermitCopy                             // Copy text and images, screen readers may always extract them
+	PermitModify                           // Change the content
+	PermitAnnotate                         // Add comments and fill forms
+	PermitFillForms                        // Fill forms, even without PermitAnnotate
+	PermitAssemble                         // Insert, rotate and delete pages
+
+	PermitAll = PermitPrint | PermitCopy | PermitModify | PermitAnnotate | PermitFillForms | PermitAssemble
+)
+
+// Encryption protects a PDF with passwords, with AES-256 as PDF 2.0 defines it, which
+// readers from Acrobat 9 on open. Pass it to RenderPDF like the pages, or set it in
+// PDFOptions
+type Encryption struct {
+	UserPassword  string // Needed to open the document, which opens without one when empty
+	OwnerPassword string // Gives every permission, a random one when empty, required with Deterministic
+	Permissions   Permission
+}
+
+var _ RenderOption = Encryption{}
+
+func (e Encryption) configureRender(c *renderConfig) {
+	c.encryption = &e
+}
+
+// maxPasswordLength is the length of the longest password, in bytes of UTF-8
+const maxPasswordLength = 127
+
+// checkEncryption reports passwords which are too long, unknown permissions,
+// encrypted PDF/A documents, which PDF/A forbids, and deterministic documents without
+// an owner password, whose random one would be derived from the user password
+func checkEncryption(config renderConfig) error {
+	e := config.encryption
+	if e == nil {
+		return nil
+	}
+	if config.pdfa != 0 {
+		return fmt.Errorf("invalid encryption: %s documents can't be encrypted", config.pdfa)
+	}
+	if config.deterministic && e.OwnerPassword == "" {
+		return errors.New("invalid encryption: deterministic documents need an OwnerPassword")
+	}
+	for _, password := range []string{e.UserPassword, e.OwnerPassword} {
+		if len(password) > maxPasswordLength {
+			return fmt.Errorf("invalid encryption: passwords are at most %d bytes long", maxPasswordLength)
+		}
+	}
+	if e.Permissions&^PermitAll != 0 {
+		return fmt.Errorf("invalid encryption: unknown permissions %#x", int(e.Permissions&^PermitAll))
+	}
+	return nil
+}
+
+// flags returns the permission flags of the standard security handler, as a signed
+// 32-bit integer. The reserved bits are set and screen readers may extract the text
+func (e Encryption) flags() int32 {
+	flags := uint32(0xFFFFF0C0) | 1<<9
+	for _, permission := range []struct {
+		permission Permission
+		bits       uint32
+	}{
+		{PermitPrint, 1<<2 | 1<<11},
+		{PermitModify, 1 << 3},
+		{PermitCopy, 1 << 4},
+		{PermitAnnotate, 1<<5 | 1<<8},
+		{PermitFillForms, 1 << 8},
+		{PermitAssemble, 1 << 10},
+	} {
+		if e.Permissions&permission.permission != 0 {
+			flags |= permission.bits
+		}
+	}
+	return int32(flags)
+}
+
+// pdfVersionHeader matches the version in the header of a document
+var pdfVersionHeader = regexp.MustCompile(`^%PDF-\d\.\d`)
+
+// pdfStreamLength matches the length of a stream in its dictionary
+var pdfStreamLength = regexp.MustCompile(`/Length (\d+)`)
+
+// pdfEncrypter encrypts the objects of a document with a file key
+type pdfEncrypter struct {
+	key        []byte
+	random     io.Reader // The initialization vectors
+	dictionary string    // The encryption dictionary, which is not encrypted
+}
+
+// newPDFEncrypter makes the file key and the encryption dictionary, with the keys and
+// salts read from random. A missing owner password is read from crypto/rand
+func newPDFEncrypter(e Encryption, random io.Reader) (*pdfEncrypter, error) {
+	key := make([]byte, 32)
+	salts := make([]byte, 32)
+	extra := make([]byte, 4)
+	for _, b := range [][]byte{key, salts, extra} {
+		if _, err := io.ReadFull(random, b); err != nil {
+			return nil, fmt.Errorf("failed to encrypt the document: %w", err)
+		}
+	}
+
+	// A missing owner password is never derived from the document or the user password
+	owner := []byte(e.OwnerPassword)
+	if len(owner) == 0 {
+		owner = make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, owner); err != nil {
+			return nil, fmt.Errorf("failed to encrypt the document: %w", err)
+		}
+	}
+
+	// Algorithms 8 and 9 of ISO 32000-2
+	user := []byte(e.UserPassword)
+	u := append(hashR6(user, salts[0:8], nil), salts[0:16]...)
+	ue := encryptBlocks(hashR6(user, salts[8:16], nil), key)
+	o := append(hashR6(owner, salts[16:24], u), salts[16:32]...)
+	oe := encryptBlocks(hashR6(owner, salts[24:32], u), key)
+
+	// Algorithm 10, the permissions encrypted with the file key
+	flags := e.flags()
+	perms := binary.LittleEndian.AppendUint32(nil, uint32(flags))
+	perms = append(perms, 0xFF, 0xFF, 0xFF, 0xFF, 'T', 'a', 'd', 'b')
+	perms = append(perms, extra...)
+	block, _ := aes.NewCipher(key)
+	block.Encrypt(perms, perms)
+
+	return &pdfEncrypter{
+		key:    key,
+		random: random,
+		dictionary: fmt.Sprintf("<</Filter /Standard /V 5 /R 6 /Length 256 /CF <</StdCF <</AuthEvent /DocOpen /CFM /AESV3 /Length 32>>>> /StmF /StdCF /StrF /StdCF /O <%X> /U <%X> /OE <%X> /UE <%X> /P %d /Perms <%X> /EncryptMetadata true>>",
+			o, u, oe, ue, flags, perms),
+	}, nil
+}
+
+// pdfEncryptionExtension declares AES-256 in the catalog, an extension of PDF 1.7
+// which PDF 2.0 made standard
+const pdfEncryptionExtension = "/Extensions <</ADBE <</BaseVersion /1.7 /ExtensionLevel 8>>>>"
+
+// object encrypts the strings and the stream of an object
+func (e *pdfEncrypter) object(object []byte) ([]byte, error) {
+	return encryptObject(object, func(data []byte) ([]byte, error) {
+		return encryptAES(e.key, data, e.random)
+	})
+}
+
+// encrypt encrypts every string and stream of the document and adds the encryption
+// dictionary, with the keys and salts read from random
+func (f *pdfFile) encrypt(e Encryption, random io.Reader) error {
+	encrypter, err := newPDFEncrypter(e, random)
+	if err != nil {
+		return err
+	}
+
+	f.header = pdfVersionHeader.ReplaceAll(f.header, []byte("%PDF-1.7"))
+	f.catalog(pdfEncryptionExtension)
+	for _, n := range f.order {
+		object, err := encrypter.object(f.objects[n])
+		if err != nil {
+			return fmt.Errorf("failed to encrypt object %d: %w", n, err)
+		}
+		f.objects[n] = object
+	}
+
+	f.encryption = f.add(encrypter.dictionary, nil)
+	return nil
+}
+
+// encryptObject encrypts the strings and the stream of an object
+func encryptObject(object []byte, encrypt func([]byte) ([]byte, error)) ([]byte, error) {
+	start := bytes.Index(object, []byte(" obj\n"))
+	if start < 0 {
+		return nil, errors.New("invalid object")
+	}
+	start += len(" obj\n")
+
+	var out bytes.Buffer
+	out.Write(object[:start])
+	for i := start; i < len(object); {
+		switch c := object[i]; {
+		case c == '(':
+			value, end, err := pdfLiteralString(object, i)
+			if err != nil {
+				return nil, err
+			}
+			if err := writeEncrypted(&out, value, encrypt); err != nil {
+				return nil, err
+			}
+			i = end
+		case c == '<' && i+1 < len(object) && object[i+1] == '<':
+			out.WriteString("<<")
+			i += 2
+		case c == '<':
+			end := bytes.IndexByte(object[i:], '>')
+			if end < 0 {
+				return nil, errors.New("unterminated hex string")
+			}
+			digits := bytes.Join(bytes.Fields(object[i+1:i+end]), nil)
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			value := make([]byte, len(digits)/2)
+			if _, err := hex.Decode(value, digits); err != nil {
+				return nil, fmt.Errorf("invalid hex string: %w", err)
+			}
+			if err := writeEncrypted(&out, value, encrypt); err != nil {
+				return nil, err
+			}
+			i += end + 1
+		case bytes.HasPrefix(object[i:], []byte("stream\n")) && (i == 0 || object[i-1] == '\n' || object[i-1] == '>' || object[i-1] == ' '):
+			// The dictionary ends before the stream, which is as long as it says
+			dictionary := out.Bytes()[start:]
+			match := pdfStreamLength.FindSubmatchIndex(dictionary)
+			if match == nil {
+				return nil, errors.New("stream without a direct length")
+			}
+			length, _ := strconv.Atoi(string(dictionary[match[2]:match[3]]))
+			data := i + len("stream\n")
+			if data+length > len(object) {
+				return nil, errors.New("stream longer than its object")
+			}
+			encrypted, err := encrypt(object[data : data+length])
+			if err != nil {
+				return nil, err
+			}
+
+			var b bytes.Buffer
+			b.Write(out.Bytes()[:start+match[2]])
+			b.WriteString(strconv.Itoa(len(encrypted)))
+			b.Write(out.Bytes()[start+match[3]:])
+			b.WriteString("stream\n")
+			b.Write(encrypted)
+			b.Write(object[data+length:])
+			return b.Bytes(), nil
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// writeEncrypted writes a string encrypted as a hex string
+func writeEncrypted(out *bytes.Buffer, value []byte, encrypt func([]byte) ([]byte, error)) error {
+	encrypted, err := encrypt(value)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "<%X>", encrypted)
+	return nil
+}
+
+// pdfLiteralString decodes the literal string starting at data[start], returning its
+// bytes and the index following it
+func pdfLiteralString(data []byte, start int) ([]byte, int, error) {
+	var value []byte
+	depth := 0
+	for i := start; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '(':
+			depth++
+			if depth > 1 {
+				value = append(value, c)
+			}
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return value, i + 1, nil
+			}
+			value = append(value, c)
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch e := data[i]; e {
+			case 'n':
+				value = append(value, '\n')
+			case 'r':
+				value = append(value, '\r')
+			case 't':
+				value = append(value, '\t')
+			case 'b':
+				value = append(value, '\b')
+			case 'f':
+				value = append(value, '\f')
+			case '\n':
+				// A line continuation
+			case '\r':
+				if i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			default:
+				if e >= '0' && e <= '7' {
+					code := 0
+					for j := 0; j < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; j++ {
+						code = code*8 + int(data[i]-'0')
+						i++
+					}
+					i--
+					value = append(value, byte(code))
+				} else {
+					value = append(value, e)
+				}
+			}
+		default:
+			value = append(value, c)
+		}
+	}
+	return nil, 0, errors.New("unterminated literal string")
+}
+
+// encryptAES encrypts data with AES-256 in CBC mode, padded as PKCS #5 and preceded by
+// its initialization vector
+func encryptAES(key, data []byte, random io.Reader) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	out := make([]byte, aes.BlockSize+len(data)+padding)
+	if _, err := io.ReadFull(random, out[:aes.BlockSize]); err != nil {
+		return nil, err
+	}
+	copy(out[aes.BlockSize:], data)
+	for i := len(out) - padding; i < len(out); i++ {
+		out[i] = byte(padding)
+	}
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], out[aes.BlockSize:])
+	return out, nil
+}
+
+// encryptBlocks encrypts data, a multiple of the block size, with AES-256 in CBC mode
+// with a zero initialization vector and no padding
+func encryptBlocks(key, data []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, data)
+	return out
+}
+
+// hashR6 is the hash of a password of algorithm 2.B of ISO 32000-2. The rounds after
+// the 64th go on until the last byte of E is at most the round number minus 32
+func hashR6(password, salt, userKey []byte) []byte {
+	sum := sha256.Sum256(append(append(append([]byte(nil), password...), salt...), userKey...))
+	k := sum[:]
+
+	for round := 1; ; round++ {
+		var k1 []byte
+		for i := 0; i < 64; i++ {
+			k1 = append(k1, password...)
+			k1 = append(k1, k...)
+			k1 = append(k1, userKey...)
+		}
+
+		block, _ := aes.NewCipher(k[:16])
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+
+		// The first 16 bytes of E as a number modulo 3, which is the sum of the bytes
+		// modulo 3 as 256 is 1 modulo 3
+		remainder := 0
+		for _, b := range e[:16] {
+			remainder += int(b)
+		}
+		var h hash.Hash
+		switch remainder % 3 {
+		case 0:
+			h = sha256.New()
+		case 1:
+			h = sha512.New384()
+		default:
+			h = sha512.New()
+		}
+		h.Write(e)
+		k = h.Sum(nil)
+
+		if round >= 64 && int(e[len(e)-1]) <= round-32 {
+			return k[:32]
+		}
+	}
+}
+
+// deterministicRandom is a source of random bytes made from a seed, so deterministic
+// documents are encrypted the same way every time: the SHA-256 of the seed and a counter
+type deterministicRandom struct {
+	seed    [32]byte
+	counter uint64
+	buf     []byte
+}
+
+func newDeterministicRandom(seed ...[]byte) *deterministicRandom {
+	h := sha256.New()
+	for _, s := range seed {
+		h.Write(s)
+	}
+	r := &deterministicRandom{}
+	h.Sum(r.seed[:0])
+	return r
+}
+
+func (r *deterministicRandom) Read(p []byte) (int, error) {
+	for n := 0; n < len(p); {
+		if len(r.buf) == 0 {
+			block := sha256.Sum256(binary.BigEndian.AppendUint64(r.seed[:], r.counter))
+			r.counter++
+			r.buf = block[:]
+		}
+		copied := copy(p[n:], r.buf)
+		r.buf = r.buf[copied:]
+		n += copied
+	}
+	return len(p), nil
+}
+
+// encryptionRandom returns the random source of the encryption, made from the document
+// and the passwords in deterministic mode, where checkEncryption requires an owner
+// password so the seed holds a secret
+func encryptionRandom(config renderConfig, data []byte) io.Reader {
+	if !config.deterministic {
+		return rand.Reader
+	}
+	return newDeterministicRandom(data, []byte(config.encryption.UserPassword), []byte{0}, []byte(config.encryption.OwnerPassword))
+}
@@ -0,0 +1,253 @@
+package sahar
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEncryption(t *testing.T) {
+	page := func() *Node {
+		return Layout(Box(
+			Sizing(Fixed(200), Fixed(100)),
+			Children(Text("Total (net)", FontType("Helvetica"), FontSize(10)), TextField("name", FieldValue("Ada"))),
+		))
+	}
+	encryption := Encryption{UserPassword: "open", OwnerPassword: "owner", Permissions: PermitPrint | PermitFillForms}
+
+	render := func(t *testing.T, opts ...RenderOption) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := RenderPDF(&buf, append([]RenderOption{page(), Metadata{Title: "Secret"}}, opts...)...); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	// value returns the hex string of a key of the encryption dictionary
+	value := func(t *testing.T, data []byte, key string) []byte {
+		t.Helper()
+		match := regexp.MustCompile(`/` + key + ` <([0-9A-F]+)>`).FindSubmatch(data)
+		if match == nil {
+			t.Fatalf("missing /%s in the encryption dictionary", key)
+		}
+		b, _ := hex.DecodeString(string(match[1]))
+		return b
+	}
+
+	// fileKey returns the file key opened by the user password, or by the owner
+	// password when owner is true, nil for a wrong password
+	fileKey := func(t *testing.T, data []byte, password string, owner bool) []byte {
+		t.Helper()
+		u := value(t, data, "U")
+		hashed, salts, userKey, encrypted := u, u, []byte(nil), value(t, data, "UE")
+		if owner {
+			hashed, salts, userKey, encrypted = value(t, data, "O"), value(t, data, "O"), u, value(t, data, "OE")
+		}
+		if !bytes.Equal(hashR6([]byte(password), salts[32:40], userKey), hashed[:32]) {
+			return nil
+		}
+
+		block, _ := aes.NewCipher(hashR6([]byte(password), salts[40:48], userKey))
+		key := make([]byte, 32)
+		cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(key, encrypted)
+		return key
+	}
+
+	decrypt := func(t *testing.T, key, data []byte) []byte {
+		t.Helper()
+		block, _ := aes.NewCipher(key)
+		out := make([]byte, len(data)-aes.BlockSize)
+		cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(out, data[aes.BlockSize:])
+		return out[:len(out)-int(out[len(out)-1])]
+	}
+
+	t.Run("encrypts the strings and streams", func(t *testing.T) {
+		data := render(t, encryption)
+		checkXref(t, data)
+		if !bytes.HasPrefix(data, []byte("%PDF-1.7\n")) {
+			t.Errorf("expected the version of AES-256, got %q", data[:8])
+		}
+
+		output := string(data)
+		for _, expected := range []string{
+			"/Filter /Standard /V 5 /R 6 /Length 256",
+			"/CFM /AESV3",
+			"/P -1084 ",
+			"/EncryptMetadata true",
+			"\n/Encrypt ",
+			"\n/ID [<",
+			"/Extensions <</ADBE <</BaseVersion /1.7 /ExtensionLevel 8>>>>",
+		} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("expected %s in the PDF", expected)
+			}
+		}
+		for _, clear := range []string{"(Secret)", "(Ada)", "(name)", "(Helv)"} {
+			if strings.Contains(output, clear) {
+				t.Errorf("expected %s to be encrypted", clear)
+			}
+		}
+
+		key := fileKey(t, data, "open", false)
+		if key == nil {
+			t.Fatal("expected the user password to open the document")
+		}
+		if !bytes.Equal(fileKey(t, data, "owner", true), key) {
+			t.Error("expected the owner password to open the document with the same key")
+		}
+		if fileKey(t, data, "wrong", false) != nil || fileKey(t, data, "open", true) != nil {
+			t.Error("expected wrong passwords to be refused")
+		}
+
+		perms := value(t, data, "Perms")
+		block, _ := aes.NewCipher(key)
+		block.Decrypt(perms, perms)
+		if flags := int32(binary.LittleEndian.Uint32(perms)); flags != -1084 || string(perms[8:12]) != "Tadb" {
+			t.Errorf("unexpected permissions %d %q", flags, perms[8:12])
+		}
+
+		f, err := parsePDF(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range f.order {
+			object := f.objects[n]
+			start := bytes.Index(object, []byte(">>\nstream\n"))
+			if start < 0 {
+				continue
+			}
+			length := atoi(t, regexp.MustCompile(`/Length (\d+)`).FindSubmatch(object)[1])
+			if end := bytes.LastIndex(object, []byte("\nendstream")); end-start-len(">>\nstream\n") != length || length%aes.BlockSize != 0 {
+				t.Errorf("object %d: unexpected stream length %d", n, length)
+			}
+		}
+
+		content := regexp.MustCompile(`/Contents (\d+) 0 R`).FindSubmatch(f.objects[f.pages()[0]])
+		if content == nil {
+			t.Fatal("missing the content of the page")
+		}
+		object := f.objects[atoi(t, content[1])]
+		length := atoi(t, regexp.MustCompile(`/Length (\d+)`).FindSubmatch(object)[1])
+		start := bytes.Index(object, []byte("stream\n")) + len("stream\n")
+		reader, err := zlib.NewReader(bytes.NewReader(decrypt(t, key, object[start:start+length])))
+		if err != nil {
+			t.Fatalf("failed to read the decrypted content: %v", err)
+		}
+		decompressed, _ := io.ReadAll(reader)
+		if !bytes.Contains(decompressed, []byte(`(Total \(net\)) Tj`)) {
+			t.Errorf("expected the text in the decrypted content:\n%s", decompressed)
+		}
+	})
+
+	t.Run("opens without a user password", func(t *testing.T) {
+		data := render(t, Encryption{Permissions: PermitAll})
+		if fileKey(t, data, "", false) == nil {
+			t.Error("expected the empty user password to open the document")
+		}
+		if !strings.Contains(string(data), "/P -4 ") {
+			t.Error("expected every permission")
+		}
+	})
+
+	t.Run("is deterministic", func(t *testing.T) {
+		data := render(t, encryption, Deterministic())
+		if !bytes.Equal(data, render(t, encryption, Deterministic())) {
+			t.Error("expected identical output in deterministic mode")
+		}
+		if bytes.Equal(data, render(t, Encryption{UserPassword: "other", OwnerPassword: "owner"}, Deterministic())) {
+			t.Error("expected other passwords to change the output")
+		}
+	})
+
+	t.Run("never derives a missing owner password", func(t *testing.T) {
+		owner := func() string {
+			encrypter, err := newPDFEncrypter(Encryption{UserPassword: "open"}, newDeterministicRandom([]byte("seed")))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			o, _, _ := strings.Cut(encrypter.dictionary[strings.Index(encrypter.dictionary, "/O <"):], ">")
+			return o
+		}
+		if owner() == owner() {
+			t.Error("expected a random owner password with the same seed")
+		}
+	})
+
+	t.Run("is set by PDFOptions", func(t *testing.T) {
+		options := DefaultPDFOptions()
+		options.Encryption = &encryption
+
+		var buf bytes.Buffer
+		if err := RenderToPDFWithOptions(page(), &buf, options); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		checkXref(t, buf.Bytes())
+		if fileKey(t, buf.Bytes(), "open", false) == nil {
+			t.Error("expected the document to be encrypted")
+		}
+	})
+
+	t.Run("reports invalid encryption", func(t *testing.T) {
+		tests := []struct {
+			name string
+			opts []RenderOption
+			err  string
+		}{
+			{"long password", []RenderOption{Encryption{UserPassword: strings.Repeat("x", 128)}}, "passwords are at most 127 bytes long"},
+			{"permissions", []RenderOption{Encryption{Permissions: 1 << 10}}, "unknown permissions 0x400"},
+			{"PDF/A", []RenderOption{PDFA2B, Encryption{}}, "PDF/A-2b documents can't be encrypted"},
+			{"deterministic without owner password", []RenderOption{Deterministic(), Encryption{UserPassword: "open"}}, "deterministic documents need an OwnerPassword"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				root := Layout(Box(Sizing(Fixed(100), Fixed(100))))
+				err := RenderPDF(&bytes.Buffer{}, append([]RenderOption{root}, tt.opts...)...)
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected %q, got %v", tt.err, err)
+				}
+			})
+		}
+	})
+}
+
+func TestEncryptObject(t *testing.T) {
+	reverse := func(data []byte) ([]byte, error) {
+		out := make([]byte, len(data))
+		for i, b := range data {
+			out[len(data)-1-i] = b
+		}
+		return out, nil
+	}
+
+	object := "4 0 obj\n<</T (a\\(b\\) \\101\\n(c)) /S <6162> /N /x#28 /D <</K [(z)]>> /Length 3>>\nstream\n(y)\nendstream\nendobj\n"
+	encrypted, err := encryptObject([]byte(object), reverse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "4 0 obj\n<</T <2963280A412029622861> /S <6261> /N /x#28 /D <</K [<7A>]>> /Length 3>>\nstream\n)y(\nendstream\nendobj\n"
+	if string(encrypted) != expected {
+		t.Errorf("unexpected object:\n%s", encrypted)
+	}
+
+	if _, err := encryptObject([]byte("4 0 obj\n<</T (a>>\nendobj\n"), reverse); err == nil {
+		t.Error("expected an unterminated string to be reported")
+	}
+}
+
+func atoi(t *testing.T, s []byte) int {
+	t.Helper()
+	n, err := strconv.Atoi(string(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/xml"
 	"fmt"
 	"os"
 
@@ -407,6 +408,22 @@ func Footer() *sahar.Node {
 	)
 }
 
+// InvoiceData creates the machine-readable invoice attached to the PDF, so accounting
+// software can read it without parsing the pages
+func InvoiceData(invoiceNumber string, items []InvoiceItem) ([]byte, error) {
+	data := struct {
+		XMLName xml.Name      `xml:"Invoice"`
+		Number  string        `xml:"Number"`
+		Items   []InvoiceItem `xml:"Item"`
+	}{Number: invoiceNumber, Items: items}
+
+	out, err := xml.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
 func main() {
 	// Load fonts
 	err := sahar.LoadFonts("Arial", "./Arial.ttf")
@@ -480,7 +497,18 @@ func main() {
 	}
 	defer pdfFile.Close()
 
-	err = sahar.RenderToPDF(pdfFile, page)
+	invoiceData, err := InvoiceData(invoiceNumber, items)
+	if err != nil {
+		panic(err)
+	}
+
+	err = sahar.RenderPDF(pdfFile, page, sahar.Attachment{
+		Name:         "invoice.xml",
+		MIMEType:     "text/xml",
+		Description:  "Invoice " + invoiceNumber,
+		Relationship: sahar.RelationData,
+		Data:         invoiceData,
+	})
 	if err != nil {
 		panic(err)
 	}
@@ -3,15 +3,34 @@ package sahar
 import (
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/font"
 )
 
-// fontCache stores loaded fonts to avoid reloading
-var fontCache = make(map[string]*truetype.Font)
+var (
+	// fontMu guards fontCache and fontData, fonts can be loaded while documents are rendered
+	fontMu sync.RWMutex
+	// fontCache stores loaded fonts to avoid reloading
+	fontCache = make(map[string]*truetype.Font)
+	// fontData stores the files of the loaded fonts, so they can be embedded in documents
+	fontData = make(map[string][]byte)
+)
 
 func LoadFonts(src ...string) error {
+	return loadFonts(false, src...)
+}
+
+// ReloadFonts loads fonts like LoadFonts, but reads the files of the fonts which are
+// already loaded again, to pick up the changes of a font file
+func ReloadFonts(src ...string) error {
+	return loadFonts(true, src...)
+}
+
+// loadFonts loads pairs of names and paths, the fonts already loaded are skipped
+// unless reload is true
+func loadFonts(reload bool, src ...string) error {
 	if len(src) == 0 {
 		return nil // No fonts to load
 	}
@@ -24,7 +43,7 @@ func LoadFonts(src ...string) error {
 		name := src[i]
 		path := src[i+1]
 
-		if name == "" || path == "" || fontCache[name] != nil {
+		if name == "" || path == "" || (!reload && loadedFont(name) != nil) {
 			continue // Skip empty names/paths or already loaded fonts
 		}
 
@@ -38,16 +57,34 @@ func LoadFonts(src ...string) error {
 			return fmt.Errorf("failed to parse font %s from %s: %w", name, path, err)
 		}
 
+		fontMu.Lock()
 		fontCache[name] = ttfFont
+		fontData[name] = font
+		fontMu.Unlock()
 	}
 
 	return nil
 }
 
+// loadedFont returns the font loaded with the given name, nil when there is none
+func loadedFont(name string) *truetype.Font {
+	fontMu.RLock()
+	defer fontMu.RUnlock()
+	return fontCache[name]
+}
+
+// loadedFontData returns the file of the font loaded with the given name, nil when
+// there is none
+func loadedFontData(name string) []byte {
+	fontMu.RLock()
+	defer fontMu.RUnlock()
+	return fontData[name]
+}
+
 // getFontFace returns a font.Face for the given font type and size
 func getFontFace(fontType string, fontSize float64) font.Face {
-	ttfFont, exists := fontCache[fontType]
-	if !exists {
+	ttfFont := loadedFont(fontType)
+	if ttfFont == nil {
 		return nil
 	}
 
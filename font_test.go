@@ -1,10 +1,14 @@
 package sahar
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
 )
 
 func TestLoadFonts(t *testing.T) {
@@ -78,6 +82,37 @@ func TestLoadFonts(t *testing.T) {
 			t.Errorf("unexpected error: %v", err)
 		}
 	})
+
+	t.Run("reloads a changed font file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "font.ttf")
+		if err := os.WriteFile(path, goregular.TTF, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := LoadFonts("ReloadFont", path); err != nil {
+			t.Fatal(err)
+		}
+		before := measureTextWidth("Hello world", 12, "ReloadFont")
+
+		if err := os.WriteFile(path, gomono.TTF, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := LoadFonts("ReloadFont", path); err != nil {
+			t.Fatal(err)
+		}
+		if width := measureTextWidth("Hello world", 12, "ReloadFont"); width != before {
+			t.Errorf("expected LoadFonts to keep the loaded font, got %v instead of %v", width, before)
+		}
+
+		if err := ReloadFonts("ReloadFont", path); err != nil {
+			t.Fatal(err)
+		}
+		if width := measureTextWidth("Hello world", 12, "ReloadFont"); width == before {
+			t.Error("expected ReloadFonts to read the font file again")
+		}
+		if !bytes.Equal(loadedFontData("ReloadFont"), gomono.TTF) {
+			t.Error("expected the data of the reloaded font")
+		}
+	})
 }
 
 func TestGetFontFace(t *testing.T) {
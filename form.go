@@ -0,0 +1,465 @@
+package sahar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldKind is the kind of a form field
+type FieldKind int
+
+const (
+	FieldText      FieldKind = iota // A text input, see TextField and TextArea
+	FieldCheckbox                   // See Checkbox
+	FieldRadio                      // A button of a radio group, see RadioButton
+	FieldDropdown                   // See Dropdown
+	FieldSignature                  // An empty field for a digital signature, see SignatureField
+)
+
+func (k FieldKind) String() string {
+	switch k {
+	case FieldText:
+		return "text"
+	case FieldCheckbox:
+		return "checkbox"
+	case FieldRadio:
+		return "radio"
+	case FieldDropdown:
+		return "dropdown"
+	case FieldSignature:
+		return "signature"
+	default:
+		return fmt.Sprintf("FieldKind(%d)", int(k))
+	}
+}
+
+// FormField is a fillable field of a PDF form. The node holding it is laid out like
+// a box, which gives the field its size and position, and RenderToPDF writes it as an
+// AcroForm widget over the border box of the node
+type FormField struct {
+	Kind      FieldKind
+	Name      string   // Radio buttons of a group share their name
+	Value     string   // The default text or option, or the value of a radio button
+	Checked   bool     // Whether a checkbox or radio button is on by default
+	Options   []string // The options of a dropdown
+	Multiline bool
+	MaxLength int // The maximum number of characters of a text field, 0 for no limit
+	Required  bool
+	ReadOnly  bool
+	Tooltip   string
+	FontSize  float64 // 10 when zero
+	FontColor string
+	Format    FieldFormat // How viewers format a text field, see NumberFormat
+}
+
+// defaultFieldFontSize is the font size of fields without one
+const defaultFieldFontSize = 10
+
+// FieldFormat is how viewers format the value of a text field when it is typed, with
+// the JavaScript functions of the PDF specification. Viewers without JavaScript keep
+// the value as typed
+type FieldFormat struct {
+	kind     string // number, percent or date
+	decimals int
+	text     string // The currency of a number or the layout of a date
+}
+
+// NumberFormat formats numbers with decimals digits after the point and thousands
+// separators, prefixed with currency when it is not empty
+func NumberFormat(decimals int, currency string) FieldFormat {
+	return FieldFormat{kind: "number", decimals: decimals, text: currency}
+}
+
+// PercentFormat formats numbers as percentages with decimals digits after the point
+func PercentFormat(decimals int) FieldFormat {
+	return FieldFormat{kind: "percent", decimals: decimals}
+}
+
+// DateFormat formats dates with a layout such as "yyyy-mm-dd" or "dd/mm/yyyy"
+func DateFormat(layout string) FieldFormat {
+	return FieldFormat{kind: "date", text: layout}
+}
+
+// scripts returns the JavaScript formatting the value and the one checking keystrokes
+func (f FieldFormat) scripts() (format, keystroke string) {
+	switch f.kind {
+	case "number":
+		args := fmt.Sprintf("%d, 0, 0, 0, %s, true", f.decimals, strconv.Quote(f.text))
+		return "AFNumber_Format(" + args + ");", "AFNumber_Keystroke(" + args + ");"
+	case "percent":
+		args := fmt.Sprintf("%d, 0", f.decimals)
+		return "AFPercent_Format(" + args + ");", "AFPercent_Keystroke(" + args + ");"
+	default:
+		arg := strconv.Quote(f.text)
+		return "AFDate_FormatEx(" + arg + ");", "AFDate_KeystrokeEx(" + arg + ");"
+	}
+}
+
+// field creates the box of a form field with a default size, which the options can change
+func field(f FormField, width, height sizingOpt, opts []nodeOpt) *Node {
+	n := Box(Sizing(width, height), Border(1), BorderColor("#808080"))
+	n.Field = &f
+	for _, opt := range opts {
+		opt.configureNode(n)
+	}
+	return n
+}
+
+// TextField creates a single-line text input filling the width of its parent
+func TextField(name string, opts ...nodeOpt) *Node {
+	return field(FormField{Kind: FieldText, Name: name}, Grow(), Fixed(22), opts)
+}
+
+// TextArea creates a multi-line text input filling the width of its parent
+func TextArea(name string, opts ...nodeOpt) *Node {
+	return field(FormField{Kind: FieldText, Name: name, Multiline: true}, Grow(), Fixed(66), opts)
+}
+
+// Checkbox creates a checkbox, which is off unless Checked is passed
+func Checkbox(name string, opts ...nodeOpt) *Node {
+	return field(FormField{Kind: FieldCheckbox, Name: name}, Fixed(12), Fixed(12), opts)
+}
+
+// RadioButton creates a button of the radio group called group, choosing value. At
+// most one button of a group is Checked
+func RadioButton(group, value string, opts ...nodeOpt) *Node {
+	return field(FormField{Kind: FieldRadio, Name: group, Value: value}, Fixed(12), Fixed(12), opts)
+}
+
+// Dropdown creates a list of options filling the width of its parent, the first
+// option is chosen unless FieldValue chooses another one
+func Dropdown(name string, options []string, opts ...nodeOpt) *Node {
+	f := FormField{Kind: FieldDropdown, Name: name, Options: options}
+	if len(options) > 0 {
+		f.Value = options[0]
+	}
+	return field(f, Grow(), Fixed(22), opts)
+}
+
+// SignatureField creates an empty field where a digital signature is placed later
+func SignatureField(name string, opts ...nodeOpt) *Node {
+	return field(FormField{Kind: FieldSignature, Name: name}, Grow(), Fixed(48), opts)
+}
+
+// fieldOpt changes the form field of a node, nodes without a field are left unchanged
+func fieldOpt(configure func(f *FormField)) nodeOpt {
+	return nodeOptFunc(func(n *Node) {
+		if n.Field != nil {
+			configure(n.Field)
+		}
+	})
+}
+
+// FieldValue sets the default text of a text field or the chosen option of a dropdown
+func FieldValue(value string) nodeOpt {
+	return fieldOpt(func(f *FormField) {
+		f.Value = value
+	})
+}
+
+// Checked turns a checkbox or a radio button on by default
+func Checked() nodeOpt {
+	return fieldOpt(func(f *FormField) {
+		f.Checked = true
+	})
+}
+
+// MaxLength limits the number of characters of a text field
+func MaxLength(length int) nodeOpt {
+	return fieldOpt(func(f *FormField) {
+		f.MaxLength = length
+	})
+}
+
+// Required makes viewers ask for a value before the form is submitted
+func Required() nodeOpt {
+	return fieldOpt(func(f *FormField) {
+		f.Required = true
+	})
+}
+
+// ReadOnly prevents the field from being changed
+func ReadOnly() nodeOpt {
+	return fieldOpt(func(f *FormField) {
+		f.ReadOnly = true
+	})
+}
+
+// Tooltip sets the text viewers show over the field, screen readers read it as its name
+func Tooltip(text string) nodeOpt {
+	return fieldOpt(func(f *FormField) {
+		f.Tooltip = text
+	})
+}
+
+// FieldFont sets the font size and the hex color of the value of the field
+func FieldFont(size float64, color string) nodeOpt {
+	return fieldOpt(func(f *FormField) {
+		f.FontSize = size
+		f.FontColor = color
+	})
+}
+
+// Formatted sets how viewers format the value of a text field
+func Formatted(format FieldFormat) nodeOpt {
+	return fieldOpt(func(f *FormField) {
+		f.Format = format
+	})
+}
+
+// onValue returns the name of the on state of a checkbox or radio button
+func (f FormField) onValue() string {
+	if f.Kind == FieldRadio || f.Value != "" {
+		return f.Value
+	}
+	return "Yes"
+}
+
+// check reports a field which can't be written
+func (f FormField) check() error {
+	switch {
+	case f.Name == "":
+		return fmt.Errorf("%s field without a name", f.Kind)
+	case strings.Contains(f.Name, "."):
+		return fmt.Errorf("form field %q: names can't contain dots", f.Name)
+	case f.Kind < FieldText || f.Kind > FieldSignature:
+		return fmt.Errorf("form field %q has the unknown kind %s", f.Name, f.Kind)
+	case f.MaxLength < 0:
+		return fmt.Errorf("form field %q has a negative maximum length", f.Name)
+	case f.FontSize < 0:
+		return fmt.Errorf("form field %q has a negative font size", f.Name)
+	case f.Kind == FieldRadio && f.Value == "":
+		return fmt.Errorf("radio button of %q without a value", f.Name)
+	case f.Kind == FieldDropdown && len(f.Options) == 0:
+		return fmt.Errorf("dropdown %q has no options", f.Name)
+	case f.Format != FieldFormat{} && f.Kind != FieldText:
+		return fmt.Errorf("form field %q: only text fields are formatted", f.Name)
+	}
+	if f.Kind == FieldDropdown {
+		found := false
+		for _, option := range f.Options {
+			found = found || option == f.Value
+		}
+		if !found {
+			return fmt.Errorf("dropdown %q has the value %q, which is not one of its options", f.Name, f.Value)
+		}
+	}
+	if _, err := parseColor(f.FontColor, "#000000"); err != nil {
+		return fmt.Errorf("form field %q: invalid font color: %w", f.Name, err)
+	}
+	return nil
+}
+
+// checkFields reports invalid fields, names used by several fields other than the
+// buttons of a radio group, and radio groups with several buttons on or with the same
+// value, before anything is drawn
+func checkFields(pages []*Node) error {
+	kinds := map[string]FieldKind{}
+	values := map[string]map[string]bool{}
+	checked := map[string]bool{}
+
+	var walk func(node *Node) error
+	walk = func(node *Node) error {
+		if node == nil {
+			return nil
+		}
+		if f := node.Field; f != nil {
+			if err := f.check(); err != nil {
+				return err
+			}
+			if kind, ok := kinds[f.Name]; ok && (kind != FieldRadio || f.Kind != FieldRadio) {
+				return fmt.Errorf("form field %q is used twice", f.Name)
+			}
+			kinds[f.Name] = f.Kind
+
+			if f.Kind == FieldRadio {
+				if values[f.Name] == nil {
+					values[f.Name] = map[string]bool{}
+				}
+				if values[f.Name][f.Value] {
+					return fmt.Errorf("radio group %q has two buttons with the value %q", f.Name, f.Value)
+				}
+				values[f.Name][f.Value] = true
+				if f.Checked && checked[f.Name] {
+					return fmt.Errorf("radio group %q has several buttons checked", f.Name)
+				}
+				checked[f.Name] = checked[f.Name] || f.Checked
+			}
+		}
+		for _, child := range node.Children {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, page := range pages {
+		if err := walk(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pdfField is a form field written by form once the pages are written, with the
+// appearance streams of its states, drawn in its rectangle
+type pdfField struct {
+	page     int  // The number of the page, starting at 1
+	rect     Rect // In PDF coordinates, from the bottom left corner
+	field    FormField
+	quadding int    // The alignment of the value, 0 for left, 1 for center and 2 for right
+	on       string // The appearance of text fields and dropdowns, or of the on state
+	off      string // The appearance of the off state of checkboxes and radio buttons
+}
+
+// form adds the fields to the catalog and their widgets to their pages. The values are
+// drawn with the standard Helvetica font, and check marks with ZapfDingbats
+func (f *pdfFile) form(fields []pdfField) error {
+	pages := f.pages()
+	helvetica := f.add("<</Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding>>", nil)
+	dingbats := f.add("<</Type /Font /Subtype /Type1 /BaseFont /ZapfDingbats>>", nil)
+	resources := fmt.Sprintf("<</Font <</Helv %d 0 R /ZaDb %d 0 R>>>>", helvetica, dingbats)
+	appearance := func(rect Rect, content string) int {
+		return f.add(fmt.Sprintf("<</Type /XObject /Subtype /Form /BBox [0 0 %.2f %.2f] /Resources %s /Length %d>>",
+			rect.Width, rect.Height, resources, len(content)), []byte(content))
+	}
+
+	var roots []string
+	groups := map[string]int{}    // The parent of the buttons of every radio group
+	kids := map[string][]string{} // The buttons of every radio group
+	chosen := map[string]string{} // The value of every radio group
+	var groupNames []string       // The radio groups in order
+	signatures := false
+	for _, pf := range fields {
+		if pf.page < 1 || pf.page > len(pages) {
+			return fmt.Errorf("failed to add form field %q: page %d not found", pf.field.Name, pf.page)
+		}
+		page := pages[pf.page-1]
+		field := pf.field
+		widget := fmt.Sprintf("/Type /Annot /Subtype /Widget /Rect [%.2f %.2f %.2f %.2f] /F 4 /P %d 0 R",
+			pf.rect.X, pf.rect.Y, pf.rect.X+pf.rect.Width, pf.rect.Y+pf.rect.Height, page)
+
+		var flags int
+		if field.ReadOnly {
+			flags |= 1
+		}
+		if field.Required {
+			flags |= 1 << 1
+		}
+		entries := fmt.Sprintf(" /T %s", pdfLiteral(field.Name))
+		if field.Tooltip != "" {
+			entries += " /TU " + pdfLiteral(field.Tooltip)
+		}
+
+		var object int
+		switch field.Kind {
+		case FieldRadio:
+			if _, ok := groups[field.Name]; !ok {
+				groups[field.Name] = f.add("", nil) // Written once all the buttons are known
+				groupNames = append(groupNames, field.Name)
+				roots = append(roots, fmt.Sprintf("%d 0 R", groups[field.Name]))
+			}
+			state := "/Off"
+			if field.Checked {
+				state = pdfName(field.Value)
+				chosen[field.Name] = field.Value
+			}
+			tooltip := ""
+			if field.Tooltip != "" {
+				tooltip = " /TU " + pdfLiteral(field.Tooltip)
+			}
+			object = f.add(fmt.Sprintf("<<%s /Parent %d 0 R%s /AS %s /MK <</CA (l)>> /AP <</N <<%s %d 0 R /Off %d 0 R>>>>>>",
+				widget, groups[field.Name], tooltip, state, pdfName(field.Value), appearance(pf.rect, pf.on), appearance(pf.rect, pf.off)), nil)
+			kids[field.Name] = append(kids[field.Name], fmt.Sprintf("%d 0 R", object))
+			f.annotate(page, object)
+			continue
+		case FieldCheckbox:
+			state := "/Off"
+			if field.Checked {
+				state = pdfName(field.onValue())
+			}
+			object = f.add(fmt.Sprintf("<<%s /FT /Btn%s /Ff %d /V %s /DV %s /AS %s /MK <</CA (4)>> /AP <</N <<%s %d 0 R /Off %d 0 R>>>>>>",
+				widget, entries, flags, state, state, state, pdfName(field.onValue()), appearance(pf.rect, pf.on), appearance(pf.rect, pf.off)), nil)
+		case FieldText, FieldDropdown:
+			kind := "/Tx"
+			if field.Kind == FieldDropdown {
+				kind = "/Ch"
+				flags |= 1 << 17 // Combo box
+				var options []string
+				for _, option := range field.Options {
+					options = append(options, pdfLiteral(option))
+				}
+				entries += fmt.Sprintf(" /Opt [%s]", strings.Join(options, " "))
+			} else if field.Multiline {
+				flags |= 1 << 12
+			}
+			if field.MaxLength > 0 {
+				entries += fmt.Sprintf(" /MaxLen %d", field.MaxLength)
+			}
+			if field.Format != (FieldFormat{}) {
+				format, keystroke := field.Format.scripts()
+				entries += fmt.Sprintf(" /AA <</F <</S /JavaScript /JS %s>> /K <</S /JavaScript /JS %s>>>>",
+					pdfLiteral(format), pdfLiteral(keystroke))
+			}
+			object = f.add(fmt.Sprintf("<<%s /FT %s%s /Ff %d /V %s /DV %s /DA (%s) /Q %d /AP <</N %d 0 R>>>>",
+				widget, kind, entries, flags, pdfLiteral(field.Value), pdfLiteral(field.Value), fieldFont(field), pf.quadding, appearance(pf.rect, pf.on)), nil)
+		case FieldSignature:
+			signatures = true
+			object = f.add(fmt.Sprintf("<<%s /FT /Sig%s /Ff %d /AP <</N %d 0 R>>>>", widget, entries, flags, appearance(pf.rect, "")), nil)
+		}
+		roots = append(roots, fmt.Sprintf("%d 0 R", object))
+		f.annotate(page, object)
+	}
+
+	for _, name := range groupNames {
+		value := "/Off"
+		if v, ok := chosen[name]; ok {
+			value = pdfName(v)
+		}
+		n := groups[name]
+		f.objects[n] = fmt.Appendf(nil, "%d 0 obj\n<</FT /Btn /Ff %d /T %s /V %s /DV %s /Kids [%s]>>\nendobj\n",
+			n, 1<<14|1<<15, pdfLiteral(name), value, value, strings.Join(kids[name], " "))
+	}
+
+	form := fmt.Sprintf("/AcroForm <</Fields [%s] /DR %s /DA (/Helv 0 Tf 0 g)", strings.Join(roots, " "), resources)
+	if signatures {
+		form += " /SigFlags 1"
+	}
+	f.catalog(form + ">>")
+	return nil
+}
+
+// fieldFont returns the operators setting the font and the color of the value of a field
+func fieldFont(field FormField) string {
+	size := field.FontSize
+	if size == 0 {
+		size = defaultFieldFontSize
+	}
+	color, _ := parseColor(field.FontColor, "#000000")
+	return fmt.Sprintf("/Helv %.2f Tf %.3f %.3f %.3f rg", size, float64(color.R)/255, float64(color.G)/255, float64(color.B)/255)
+}
+
+// pdfWinAnsi returns text as a PDF string in the WinAnsi encoding of the standard
+// fonts, characters it doesn't have become question marks
+func pdfWinAnsi(text string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '€':
+			b.WriteString(`\200`)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, `\%03o`, r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
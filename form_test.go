@@ -0,0 +1,146 @@
+package sahar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestForm(t *testing.T) {
+	page := func() *Node {
+		return Layout(Box(
+			Sizing(Fixed(300), Fixed(400)),
+			Direction(TopToBottom),
+			Padding(10, 10, 10, 10),
+			ChildGap(5),
+			Children(
+				TextField("name", FieldValue("Ada (Countess)"), MaxLength(20), Required(), Tooltip("Full name")),
+				TextArea("notes", FieldValue("first\nsecond")),
+				TextField("amount", Formatted(NumberFormat(2, "$")), Alignment(Right, Top)),
+				Checkbox("agree", Checked()),
+				Box(ChildGap(5), Children(RadioButton("plan", "basic"), RadioButton("plan", "pro", Checked()))),
+				Dropdown("country", []string{"FR", "DE"}, FieldValue("DE"), ReadOnly()),
+				SignatureField("signature"),
+			),
+		))
+	}
+
+	t.Run("lays out the fields", func(t *testing.T) {
+		canvas := &recordingCanvas{}
+		if err := Render(canvas, page()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		calls := strings.Join(canvas.calls, "\n")
+		for _, expected := range []string{
+			"field text name 10,10 280x22",
+			"field text notes 10,37 280x66",
+			"field checkbox agree 10,135 12x12",
+			"field radio plan 10,152 12x12",
+			"field radio plan 27,152 12x12",
+			"field signature signature 10,196 280x48",
+		} {
+			if !strings.Contains(calls, expected) {
+				t.Errorf("expected %q in:\n%s", expected, calls)
+			}
+		}
+	})
+
+	t.Run("writes the form", func(t *testing.T) {
+		render := func() []byte {
+			var buf bytes.Buffer
+			if err := RenderPDF(&buf, page(), Deterministic()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return buf.Bytes()
+		}
+
+		data := render()
+		checkXref(t, data)
+		if !bytes.Equal(data, render()) {
+			t.Error("expected identical output in deterministic mode")
+		}
+
+		output := string(data)
+		for _, expected := range []string{
+			"/AcroForm <</Fields [",
+			"/DA (/Helv 0 Tf 0 g) /SigFlags 1>>",
+			"/FT /Tx /T (name) /TU (Full name) /MaxLen 20 /Ff 2 /V (Ada \\(Countess\\)) /DV (Ada \\(Countess\\)) /DA (/Helv 10.00 Tf 0.000 0.000 0.000 rg) /Q 0",
+			"/FT /Tx /T (notes) /Ff 4096 /V (first\nsecond)",
+			"/Q 2",
+			`/AA <</F <</S /JavaScript /JS (AFNumber_Format\(2, 0, 0, 0, "$", true\);)>> /K <</S /JavaScript /JS (AFNumber_Keystroke\(2, 0, 0, 0, "$", true\);)>>>>`,
+			"/FT /Btn /T (agree) /Ff 0 /V /Yes /DV /Yes /AS /Yes",
+			"/FT /Btn /Ff 49152 /T (plan) /V /pro /DV /pro /Kids [",
+			"/AS /Off /MK <</CA (l)>> /AP <</N <</basic ",
+			"/AS /pro /MK <</CA (l)>> /AP <</N <</pro ",
+			"/FT /Ch /T (country) /Opt [(FR) (DE)] /Ff 131073 /V (DE)",
+			"/FT /Sig /T (signature)",
+			"(Ada \\(Countess\\)) Tj",
+			"(first) Tj",
+			"(second) Tj",
+			"/BaseFont /ZapfDingbats",
+		} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("expected %s in the PDF", expected)
+			}
+		}
+		if n := strings.Count(output, "/Subtype /Widget"); n != 8 {
+			t.Errorf("expected 8 widgets, got %d", n)
+		}
+
+		f, err := parsePDF(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if annots := strings.Count(string(f.objects[f.pages()[0]]), " 0 R"); annots < 8 {
+			t.Errorf("expected the widgets in the annotations of the page, got %s", f.objects[f.pages()[0]])
+		}
+	})
+
+	t.Run("reports invalid fields", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			field *Node
+			err   string
+		}{
+			{"without name", TextField(""), "text field without a name"},
+			{"dotted name", Checkbox("a.b"), `form field "a.b": names can't contain dots`},
+			{"used twice", Box(Children(TextField("a"), Checkbox("a"))), `form field "a" is used twice`},
+			{"radio with a text field", Box(Children(RadioButton("a", "x"), TextField("a"))), `form field "a" is used twice`},
+			{"radio without value", RadioButton("plan", ""), `radio button of "plan" without a value`},
+			{"radio values", Box(Children(RadioButton("plan", "x"), RadioButton("plan", "x"))), `radio group "plan" has two buttons with the value "x"`},
+			{"radio checked twice", Box(Children(RadioButton("plan", "x", Checked()), RadioButton("plan", "y", Checked()))), `radio group "plan" has several buttons checked`},
+			{"dropdown without options", Dropdown("country", nil), `dropdown "country" has no options`},
+			{"dropdown value", Dropdown("country", []string{"FR"}, FieldValue("DE")), `dropdown "country" has the value "DE", which is not one of its options`},
+			{"formatted checkbox", Checkbox("agree", Formatted(PercentFormat(1))), `form field "agree": only text fields are formatted`},
+			{"font color", TextField("name", FieldFont(10, "red")), `form field "name": invalid font color`},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				root := Layout(Box(Sizing(Fixed(100), Fixed(100)), Children(tt.field)))
+				err := RenderToPDF(&bytes.Buffer{}, root)
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected %q, got %v", tt.err, err)
+				}
+			})
+		}
+
+		err := RenderPDF(&bytes.Buffer{}, Layout(Box(Sizing(Fixed(100), Fixed(100)), Children(TextField("name")))), PDFA2B)
+		if err == nil || !strings.Contains(err.Error(), `page 1: Box/Box[0]: form field "name" is drawn with standard fonts`) {
+			t.Errorf("expected the field reported for PDF/A, got %v", err)
+		}
+	})
+
+	t.Run("ignores field options on other nodes", func(t *testing.T) {
+		if n := Box(FieldValue("x"), Checked()); n.Field != nil {
+			t.Error("expected no field")
+		}
+	})
+}
+
+func TestPDFWinAnsi(t *testing.T) {
+	if s := pdfWinAnsi(`a(b)\ é€ж`); s != `(a\(b\)\\ \351\200?)` {
+		t.Errorf("unexpected string %s", s)
+	}
+}
@@ -0,0 +1,1100 @@
+package sahar
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format represents the serialisation format of a document.
+// It can be JSONFormat or YAMLFormat.
+type Format int
+
+const (
+	JSONFormat Format = iota
+	YAMLFormat
+)
+
+func (f Format) String() string {
+	switch f {
+	case JSONFormat:
+		return "JSON"
+	case YAMLFormat:
+		return "YAML"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// FormatFromPath returns the format of a document based on its extension: .json, .yaml or .yml
+func FormatFromPath(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSONFormat, nil
+	case ".yaml", ".yml":
+		return YAMLFormat, nil
+	default:
+		return 0, fmt.Errorf("unknown document format for %q, expected .json, .yaml or .yml", path)
+	}
+}
+
+//go:embed schema.json
+var schema []byte
+
+// Schema returns the JSON schema of the document format. The same schema applies to
+// YAML documents, since they are decoded to the same values
+func Schema() []byte {
+	return bytes.Clone(schema)
+}
+
+// DocumentError is a validation error in a document, pointing to the offending value
+type DocumentError struct {
+	Path    string // The path of the value, for example $.children[2].width
+	Message string
+}
+
+func (e *DocumentError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Load reads the document at path, using its extension to pick the format, and
+// returns the node tree ready for Layout
+func Load(path string) (*Node, error) {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	root, err := Decode(file, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	return root, nil
+}
+
+// Decode reads a document in the given format and returns its node tree.
+// All the problems found in the document are returned as DocumentError values joined
+// together, so an editor can show them at once
+func Decode(reader io.Reader, format Format) (*Node, error) {
+	value, err := readDocument(reader, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoder documentDecoder
+	root := decoder.node(value, "$")
+	if len(decoder.errs) > 0 {
+		return nil, errors.Join(decoder.errs...)
+	}
+	return root, nil
+}
+
+// readDocument reads a document in the given format as generic values
+func readDocument(reader io.Reader, format Format) (any, error) {
+	var value any
+
+	switch format {
+	case JSONFormat:
+		decoder := json.NewDecoder(reader)
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid JSON document: %w", err)
+		}
+	case YAMLFormat:
+		if err := yaml.NewDecoder(reader).Decode(&value); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("invalid YAML document: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown document format %s", format)
+	}
+
+	return value, nil
+}
+
+// Encode writes the node tree as a document in the given format.
+// Only what describes the document is written, values calculated by Layout such as the
+// position and the size of Fit and Grow nodes are left out
+func Encode(writer io.Writer, root *Node, format Format) error {
+	if root == nil {
+		return fmt.Errorf("there is no node to encode")
+	}
+
+	document := encodeNode(root)
+
+	switch format {
+	case JSONFormat:
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(document)
+	case YAMLFormat:
+		encoder := yaml.NewEncoder(writer)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		return fmt.Errorf("unknown document format %s", format)
+	}
+}
+
+//
+// Encoding
+//
+
+// nodeDocument is the serialised form of a node, the order of the fields is the
+// order of the keys in the document
+type nodeDocument struct {
+	Type            string          `json:"type" yaml:"type"`
+	TableOfContents bool            `json:"tableOfContents,omitempty" yaml:"tableOfContents,omitempty"`
+	Text            string          `json:"text,omitempty" yaml:"text,omitempty"`
+	Src             string          `json:"src,omitempty" yaml:"src,omitempty"`
+	Width           any             `json:"width,omitempty" yaml:"width,omitempty"`
+	Height          any             `json:"height,omitempty" yaml:"height,omitempty"`
+	Direction       string          `json:"direction,omitempty" yaml:"direction,omitempty"`
+	Horizontal      string          `json:"horizontal,omitempty" yaml:"horizontal,omitempty"`
+	Vertical        string          `json:"vertical,omitempty" yaml:"vertical,omitempty"`
+	Padding         any             `json:"padding,omitempty" yaml:"padding,omitempty"`
+	Margin          any             `json:"margin,omitempty" yaml:"margin,omitempty"`
+	ChildGap        float64         `json:"childGap,omitempty" yaml:"childGap,omitempty"`
+	Border          float64         `json:"border,omitempty" yaml:"border,omitempty"`
+	BorderColor     string          `json:"borderColor,omitempty" yaml:"borderColor,omitempty"`
+	BackgroundColor string          `json:"backgroundColor,omitempty" yaml:"backgroundColor,omitempty"`
+	FontType        string          `json:"fontType,omitempty" yaml:"fontType,omitempty"`
+	FontSize        float64         `json:"fontSize,omitempty" yaml:"fontSize,omitempty"`
+	FontColor       string          `json:"fontColor,omitempty" yaml:"fontColor,omitempty"`
+	Columns         []any           `json:"columns,omitempty" yaml:"columns,omitempty"`
+	Rows            []any           `json:"rows,omitempty" yaml:"rows,omitempty"`
+	ColumnGap       float64         `json:"columnGap,omitempty" yaml:"columnGap,omitempty"`
+	RowGap          float64         `json:"rowGap,omitempty" yaml:"rowGap,omitempty"`
+	Cell            *cellDocument   `json:"cell,omitempty" yaml:"cell,omitempty"`
+	Shrink          *float64        `json:"shrink,omitempty" yaml:"shrink,omitempty"`
+	Link            string          `json:"link,omitempty" yaml:"link,omitempty"`
+	Anchor          string          `json:"anchor,omitempty" yaml:"anchor,omitempty"`
+	Bookmark        string          `json:"bookmark,omitempty" yaml:"bookmark,omitempty"`
+	BookmarkLevel   int             `json:"bookmarkLevel,omitempty" yaml:"bookmarkLevel,omitempty"`
+	Role            string          `json:"role,omitempty" yaml:"role,omitempty"`
+	Alt             string          `json:"alt,omitempty" yaml:"alt,omitempty"`
+	Field           *fieldDocument  `json:"field,omitempty" yaml:"field,omitempty"`
+	Attachment      *fileDocument   `json:"attachment,omitempty" yaml:"attachment,omitempty"`
+	Children        []*nodeDocument `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// sizeDocument is the serialised form of a size with a min or max value
+type sizeDocument struct {
+	Type  string   `json:"type" yaml:"type"`
+	Value *float64 `json:"value,omitempty" yaml:"value,omitempty"`
+	Min   *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+// cellDocument is the serialised form of the placement of a node inside a grid
+type cellDocument struct {
+	Row        *int `json:"row,omitempty" yaml:"row,omitempty"`
+	Column     *int `json:"column,omitempty" yaml:"column,omitempty"`
+	RowSpan    int  `json:"rowSpan,omitempty" yaml:"rowSpan,omitempty"`
+	ColumnSpan int  `json:"columnSpan,omitempty" yaml:"columnSpan,omitempty"`
+}
+
+// fieldDocument is the serialised form of a form field
+type fieldDocument struct {
+	Kind      string          `json:"kind" yaml:"kind"`
+	Name      string          `json:"name" yaml:"name"`
+	Value     string          `json:"value,omitempty" yaml:"value,omitempty"`
+	Checked   bool            `json:"checked,omitempty" yaml:"checked,omitempty"`
+	Options   []string        `json:"options,omitempty" yaml:"options,omitempty"`
+	Multiline bool            `json:"multiline,omitempty" yaml:"multiline,omitempty"`
+	MaxLength int             `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	Required  bool            `json:"required,omitempty" yaml:"required,omitempty"`
+	ReadOnly  bool            `json:"readOnly,omitempty" yaml:"readOnly,omitempty"`
+	Tooltip   string          `json:"tooltip,omitempty" yaml:"tooltip,omitempty"`
+	FontSize  float64         `json:"fontSize,omitempty" yaml:"fontSize,omitempty"`
+	FontColor string          `json:"fontColor,omitempty" yaml:"fontColor,omitempty"`
+	Format    *formatDocument `json:"format,omitempty" yaml:"format,omitempty"`
+}
+
+// formatDocument is the serialised form of the format of a text field
+type formatDocument struct {
+	Type     string `json:"type" yaml:"type"`
+	Decimals int    `json:"decimals,omitempty" yaml:"decimals,omitempty"`
+	Currency string `json:"currency,omitempty" yaml:"currency,omitempty"`
+	Layout   string `json:"layout,omitempty" yaml:"layout,omitempty"`
+}
+
+// fileDocument is the serialised form of an attachment, with the data in base64 and
+// the modification date in RFC 3339
+type fileDocument struct {
+	Name             string `json:"name" yaml:"name"`
+	MIMEType         string `json:"mimeType,omitempty" yaml:"mimeType,omitempty"`
+	Description      string `json:"description,omitempty" yaml:"description,omitempty"`
+	Relationship     string `json:"relationship,omitempty" yaml:"relationship,omitempty"`
+	Data             string `json:"data,omitempty" yaml:"data,omitempty"`
+	ModificationDate string `json:"modificationDate,omitempty" yaml:"modificationDate,omitempty"`
+}
+
+var (
+	documentTypes = map[string]Type{
+		"box":   BoxType,
+		"text":  TextType,
+		"image": ImageType,
+		"grid":  GridType,
+	}
+	documentDirections = map[string]direction{
+		"leftToRight": LeftToRight,
+		"topToBottom": TopToBottom,
+	}
+	documentHorizontals = map[string]Horizontal{
+		"left":   Left,
+		"center": Center,
+		"right":  Right,
+	}
+	documentVerticals = map[string]Vertical{
+		"top":    Top,
+		"middle": Middle,
+		"bottom": Bottom,
+	}
+	documentFieldKinds = map[string]FieldKind{
+		"text":      FieldText,
+		"checkbox":  FieldCheckbox,
+		"radio":     FieldRadio,
+		"dropdown":  FieldDropdown,
+		"signature": FieldSignature,
+	}
+	documentFormats = map[string]bool{
+		"number":  true,
+		"percent": true,
+		"date":    true,
+	}
+)
+
+// documentName returns the key of value in names
+func documentName[T comparable](names map[string]T, value T) string {
+	for name, v := range names {
+		if v == value {
+			return name
+		}
+	}
+	return ""
+}
+
+func encodeNode(node *Node) *nodeDocument {
+	document := &nodeDocument{
+		Type:            documentName(documentTypes, node.Type),
+		Width:           encodeSize(node.Width),
+		Height:          encodeSize(node.Height),
+		Padding:         encodeSides(node.Padding),
+		Margin:          encodeSides(node.Margin),
+		ChildGap:        node.ChildGap,
+		Border:          node.Border,
+		BorderColor:     node.BorderColor,
+		BackgroundColor: node.BackgroundColor,
+		FontType:        node.FontType,
+		FontSize:        node.FontSize,
+		FontColor:       node.FontColor,
+		ColumnGap:       node.ColumnGap,
+		RowGap:          node.RowGap,
+		Link:            node.Link,
+		Anchor:          node.Anchor,
+		Bookmark:        node.Bookmark,
+		BookmarkLevel:   node.BookmarkLevel,
+		Role:            string(node.Role),
+		Alt:             node.Alt,
+	}
+
+	switch node.Type {
+	case TextType:
+		document.Text = node.Value
+	case ImageType:
+		document.Src = node.Value
+	}
+
+	// Only the values which are not the default are written
+	if node.Direction != LeftToRight {
+		document.Direction = documentName(documentDirections, node.Direction)
+	}
+	if node.Horizontal != Left {
+		document.Horizontal = documentName(documentHorizontals, node.Horizontal)
+	}
+	if node.Vertical != Top {
+		document.Vertical = documentName(documentVerticals, node.Vertical)
+	}
+	if node.Shrink != 1 {
+		document.Shrink = &node.Shrink
+	}
+
+	for _, track := range node.Columns {
+		document.Columns = append(document.Columns, encodeTrack(track))
+	}
+	for _, track := range node.Rows {
+		document.Rows = append(document.Rows, encodeTrack(track))
+	}
+
+	if node.Cell.Placed || node.Cell.RowSpan != 0 || node.Cell.ColumnSpan != 0 {
+		document.Cell = &cellDocument{RowSpan: node.Cell.RowSpan, ColumnSpan: node.Cell.ColumnSpan}
+		if node.Cell.Placed {
+			document.Cell.Row = &node.Cell.Row
+			document.Cell.Column = &node.Cell.Column
+		}
+	}
+
+	if node.Field != nil {
+		document.Field = encodeField(*node.Field)
+	}
+	if node.Attachment != nil {
+		document.Attachment = encodeFile(*node.Attachment)
+	}
+
+	// The entries of a table of contents are filled by the renderers
+	if node.contents != nil {
+		document.TableOfContents = true
+		return document
+	}
+
+	for _, child := range node.Children {
+		document.Children = append(document.Children, encodeNode(child))
+	}
+
+	return document
+}
+
+func encodeField(f FormField) *fieldDocument {
+	document := &fieldDocument{
+		Kind:      documentName(documentFieldKinds, f.Kind),
+		Name:      f.Name,
+		Value:     f.Value,
+		Checked:   f.Checked,
+		Options:   f.Options,
+		Multiline: f.Multiline,
+		MaxLength: f.MaxLength,
+		Required:  f.Required,
+		ReadOnly:  f.ReadOnly,
+		Tooltip:   f.Tooltip,
+		FontSize:  f.FontSize,
+		FontColor: f.FontColor,
+	}
+
+	switch f.Format.kind {
+	case "number":
+		document.Format = &formatDocument{Type: "number", Decimals: f.Format.decimals, Currency: f.Format.text}
+	case "percent":
+		document.Format = &formatDocument{Type: "percent", Decimals: f.Format.decimals}
+	case "date":
+		document.Format = &formatDocument{Type: "date", Layout: f.Format.text}
+	}
+
+	return document
+}
+
+func encodeFile(a Attachment) *fileDocument {
+	document := &fileDocument{
+		Name:         a.Name,
+		MIMEType:     a.MIMEType,
+		Description:  a.Description,
+		Relationship: string(a.Relationship),
+		Data:         base64.StdEncoding.EncodeToString(a.Data),
+	}
+	if !a.ModificationDate.IsZero() {
+		document.ModificationDate = a.ModificationDate.Format(time.RFC3339Nano)
+	}
+	return document
+}
+
+// encodeSize returns nil for the default Fit size, "grow" for Grow, a number for Fixed
+// and an object when min or max are set
+func encodeSize(size Size) any {
+	hasMin := size.Min != minNotSet
+	hasMax := size.Max != maxNotSet
+
+	if !hasMin && !hasMax {
+		switch size.Type {
+		case FitType:
+			return nil
+		case GrowType:
+			return "grow"
+		default:
+			return size.Value
+		}
+	}
+
+	document := sizeDocument{}
+	switch size.Type {
+	case FitType:
+		document.Type = "fit"
+	case GrowType:
+		document.Type = "grow"
+	default:
+		document.Type = "fixed"
+		document.Value = &size.Value
+	}
+	if hasMin {
+		document.Min = &size.Min
+	}
+	if hasMax {
+		document.Max = &size.Max
+	}
+	return document
+}
+
+// encodeSides returns nil when all sides are 0, a number when they are the same
+// and a list of top, right, bottom and left otherwise
+func encodeSides(sides [4]float64) any {
+	if sides[0] == sides[1] && sides[1] == sides[2] && sides[2] == sides[3] {
+		if sides[0] == 0 {
+			return nil
+		}
+		return sides[0]
+	}
+	return sides[:]
+}
+
+// encodeTrack returns a number for fixed tracks, "fit" for fit tracks and
+// strings like "1fr" and "25%" for fraction and percent tracks
+func encodeTrack(track Track) any {
+	value := strconv.FormatFloat(track.Value, 'f', -1, 64)
+	switch track.Type {
+	case FitTrackType:
+		return "fit"
+	case FractionTrackType:
+		return value + "fr"
+	case PercentTrackType:
+		return value + "%"
+	default:
+		return track.Value
+	}
+}
+
+//
+// Decoding
+//
+
+// documentKeys lists the keys allowed for every node type
+var documentKeys = func() map[Type]map[string]bool {
+	common := []string{"type", "width", "height", "horizontal", "vertical", "padding", "margin", "border", "borderColor", "backgroundColor", "cell", "shrink", "link", "anchor", "bookmark", "bookmarkLevel", "role", "alt", "attachment"}
+	container := []string{"direction", "childGap", "children"}
+
+	keys := map[Type][]string{
+		BoxType:   append([]string{"tableOfContents", "field"}, container...),
+		GridType:  append([]string{"columns", "rows", "columnGap", "rowGap"}, container...),
+		TextType:  {"text", "fontType", "fontSize", "fontColor"},
+		ImageType: {"src"},
+	}
+
+	allowed := make(map[Type]map[string]bool)
+	for typ, names := range keys {
+		allowed[typ] = make(map[string]bool)
+		for _, name := range append(common, names...) {
+			allowed[typ][name] = true
+		}
+	}
+	return allowed
+}()
+
+// documentDecoder converts the generic values of a decoded document to nodes
+// and collects every problem found on the way
+type documentDecoder struct {
+	errs []error
+}
+
+func (d *documentDecoder) errorf(path, format string, args ...any) {
+	d.errs = append(d.errs, &DocumentError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *documentDecoder) node(value any, path string) *Node {
+	object, ok := d.object(value, path)
+	if !ok {
+		return nil
+	}
+
+	name, ok := decodeEnum(d, object["type"], path+".type", documentTypes)
+	if !ok {
+		return nil
+	}
+
+	var node *Node
+	switch documentTypes[name] {
+	case BoxType:
+		node = Box()
+		if object["tableOfContents"] == true {
+			node = TableOfContents(nil)
+		}
+	case GridType:
+		node = Grid()
+	case TextType:
+		node = Text("")
+	case ImageType:
+		node = Image("")
+	}
+
+	for _, key := range sortedKeys(object) {
+		if !documentKeys[node.Type][key] {
+			d.errorf(path+"."+key, "unknown field for %s node", name)
+			continue
+		}
+		d.field(node, key, object[key], path+"."+key)
+	}
+
+	if node.Type == TextType {
+		node.FontLineHeight = node.FontSize / 2
+	}
+	if node.Type == ImageType && node.Value == "" {
+		d.errorf(path+".src", "image source is required")
+	}
+	if node.contents != nil && object["children"] != nil {
+		d.errorf(path+".children", "a table of contents is filled with the bookmarks, it has no children")
+	}
+
+	return node
+}
+
+// field sets the value of a single key on the node
+func (d *documentDecoder) field(node *Node, key string, value any, path string) {
+	switch key {
+	case "type":
+		// already handled by node
+	case "tableOfContents":
+		d.boolean(value, path)
+	case "text", "src":
+		node.Value, _ = d.string(value, path)
+	case "width":
+		node.Width = d.size(value, path)
+	case "height":
+		node.Height = d.size(value, path)
+	case "direction":
+		if name, ok := decodeEnum(d, value, path, documentDirections); ok {
+			node.Direction = documentDirections[name]
+		}
+	case "horizontal":
+		if name, ok := decodeEnum(d, value, path, documentHorizontals); ok {
+			node.Horizontal = documentHorizontals[name]
+		}
+	case "vertical":
+		if name, ok := decodeEnum(d, value, path, documentVerticals); ok {
+			node.Vertical = documentVerticals[name]
+		}
+	case "padding":
+		node.Padding = d.sides(value, path)
+	case "margin":
+		node.Margin = d.sides(value, path)
+	case "childGap":
+		node.ChildGap, _ = d.positive(value, path)
+	case "border":
+		node.Border, _ = d.positive(value, path)
+	case "borderColor":
+		node.BorderColor = d.color(value, path)
+	case "backgroundColor":
+		node.BackgroundColor = d.color(value, path)
+	case "fontType":
+		node.FontType, _ = d.string(value, path)
+	case "fontSize":
+		node.FontSize, _ = d.positive(value, path)
+	case "fontColor":
+		node.FontColor = d.color(value, path)
+	case "columns":
+		node.Columns = d.tracks(value, path)
+	case "rows":
+		node.Rows = d.tracks(value, path)
+	case "columnGap":
+		node.ColumnGap, _ = d.positive(value, path)
+	case "rowGap":
+		node.RowGap, _ = d.positive(value, path)
+	case "cell":
+		node.Cell = d.cell(value, path)
+	case "shrink":
+		node.Shrink, _ = d.positive(value, path)
+	case "link":
+		node.Link, _ = d.string(value, path)
+	case "anchor":
+		node.Anchor, _ = d.string(value, path)
+	case "bookmark":
+		node.Bookmark, _ = d.string(value, path)
+	case "bookmarkLevel":
+		node.BookmarkLevel, _ = d.integer(value, path)
+	case "role":
+		if name, ok := decodeEnum(d, value, path, roles); ok {
+			node.Role = roles[name]
+		}
+	case "alt":
+		node.Alt, _ = d.string(value, path)
+	case "field":
+		node.Field = d.formField(value, path)
+	case "attachment":
+		node.Attachment = d.attachment(value, path)
+	case "children":
+		children, ok := value.([]any)
+		if !ok {
+			d.errorf(path, "expected a list of nodes, got %s", describeValue(value))
+			return
+		}
+		for i, value := range children {
+			if child := d.node(value, fmt.Sprintf("%s[%d]", path, i)); child != nil {
+				child.configureNode(node)
+			}
+		}
+	}
+}
+
+func (d *documentDecoder) object(value any, path string) (map[string]any, bool) {
+	object, ok := value.(map[string]any)
+	if !ok {
+		d.errorf(path, "expected an object, got %s", describeValue(value))
+	}
+	return object, ok
+}
+
+func (d *documentDecoder) string(value any, path string) (string, bool) {
+	s, ok := value.(string)
+	if !ok {
+		d.errorf(path, "expected a string, got %s", describeValue(value))
+	}
+	return s, ok
+}
+
+func (d *documentDecoder) boolean(value any, path string) (bool, bool) {
+	b, ok := value.(bool)
+	if !ok {
+		d.errorf(path, "expected a boolean, got %s", describeValue(value))
+	}
+	return b, ok
+}
+
+func (d *documentDecoder) number(value any, path string) (float64, bool) {
+	var n float64
+	var err error
+
+	switch v := value.(type) {
+	case json.Number:
+		n, err = v.Float64()
+	case int:
+		n = float64(v)
+	case float64:
+		n = v
+	default:
+		d.errorf(path, "expected a number, got %s", describeValue(value))
+		return 0, false
+	}
+
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		d.errorf(path, "expected a finite number, got %v", value)
+		return 0, false
+	}
+	return n, true
+}
+
+func (d *documentDecoder) positive(value any, path string) (float64, bool) {
+	n, ok := d.number(value, path)
+	if ok && n < 0 {
+		d.errorf(path, "expected a number greater than or equal to 0, got %v", n)
+		return 0, false
+	}
+	return n, ok
+}
+
+func (d *documentDecoder) integer(value any, path string) (int, bool) {
+	n, ok := d.positive(value, path)
+	if ok && n != math.Trunc(n) {
+		d.errorf(path, "expected an integer, got %v", n)
+		return 0, false
+	}
+	return int(n), ok
+}
+
+func (d *documentDecoder) color(value any, path string) string {
+	s, ok := d.string(value, path)
+	if !ok {
+		return ""
+	}
+	if _, _, _, err := hexToRGB(s, ""); err != nil {
+		d.errorf(path, "expected a hex color like #RRGGBB, got %q", s)
+		return ""
+	}
+	return s
+}
+
+// decodeEnum checks that value is one of the names and returns it
+func decodeEnum[T any](d *documentDecoder, value any, path string, names map[string]T) (string, bool) {
+	s, ok := d.string(value, path)
+	if !ok {
+		return "", false
+	}
+	if _, ok := names[s]; !ok {
+		list := make([]string, 0, len(names))
+		for name := range names {
+			list = append(list, strconv.Quote(name))
+		}
+		sort.Strings(list)
+		d.errorf(path, "expected one of %s, got %q", strings.Join(list, ", "), s)
+		return "", false
+	}
+	return s, true
+}
+
+// size accepts a number for Fixed, "fit" or "grow", or an object with type, value, min and max
+func (d *documentDecoder) size(value any, path string) Size {
+	size := Size{Type: FitType, Min: minNotSet, Max: maxNotSet}
+
+	switch v := value.(type) {
+	case string:
+		switch v {
+		case "fit":
+		case "grow":
+			size.Type = GrowType
+		default:
+			d.errorf(path, "expected a number, \"fit\" or \"grow\", got %q", v)
+		}
+	case map[string]any:
+		name, ok := decodeEnum(d, v["type"], path+".type", map[string]SizeType{"fit": FitType, "fixed": FixedType, "grow": GrowType})
+		if !ok {
+			return size
+		}
+		for _, key := range sortedKeys(v) {
+			value := v[key]
+			switch key {
+			case "type":
+			case "value":
+				size.Value, _ = d.positive(value, path+".value")
+			case "min":
+				size.Min, _ = d.positive(value, path+".min")
+			case "max":
+				size.Max, _ = d.positive(value, path+".max")
+			default:
+				d.errorf(path+"."+key, "unknown field for size")
+			}
+		}
+		switch name {
+		case "fixed":
+			size.Type = FixedType
+			if _, ok := v["value"]; !ok {
+				d.errorf(path+".value", "fixed size requires a value")
+			}
+		case "grow":
+			size.Type = GrowType
+		}
+		if name != "fixed" && v["value"] != nil {
+			d.errorf(path+".value", "only fixed sizes have a value")
+		}
+	default:
+		if n, ok := d.positive(value, path); ok {
+			size.Type = FixedType
+			size.Value = n
+		}
+	}
+
+	return size
+}
+
+// sides accepts a number for all sides or a list of top, right, bottom and left
+func (d *documentDecoder) sides(value any, path string) [4]float64 {
+	var sides [4]float64
+
+	list, ok := value.([]any)
+	if !ok {
+		n, _ := d.positive(value, path)
+		return [4]float64{n, n, n, n}
+	}
+
+	if len(list) != 4 {
+		d.errorf(path, "expected a number or a list of 4 numbers (top, right, bottom, left), got %d values", len(list))
+		return sides
+	}
+	for i, value := range list {
+		sides[i], _ = d.positive(value, fmt.Sprintf("%s[%d]", path, i))
+	}
+	return sides
+}
+
+// tracks accepts a list where every track is a number for a fixed track, "fit",
+// or a string like "1fr" or "25%"
+func (d *documentDecoder) tracks(value any, path string) []Track {
+	list, ok := value.([]any)
+	if !ok {
+		d.errorf(path, "expected a list of tracks, got %s", describeValue(value))
+		return nil
+	}
+
+	tracks := make([]Track, 0, len(list))
+	for i, value := range list {
+		path := fmt.Sprintf("%s[%d]", path, i)
+
+		s, ok := value.(string)
+		if !ok {
+			if n, ok := d.positive(value, path); ok {
+				tracks = append(tracks, FixedTrack(n))
+			}
+			continue
+		}
+
+		if s == "fit" {
+			tracks = append(tracks, FitTrack())
+			continue
+		}
+
+		var track func(float64) Track
+		var number string
+		if number, ok = strings.CutSuffix(s, "fr"); ok {
+			track = FractionTrack
+		} else if number, ok = strings.CutSuffix(s, "%"); ok {
+			track = PercentTrack
+		}
+
+		n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+		if track == nil || err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+			d.errorf(path, "expected a number, \"fit\", a fraction like \"1fr\" or a percent like \"25%%\", got %q", s)
+			continue
+		}
+		tracks = append(tracks, track(n))
+	}
+	return tracks
+}
+
+// cell accepts an object with row and column, and optionally rowSpan and columnSpan
+func (d *documentDecoder) cell(value any, path string) GridCell {
+	var cell GridCell
+
+	object, ok := d.object(value, path)
+	if !ok {
+		return cell
+	}
+
+	_, hasRow := object["row"]
+	_, hasColumn := object["column"]
+	if hasRow != hasColumn {
+		d.errorf(path, "row and column must be set together")
+	}
+	cell.Placed = hasRow && hasColumn
+
+	for _, key := range sortedKeys(object) {
+		value, path := object[key], path+"."+key
+		switch key {
+		case "row":
+			cell.Row, _ = d.integer(value, path)
+		case "column":
+			cell.Column, _ = d.integer(value, path)
+		case "rowSpan":
+			cell.RowSpan, _ = d.integer(value, path)
+		case "columnSpan":
+			cell.ColumnSpan, _ = d.integer(value, path)
+		default:
+			d.errorf(path, "unknown field for cell")
+		}
+	}
+
+	return cell
+}
+
+// formField accepts an object with the kind and the name of the field, and optionally
+// its value, options, limits and format
+func (d *documentDecoder) formField(value any, path string) *FormField {
+	object, ok := d.object(value, path)
+	if !ok {
+		return nil
+	}
+
+	// The field is only checked when its values are valid
+	errs := len(d.errs)
+
+	var f FormField
+	if name, ok := decodeEnum(d, object["kind"], path+".kind", documentFieldKinds); ok {
+		f.Kind = documentFieldKinds[name]
+	}
+	if _, ok := object["name"]; !ok {
+		d.errorf(path+".name", "field name is required")
+	}
+
+	for _, key := range sortedKeys(object) {
+		value, path := object[key], path+"."+key
+		switch key {
+		case "kind":
+		case "name":
+			f.Name, _ = d.string(value, path)
+		case "value":
+			f.Value, _ = d.string(value, path)
+		case "checked":
+			f.Checked, _ = d.boolean(value, path)
+		case "options":
+			options, ok := value.([]any)
+			if !ok {
+				d.errorf(path, "expected a list of strings, got %s", describeValue(value))
+				continue
+			}
+			for i, option := range options {
+				if s, ok := d.string(option, fmt.Sprintf("%s[%d]", path, i)); ok {
+					f.Options = append(f.Options, s)
+				}
+			}
+		case "multiline":
+			f.Multiline, _ = d.boolean(value, path)
+		case "maxLength":
+			f.MaxLength, _ = d.integer(value, path)
+		case "required":
+			f.Required, _ = d.boolean(value, path)
+		case "readOnly":
+			f.ReadOnly, _ = d.boolean(value, path)
+		case "tooltip":
+			f.Tooltip, _ = d.string(value, path)
+		case "fontSize":
+			f.FontSize, _ = d.positive(value, path)
+		case "fontColor":
+			f.FontColor = d.color(value, path)
+		case "format":
+			f.Format = d.fieldFormat(value, path)
+		default:
+			d.errorf(path, "unknown field for form field")
+		}
+	}
+
+	// As with Dropdown, the first option is chosen unless the value chooses another one
+	if f.Kind == FieldDropdown && f.Value == "" && len(f.Options) > 0 {
+		f.Value = f.Options[0]
+	}
+	if len(d.errs) == errs {
+		if err := f.check(); err != nil {
+			d.errorf(path, "%v", err)
+		}
+	}
+
+	return &f
+}
+
+// fieldFormat accepts an object with the type of the format, number, percent or date,
+// and its decimals, currency or layout
+func (d *documentDecoder) fieldFormat(value any, path string) FieldFormat {
+	object, ok := d.object(value, path)
+	if !ok {
+		return FieldFormat{}
+	}
+
+	typ, ok := decodeEnum(d, object["type"], path+".type", documentFormats)
+	if !ok {
+		return FieldFormat{}
+	}
+
+	keys := map[string]bool{"type": true, "decimals": typ != "date", "currency": typ == "number", "layout": typ == "date"}
+	format := FieldFormat{kind: typ}
+	for _, key := range sortedKeys(object) {
+		value, path := object[key], path+"."+key
+		if !keys[key] {
+			d.errorf(path, "unknown field for %s format", typ)
+			continue
+		}
+		switch key {
+		case "decimals":
+			format.decimals, _ = d.integer(value, path)
+		case "currency", "layout":
+			format.text, _ = d.string(value, path)
+		}
+	}
+
+	if typ == "date" && format.text == "" {
+		d.errorf(path+".layout", "date layout is required")
+	}
+	return format
+}
+
+// attachment accepts an object with the name of the file, and optionally its MIME
+// type, description, relationship, data in base64 and modification date in RFC 3339
+func (d *documentDecoder) attachment(value any, path string) *Attachment {
+	object, ok := d.object(value, path)
+	if !ok {
+		return nil
+	}
+
+	var a Attachment
+	if _, ok := object["name"]; !ok {
+		d.errorf(path+".name", "attachment name is required")
+	}
+
+	for _, key := range sortedKeys(object) {
+		value, path := object[key], path+"."+key
+		switch key {
+		case "name":
+			a.Name, _ = d.string(value, path)
+		case "mimeType":
+			a.MIMEType, _ = d.string(value, path)
+		case "description":
+			a.Description, _ = d.string(value, path)
+		case "relationship":
+			relationship, _ := d.string(value, path)
+			a.Relationship = Relationship(relationship)
+		case "data":
+			if s, ok := d.string(value, path); ok {
+				data, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					d.errorf(path, "expected base64 data: %v", err)
+				}
+				a.Data = data
+			}
+		case "modificationDate":
+			// YAML reads unquoted timestamps as times
+			if date, ok := value.(time.Time); ok {
+				a.ModificationDate = date
+				continue
+			}
+			if s, ok := d.string(value, path); ok {
+				date, err := time.Parse(time.RFC3339, s)
+				if err != nil {
+					d.errorf(path, "expected an RFC 3339 date like 2024-01-02T15:04:05Z, got %q", s)
+				}
+				a.ModificationDate = date
+			}
+		default:
+			d.errorf(path, "unknown field for attachment")
+		}
+	}
+
+	if a.Name != "" {
+		if err := a.check(); err != nil {
+			d.errorf(path+".relationship", "%v", err)
+		}
+	}
+
+	return &a
+}
+
+// sortedKeys returns the keys of the object in order, so the errors are always reported in the same order
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// describeValue returns the kind of a decoded value for error messages
+func describeValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "nothing"
+	case string:
+		return fmt.Sprintf("the string %q", v)
+	case bool:
+		return fmt.Sprintf("the boolean %t", v)
+	case json.Number, int, float64:
+		return fmt.Sprintf("the number %v", v)
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "an object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
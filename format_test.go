@@ -0,0 +1,356 @@
+package sahar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// documentTree builds a tree using every field of the document format
+func documentTree() *Node {
+	return Box(
+		Sizing(A4()...),
+		Direction(TopToBottom),
+		Padding(10, 20, 30, 40),
+		ChildGap(5),
+		BackgroundColor("#FFFFFF"),
+		Children(
+			Text("Invoice", FontType("Arial"), FontSize(24), FontColor("#333333"), Margin(5, 5, 5, 5), Shrink(0), Anchor("top"), Bookmark("Invoice", 0), Tag(RoleHeading1)),
+			Grid(
+				Sizing(Grow(), Fit(Min(10), Max(200))),
+				Columns(FixedTrack(100), FitTrack(), FractionTrack(1.5), PercentTrack(25)),
+				Rows(FitTrack()),
+				ColumnGap(4),
+				RowGap(2),
+				Alignment(Center, Bottom),
+				Border(1),
+				BorderColor("#000000"),
+				Children(
+					Text("Item", Cell(0, 1), Span(1, 2)),
+					Image("logo.png", Sizing(Fixed(50), Fixed(20)), Span(2, 1), Link("https://example.com"), Bookmark("Logo", 1), AltText("Logo")),
+				),
+			),
+			TextField("total", Formatted(NumberFormat(2, "$")), FieldValue("10"), MaxLength(8), Required(), Tooltip("Total")),
+			TextField("due", Formatted(DateFormat("yyyy-mm-dd")), ReadOnly()),
+			Dropdown("currency", []string{"EUR", "USD"}, FieldValue("USD"), FieldFont(9, "#333333")),
+			RadioButton("delivery", "express", Checked()),
+			Text("Data", Attach(Attachment{
+				Name:             "factur-x.xml",
+				MIMEType:         "text/xml",
+				Description:      "Invoice data",
+				Relationship:     RelationData,
+				Data:             []byte("<Invoice/>"),
+				ModificationDate: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+			})),
+		),
+	)
+}
+
+// assertSameTree compares the fields of the trees which are described by the document format
+func assertSameTree(t *testing.T, path string, want, got *Node) {
+	t.Helper()
+
+	clean := func(n *Node) Node {
+		c := *n
+		c.Parent = nil
+		c.Children = nil
+		return c
+	}
+
+	if !reflect.DeepEqual(clean(want), clean(got)) {
+		t.Errorf("%s: expected %+v, got %+v", path, clean(want), clean(got))
+	}
+	if len(want.Children) != len(got.Children) {
+		t.Fatalf("%s: expected %d children, got %d", path, len(want.Children), len(got.Children))
+	}
+	for i := range want.Children {
+		if got.Children[i].Parent != got {
+			t.Errorf("%s: child %d does not point to its parent", path, i)
+		}
+		assertSameTree(t, path+"/"+want.Children[i].Type.String(), want.Children[i], got.Children[i])
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, format := range []Format{JSONFormat, YAMLFormat} {
+		t.Run(format.String()+" round trip", func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, documentTree(), format); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			root, err := Decode(&buf, format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertSameTree(t, "Box", documentTree(), root)
+		})
+	}
+
+	t.Run("encodes short values", func(t *testing.T) {
+		var buf bytes.Buffer
+		root := Box(Sizing(Grow(), Fixed(10)), Padding(3, 3, 3, 3), Children(Text("Hi")))
+		if err := Encode(&buf, root, JSONFormat); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var document map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &document); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if document["width"] != "grow" || document["height"] != 10.0 || document["padding"] != 3.0 {
+			t.Errorf("unexpected document: %v", document)
+		}
+		if _, ok := document["direction"]; ok {
+			t.Error("expected default values to be left out")
+		}
+	})
+
+	t.Run("decodes YAML", func(t *testing.T) {
+		root, err := Decode(strings.NewReader(`
+type: box
+width: 200
+height: grow
+direction: topToBottom
+padding: [1, 2, 3, 4]
+children:
+  - type: text
+    text: Hello
+    fontSize: 12
+  - type: grid
+    columns: [fit, 1fr, "50%", 20]
+`), YAMLFormat)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if root.Width.Type != FixedType || root.Width.Value != 200 || root.Height.Type != GrowType {
+			t.Errorf("unexpected sizes: %+v %+v", root.Width, root.Height)
+		}
+		if root.Direction != TopToBottom || root.Padding != [4]float64{1, 2, 3, 4} {
+			t.Errorf("unexpected direction or padding: %v %v", root.Direction, root.Padding)
+		}
+		if text := root.Children[0]; text.Value != "Hello" || text.FontSize != 12 || text.FontLineHeight != 6 {
+			t.Errorf("unexpected text node: %+v", text)
+		}
+		columns := []Track{FitTrack(), FractionTrack(1), PercentTrack(50), FixedTrack(20)}
+		if !reflect.DeepEqual(root.Children[1].Columns, columns) {
+			t.Errorf("unexpected columns: %v", root.Children[1].Columns)
+		}
+	})
+
+	t.Run("decodes YAML fields and attachments", func(t *testing.T) {
+		root, err := Decode(strings.NewReader(`
+type: box
+children:
+  - type: box
+    field:
+      kind: dropdown
+      name: size
+      options: [S, M, L]
+  - type: box
+    field:
+      kind: text
+      name: share
+      format: {type: percent, decimals: 1}
+    attachment:
+      name: notes.txt
+      data: bm90ZXM=
+      modificationDate: 2024-03-01T12:30:00Z
+`), YAMLFormat)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if field := root.Children[0].Field; field == nil || field.Kind != FieldDropdown || field.Value != "S" {
+			t.Errorf("expected the first option to be chosen, got %+v", field)
+		}
+		share := root.Children[1]
+		if share.Field == nil || share.Field.Format != PercentFormat(1) {
+			t.Errorf("unexpected field: %+v", share.Field)
+		}
+		date := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+		if file := share.Attachment; file == nil || string(file.Data) != "notes" || !file.ModificationDate.Equal(date) {
+			t.Errorf("unexpected attachment: %+v", file)
+		}
+	})
+
+	t.Run("encodes tables of contents without their entries", func(t *testing.T) {
+		pages, err := fillContents([]*Node{Box(Children(TableOfContents(nil, ChildGap(4)), Text("Intro", Bookmark("Intro", 0))))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		table := pages[0].Children[0]
+		if len(table.Children) != 1 {
+			t.Fatalf("expected the table to be filled, got %d entries", len(table.Children))
+		}
+
+		var buf bytes.Buffer
+		if err := Encode(&buf, table, JSONFormat); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Contains(buf.String(), "children") || !strings.Contains(buf.String(), `"tableOfContents": true`) {
+			t.Errorf("unexpected document: %s", buf.String())
+		}
+
+		root, err := Decode(&buf, JSONFormat)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if root.contents == nil || root.Direction != TopToBottom || root.Width.Type != GrowType || root.ChildGap != 4 {
+			t.Errorf("unexpected table of contents: %+v", root)
+		}
+	})
+
+	t.Run("encodes nothing for nil", func(t *testing.T) {
+		if err := Encode(&bytes.Buffer{}, nil, JSONFormat); err == nil {
+			t.Error("expected error for nil root")
+		}
+	})
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		paths    []string
+	}{
+		{"missing type", `{}`, []string{"$.type"}},
+		{"unknown type", `{"type": "table"}`, []string{"$.type"}},
+		{"not an object", `[]`, []string{"$"}},
+		{"unknown field", `{"type": "box", "colour": "#FFFFFF"}`, []string{"$.colour"}},
+		{"field of another type", `{"type": "box", "fontSize": 12}`, []string{"$.fontSize"}},
+		{"children of a text", `{"type": "text", "children": []}`, []string{"$.children"}},
+		{"image without source", `{"type": "image"}`, []string{"$.src"}},
+		{"invalid size", `{"type": "box", "width": "wide"}`, []string{"$.width"}},
+		{"negative size", `{"type": "box", "width": -1}`, []string{"$.width"}},
+		{"fixed size without value", `{"type": "box", "width": {"type": "fixed"}}`, []string{"$.width.value"}},
+		{"invalid color", `{"type": "box", "backgroundColor": "red"}`, []string{"$.backgroundColor"}},
+		{"invalid sides", `{"type": "box", "padding": [1, 2]}`, []string{"$.padding"}},
+		{"invalid track", `{"type": "grid", "columns": [100, "1px"]}`, []string{"$.columns[1]"}},
+		{"half placed cell", `{"type": "text", "cell": {"row": 1}}`, []string{"$.cell"}},
+		{"table of contents with children", `{"type": "box", "tableOfContents": true, "children": []}`, []string{"$.children"}},
+		{"invalid table of contents", `{"type": "box", "tableOfContents": "yes"}`, []string{"$.tableOfContents"}},
+		{"unknown role", `{"type": "text", "text": "Title", "role": "Heading"}`, []string{"$.role"}},
+		{"table of contents of a grid", `{"type": "grid", "tableOfContents": true}`, []string{"$.tableOfContents"}},
+		{"field of a text", `{"type": "text", "field": {"kind": "text", "name": "total"}}`, []string{"$.field"}},
+		{"field without name", `{"type": "box", "field": {"kind": "checkbox"}}`, []string{"$.field.name"}},
+		{"unknown field kind", `{"type": "box", "field": {"kind": "slider", "name": "volume"}}`, []string{"$.field.kind"}},
+		{"invalid field", `{"type": "box", "field": {"kind": "radio", "name": "delivery"}}`, []string{"$.field"}},
+		{"date format without layout", `{"type": "box", "field": {"kind": "text", "name": "due", "format": {"type": "date", "decimals": 2}}}`, []string{"$.field.format.decimals", "$.field.format.layout"}},
+		{"attachment without name", `{"type": "text", "attachment": {"data": "bm90ZXM="}}`, []string{"$.attachment.name"}},
+		{"invalid attachment", `{"type": "text", "attachment": {"name": "a.txt", "data": "%", "modificationDate": "yesterday"}}`, []string{"$.attachment.data", "$.attachment.modificationDate"}},
+		{
+			"nested errors",
+			`{"type": "box", "children": [{"type": "box"}, {"type": "box", "children": [{"type": "text", "fontSize": "big"}]}, {"type": "grid", "rowGap": true}]}`,
+			[]string{"$.children[1].children[0].fontSize", "$.children[2].rowGap"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.document), JSONFormat)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			var paths []string
+			for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+				var documentErr *DocumentError
+				if !errors.As(err, &documentErr) {
+					t.Fatalf("expected a DocumentError, got %v", err)
+				}
+				paths = append(paths, documentErr.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("expected errors at %v, got %v", tt.paths, err)
+			}
+		})
+	}
+
+	t.Run("invalid syntax", func(t *testing.T) {
+		if _, err := Decode(strings.NewReader(`{"type":`), JSONFormat); err == nil {
+			t.Error("expected an error for invalid JSON")
+		}
+		if _, err := Decode(strings.NewReader("type: [box"), YAMLFormat); err == nil {
+			t.Error("expected an error for invalid YAML")
+		}
+	})
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("loads a document ready for layout", func(t *testing.T) {
+		path := filepath.Join(dir, "page.yml")
+		document := "type: box\nwidth: 100\nheight: 50\nchildren:\n  - type: box\n    width: grow\n    height: grow\n"
+		if err := os.WriteFile(path, []byte(document), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		root, err := Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		Layout(root)
+		if child := root.Children[0]; child.Width.Value != 100 || child.Height.Value != 50 {
+			t.Errorf("expected child to grow to 100x50, got %fx%f", child.Width.Value, child.Height.Value)
+		}
+	})
+
+	t.Run("reports the file and the path of errors", func(t *testing.T) {
+		path := filepath.Join(dir, "broken.json")
+		if err := os.WriteFile(path, []byte(`{"type": "box", "width": "wide"}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := Load(path)
+		if err == nil || !strings.Contains(err.Error(), "broken.json") || !strings.Contains(err.Error(), "$.width") {
+			t.Errorf("expected error with file and path, got %v", err)
+		}
+	})
+
+	t.Run("rejects unknown extensions", func(t *testing.T) {
+		if _, err := Load(filepath.Join(dir, "page.xml")); err == nil {
+			t.Error("expected an error for unknown extension")
+		}
+	})
+}
+
+func TestSchema(t *testing.T) {
+	var schema struct {
+		Defs struct {
+			Node struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"node"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(Schema(), &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+
+	// Every key accepted by the decoder is described by the schema, and the other way around
+	allKeys := map[string]bool{}
+	for _, keys := range documentKeys {
+		for key := range keys {
+			allKeys[key] = true
+		}
+	}
+	for key := range allKeys {
+		if _, ok := schema.Defs.Node.Properties[key]; !ok {
+			t.Errorf("key %q is missing from the schema", key)
+		}
+	}
+	for key := range schema.Defs.Node.Properties {
+		if !allKeys[key] {
+			t.Errorf("schema key %q is not accepted by the decoder", key)
+		}
+	}
+}
@@ -5,5 +5,8 @@ go 1.24.4
 require (
 	codeberg.org/go-pdf/fpdf v0.11.1
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
+	github.com/yuin/goldmark v1.7.12
 	golang.org/x/image v0.29.0
+	golang.org/x/net v0.46.0
+	gopkg.in/yaml.v3 v3.0.1
 )
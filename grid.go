@@ -0,0 +1,473 @@
+package sahar
+
+import "math"
+
+// TrackType represents how a grid column or row is sized.
+// It can be FixedTrackType, FitTrackType, FractionTrackType, or PercentTrackType.
+type TrackType int
+
+const (
+	// FixedTrackType is used when the track has a specific size.
+	FixedTrackType TrackType = iota
+	// FitTrackType is used when the track should fit the largest cell in it.
+	FitTrackType
+	// FractionTrackType is used when the track should take a share of the remaining space.
+	FractionTrackType
+	// PercentTrackType is used when the track size is a percentage of the grid content size.
+	PercentTrackType
+)
+
+// Track represents a single column or row definition of a grid.
+type Track struct {
+	Type  TrackType
+	Value float64
+}
+
+// FixedTrack creates a track with the exact size in points
+func FixedTrack(value float64) Track {
+	return Track{Type: FixedTrackType, Value: value}
+}
+
+// FitTrack creates a track that fits the largest cell placed in it
+func FitTrack() Track {
+	return Track{Type: FitTrackType}
+}
+
+// FractionTrack creates a track that takes `value` shares of the space left after
+// fixed, fit and percent tracks are sized. FractionTrack(1), FractionTrack(2) gives
+// the second track twice the space of the first one.
+func FractionTrack(value float64) Track {
+	return Track{Type: FractionTrackType, Value: value}
+}
+
+// PercentTrack creates a track that takes `value` percent of the grid content size
+func PercentTrack(value float64) Track {
+	return Track{Type: PercentTrackType, Value: value}
+}
+
+// GridCell describes where a node is placed inside its Grid parent.
+// Row and Column are zero-based track indexes and they are only used
+// if Placed is true, otherwise the node is placed in the next free cell.
+type GridCell struct {
+	Row, Column         int
+	RowSpan, ColumnSpan int
+	Placed              bool
+}
+
+// gridPlacement is the resolved cell of a grid child
+type gridPlacement struct {
+	child               *Node
+	row, column         int
+	rowSpan, columnSpan int
+}
+
+// Grid creates a new grid node with the specified options.
+// A grid node places its children into cells formed by columns and rows, so cells
+// in the same row share the same height and cells in the same column share the same width.
+func Grid(opts ...nodeOpt) *Node {
+	n := &Node{
+		Type:      GridType,
+		Direction: LeftToRight,
+		Shrink:    1,
+		Width: Size{
+			Type:  FitType,
+			Value: 0,
+			Max:   maxNotSet,
+			Min:   minNotSet,
+		},
+		Height: Size{
+			Type:  FitType,
+			Value: 0,
+			Max:   maxNotSet,
+			Min:   minNotSet,
+		},
+	}
+
+	for _, opt := range opts {
+		opt.configureNode(n)
+	}
+
+	return n
+}
+
+// Columns sets the column tracks of a grid node
+func Columns(tracks ...Track) nodeOpt {
+	return nodeOptFunc(func(n *Node) {
+		n.Columns = tracks
+	})
+}
+
+// Rows sets the row tracks of a grid node. Rows that are needed by
+// the children but not defined here are sized with FitTrack
+func Rows(tracks ...Track) nodeOpt {
+	return nodeOptFunc(func(n *Node) {
+		n.Rows = tracks
+	})
+}
+
+// RowGap sets the space between rows of a grid node
+func RowGap(gap float64) nodeOpt {
+	return nodeOptFunc(func(n *Node) {
+		n.RowGap = gap
+	})
+}
+
+// ColumnGap sets the space between columns of a grid node
+func ColumnGap(gap float64) nodeOpt {
+	return nodeOptFunc(func(n *Node) {
+		n.ColumnGap = gap
+	})
+}
+
+// Cell places the node at the given zero-based row and column of its Grid parent
+func Cell(row, column int) commonOpt {
+	return commonOptFunc(func(n *Node) {
+		n.Cell.Row = row
+		n.Cell.Column = column
+		n.Cell.Placed = true
+	})
+}
+
+// Span makes the node cover the given number of rows and columns of its Grid parent
+func Span(rows, columns int) commonOpt {
+	return commonOptFunc(func(n *Node) {
+		n.Cell.RowSpan = rows
+		n.Cell.ColumnSpan = columns
+	})
+}
+
+// placeGridChildren assigns every child of a grid node to a cell. Explicitly placed
+// children are placed first and the rest fill the free cells in row order.
+func placeGridChildren(node *Node) {
+	columnCount := max(len(node.Columns), 1)
+	for _, child := range node.Children {
+		if child.Cell.Placed {
+			columnCount = max(columnCount, child.Cell.Column+spanOf(child.Cell.ColumnSpan))
+		}
+	}
+
+	occupied := make(map[[2]int]bool)
+	occupy := func(p gridPlacement) {
+		for r := p.row; r < p.row+p.rowSpan; r++ {
+			for c := p.column; c < p.column+p.columnSpan; c++ {
+				occupied[[2]int{r, c}] = true
+			}
+		}
+	}
+	isFree := func(p gridPlacement) bool {
+		for r := p.row; r < p.row+p.rowSpan; r++ {
+			for c := p.column; c < p.column+p.columnSpan; c++ {
+				if occupied[[2]int{r, c}] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	placements := make([]gridPlacement, len(node.Children))
+	for i, child := range node.Children {
+		placements[i] = gridPlacement{
+			child:      child,
+			row:        max(child.Cell.Row, 0),
+			column:     max(child.Cell.Column, 0),
+			rowSpan:    spanOf(child.Cell.RowSpan),
+			columnSpan: min(spanOf(child.Cell.ColumnSpan), columnCount),
+		}
+		if child.Cell.Placed {
+			occupy(placements[i])
+		}
+	}
+
+	// Auto placement moves a cursor forward in row order, similar to
+	// the sparse auto placement of CSS grids
+	row, column := 0, 0
+	for i, child := range node.Children {
+		if child.Cell.Placed {
+			continue
+		}
+		p := placements[i]
+		for {
+			if column+p.columnSpan > columnCount {
+				row, column = row+1, 0
+				continue
+			}
+			p.row, p.column = row, column
+			if isFree(p) {
+				break
+			}
+			column++
+		}
+		occupy(p)
+		placements[i] = p
+		column += p.columnSpan
+	}
+
+	node.gridCells = placements
+}
+
+func spanOf(span int) int {
+	if span < 1 {
+		return 1
+	}
+	return span
+}
+
+// gridTracks returns the track definitions of a grid axis, extended with fit tracks
+// so that every placed child has a track to live in
+func gridTracks(defined []Track, count int) []Track {
+	tracks := make([]Track, max(len(defined), count))
+	copy(tracks, defined)
+	for i := len(defined); i < len(tracks); i++ {
+		tracks[i] = FitTrack()
+	}
+	return tracks
+}
+
+// gridColumnTracks returns the column tracks needed by the placed children
+func gridColumnTracks(node *Node) []Track {
+	count := 0
+	for _, p := range node.gridCells {
+		count = max(count, p.column+p.columnSpan)
+	}
+	return gridTracks(node.Columns, count)
+}
+
+// gridRowTracks returns the row tracks needed by the placed children
+func gridRowTracks(node *Node) []Track {
+	count := 0
+	for _, p := range node.gridCells {
+		count = max(count, p.row+p.rowSpan)
+	}
+	return gridTracks(node.Rows, count)
+}
+
+// gridTrackContent calculates the size every track needs to hold its cells.
+// Children spanning several tracks spread what they still need over the fit and
+// fraction tracks they cover.
+func gridTrackContent(tracks []Track, gap float64, cells []gridPlacement, horizontal bool) []float64 {
+	content := make([]float64, len(tracks))
+
+	// Grow children fill their cells, so they need the size of their content
+	sizeOf := func(p gridPlacement) (start, span int, size float64) {
+		if horizontal {
+			size = p.child.Margin[1] + p.child.Margin[3]
+			if p.child.Width.Type == GrowType {
+				size += fitContentWidth(p.child)
+			} else {
+				size += getActualWidth(p.child)
+			}
+			return p.column, p.columnSpan, size
+		}
+		size = p.child.Margin[0] + p.child.Margin[2]
+		if p.child.Height.Type == GrowType {
+			size += fitContentHeight(p.child)
+		} else {
+			size += getActualHeight(p.child)
+		}
+		return p.row, p.rowSpan, size
+	}
+
+	for _, p := range cells {
+		start, span, size := sizeOf(p)
+		if span == 1 && size > content[start] {
+			content[start] = size
+		}
+	}
+
+	for _, p := range cells {
+		start, span, size := sizeOf(p)
+		if span == 1 {
+			continue
+		}
+
+		var flexible []int
+		covered := gap * float64(span-1)
+		for i := start; i < start+span; i++ {
+			covered += content[i]
+			if tracks[i].Type == FitTrackType || tracks[i].Type == FractionTrackType {
+				flexible = append(flexible, i)
+			}
+		}
+
+		if missing := size - covered; missing > 0 && len(flexible) > 0 {
+			for _, i := range flexible {
+				content[i] += missing / float64(len(flexible))
+			}
+		}
+	}
+
+	return content
+}
+
+// resolveTracks calculates the final size of each track. If available is negative
+// the grid is sized by its content, so percent tracks fall back to their content and
+// fraction tracks are made large enough for the content of every fraction track.
+func resolveTracks(tracks []Track, content []float64, available float64) []float64 {
+	sizes := make([]float64, len(tracks))
+
+	var used, totalFraction, fractionUnit float64
+	for i, track := range tracks {
+		switch track.Type {
+		case FixedTrackType:
+			sizes[i] = track.Value
+		case PercentTrackType:
+			if available >= 0 {
+				sizes[i] = available * track.Value / 100
+			} else {
+				sizes[i] = content[i]
+			}
+		case FractionTrackType:
+			if track.Value > 0 {
+				totalFraction += track.Value
+				fractionUnit = max(fractionUnit, content[i]/track.Value)
+			}
+			continue
+		default:
+			sizes[i] = content[i]
+		}
+		used += sizes[i]
+	}
+
+	if totalFraction == 0 {
+		return sizes
+	}
+
+	if available >= 0 {
+		fractionUnit = max(0, available-used) / totalFraction
+	}
+
+	for i, track := range tracks {
+		if track.Type == FractionTrackType && track.Value > 0 {
+			sizes[i] = fractionUnit * track.Value
+		}
+	}
+
+	return sizes
+}
+
+// trackSpan returns the offset of the first track and the size covered by span tracks
+func trackSpan(sizes []float64, gap float64, start, span int) (offset, size float64) {
+	for i := 0; i < start; i++ {
+		offset += sizes[i] + gap
+	}
+	for i := start; i < start+span && i < len(sizes); i++ {
+		size += sizes[i]
+	}
+	size += gap * float64(span-1)
+	return offset, size
+}
+
+// sumTracks returns the total size of the tracks including gaps
+func sumTracks(sizes []float64, gap float64) float64 {
+	if len(sizes) == 0 {
+		return 0
+	}
+	_, size := trackSpan(sizes, gap, 0, len(sizes))
+	return size
+}
+
+// gridFitWidth calculates the content width of a grid node sized to fit its columns
+func gridFitWidth(node *Node) float64 {
+	tracks := gridColumnTracks(node)
+	content := gridTrackContent(tracks, node.ColumnGap, node.gridCells, true)
+	node.gridColumns = resolveTracks(tracks, content, -1)
+	return sumTracks(node.gridColumns, node.ColumnGap)
+}
+
+// gridFitHeight calculates the content height of a grid node sized to fit its rows
+func gridFitHeight(node *Node) float64 {
+	tracks := gridRowTracks(node)
+	content := gridTrackContent(tracks, node.RowGap, node.gridCells, false)
+	node.gridRows = resolveTracks(tracks, content, -1)
+	return sumTracks(node.gridRows, node.RowGap)
+}
+
+// distributeGridWidths sizes the columns to the grid width and lets
+// grow children fill their cells horizontally
+func distributeGridWidths(node *Node) {
+	tracks := gridColumnTracks(node)
+	content := gridTrackContent(tracks, node.ColumnGap, node.gridCells, true)
+	available := getAvailableWidth(node) - node.ColumnGap*float64(max(len(tracks)-1, 0))
+	node.gridColumns = resolveTracks(tracks, content, max(0, available))
+
+	for _, p := range node.gridCells {
+		_, cellWidth := trackSpan(node.gridColumns, node.ColumnGap, p.column, p.columnSpan)
+		setGrowChildrenWidth([]*Node{p.child}, cellWidth)
+	}
+}
+
+// distributeGridHeights sizes the rows to the grid height and lets
+// grow children fill their cells vertically
+func distributeGridHeights(node *Node) {
+	tracks := gridRowTracks(node)
+	content := gridTrackContent(tracks, node.RowGap, node.gridCells, false)
+	available := getAvailableHeight(node) - node.RowGap*float64(max(len(tracks)-1, 0))
+	node.gridRows = resolveTracks(tracks, content, max(0, available))
+
+	for _, p := range node.gridCells {
+		_, cellHeight := trackSpan(node.gridRows, node.RowGap, p.row, p.rowSpan)
+		setGrowChildrenHeight([]*Node{p.child}, cellHeight)
+	}
+}
+
+// shrinkGridWidths makes sure no child is wider than the cells it covers
+func shrinkGridWidths(node *Node) {
+	node.overflow[0] = math.Max(0, sumTracks(node.gridColumns, node.ColumnGap)-getAvailableWidth(node))
+	for _, p := range node.gridCells {
+		_, cellWidth := trackSpan(node.gridColumns, node.ColumnGap, p.column, p.columnSpan)
+		if excess := getOuterWidth(p.child) - cellWidth; excess > 0 {
+			node.overflow[0] = math.Max(node.overflow[0], shrinkChildren([]*Node{p.child}, excess, true))
+		}
+	}
+}
+
+// shrinkGridHeights makes sure no child is taller than the cells it covers
+func shrinkGridHeights(node *Node) {
+	node.overflow[1] = math.Max(0, sumTracks(node.gridRows, node.RowGap)-getAvailableHeight(node))
+	for _, p := range node.gridCells {
+		_, cellHeight := trackSpan(node.gridRows, node.RowGap, p.row, p.rowSpan)
+		if excess := getOuterHeight(p.child) - cellHeight; excess > 0 {
+			node.overflow[1] = math.Max(node.overflow[1], shrinkChildren([]*Node{p.child}, excess, false))
+		}
+	}
+}
+
+// gridMinContentSize calculates the smallest size of the grid tracks on the given axis
+func gridMinContentSize(node *Node, horizontal bool) float64 {
+	tracks, gap := gridRowTracks(node), node.RowGap
+	if horizontal {
+		tracks, gap = gridColumnTracks(node), node.ColumnGap
+	}
+
+	mins := make([]float64, len(tracks))
+	for _, p := range node.gridCells {
+		start, span := p.row, p.rowSpan
+		if horizontal {
+			start, span = p.column, p.columnSpan
+		}
+		if span == 1 {
+			mins[start] = math.Max(mins[start], minContentSize(p.child, horizontal)+axisMargin(p.child, horizontal))
+		}
+	}
+
+	for i, track := range tracks {
+		if track.Type == FixedTrackType {
+			mins[i] = track.Value
+		}
+	}
+
+	return sumTracks(mins, gap)
+}
+
+// positionGridChildren positions every child inside its cells and aligns
+// it using the alignment of the grid node
+func positionGridChildren(node *Node, content contentArea) {
+	for _, p := range node.gridCells {
+		cellX, cellWidth := trackSpan(node.gridColumns, node.ColumnGap, p.column, p.columnSpan)
+		cellY, cellHeight := trackSpan(node.gridRows, node.RowGap, p.row, p.rowSpan)
+
+		p.child.Position.X = getAlignedX(node.Horizontal, content.x+cellX, cellWidth, getOuterWidth(p.child)) + p.child.Margin[3]
+		p.child.Position.Y = getAlignedY(node.Vertical, content.y+cellY, cellHeight, getOuterHeight(p.child)) + p.child.Margin[0]
+	}
+}
@@ -0,0 +1,329 @@
+package sahar
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGrid(t *testing.T) {
+	t.Run("creates grid node with default values", func(t *testing.T) {
+		node := Grid()
+
+		if node.Type != GridType {
+			t.Errorf("expected Type to be GridType, got %v", node.Type)
+		}
+		if node.Width.Type != FitType || node.Height.Type != FitType {
+			t.Error("expected grid to fit its content by default")
+		}
+	})
+
+	t.Run("applies grid options", func(t *testing.T) {
+		node := Grid(
+			Columns(FixedTrack(100), FractionTrack(1)),
+			Rows(FitTrack(), PercentTrack(50)),
+			RowGap(5),
+			ColumnGap(10),
+		)
+
+		if len(node.Columns) != 2 || node.Columns[0] != FixedTrack(100) || node.Columns[1] != FractionTrack(1) {
+			t.Errorf("unexpected columns: %v", node.Columns)
+		}
+		if len(node.Rows) != 2 || node.Rows[0] != FitTrack() || node.Rows[1] != PercentTrack(50) {
+			t.Errorf("unexpected rows: %v", node.Rows)
+		}
+		if node.RowGap != 5 || node.ColumnGap != 10 {
+			t.Errorf("expected gaps 5 and 10, got %f and %f", node.RowGap, node.ColumnGap)
+		}
+	})
+
+	t.Run("cell and span apply to boxes and texts", func(t *testing.T) {
+		box := Box(Cell(1, 2), Span(2, 3))
+		text := Text("Hi", Cell(3, 4))
+
+		if box.Cell != (GridCell{Row: 1, Column: 2, RowSpan: 2, ColumnSpan: 3, Placed: true}) {
+			t.Errorf("unexpected box cell: %+v", box.Cell)
+		}
+		if text.Cell != (GridCell{Row: 3, Column: 4, Placed: true}) {
+			t.Errorf("unexpected text cell: %+v", text.Cell)
+		}
+	})
+}
+
+func TestGridLayout(t *testing.T) {
+	t.Run("cells in the same row and column line up", func(t *testing.T) {
+		a := Box(Sizing(Fixed(30), Fixed(10)))
+		b := Box(Sizing(Fixed(10), Fixed(40)))
+		c := Box(Sizing(Fixed(50), Fixed(20)))
+		d := Box(Sizing(Fixed(20), Fixed(5)))
+
+		grid := Grid(
+			Columns(FitTrack(), FitTrack()),
+			ColumnGap(10),
+			RowGap(5),
+			Children(a, b, c, d),
+		)
+
+		Layout(grid)
+
+		// Columns: max(30, 50) = 50, max(10, 20) = 20
+		// Rows: max(10, 40) = 40, max(20, 5) = 20
+		if grid.Width.Value != 80 {
+			t.Errorf("expected grid width to be 80, got %f", grid.Width.Value)
+		}
+		if grid.Height.Value != 65 {
+			t.Errorf("expected grid height to be 65, got %f", grid.Height.Value)
+		}
+
+		expected := map[*Node]Position{
+			a: {0, 0},
+			b: {60, 0},
+			c: {0, 45},
+			d: {60, 45},
+		}
+		for node, pos := range expected {
+			if node.Position != pos {
+				t.Errorf("expected position %v, got %v", pos, node.Position)
+			}
+		}
+	})
+
+	t.Run("fraction and fixed tracks share the grid width", func(t *testing.T) {
+		a := Box(Sizing(Grow(), Grow()))
+		b := Box(Sizing(Grow(), Grow()))
+		c := Box(Sizing(Grow(), Grow()))
+
+		grid := Grid(
+			Sizing(Fixed(320), Fixed(100)),
+			Padding(10, 10, 10, 10),
+			Columns(FixedTrack(60), FractionTrack(1), FractionTrack(2)),
+			Rows(FractionTrack(1)),
+			ColumnGap(10),
+			Children(a, b, c),
+		)
+
+		Layout(grid)
+
+		// Available = 300 - 20 (gaps) - 60 (fixed) = 220, 1fr = 73.33
+		if a.Width.Value != 60 {
+			t.Errorf("expected fixed column to be 60, got %f", a.Width.Value)
+		}
+		if math.Abs(b.Width.Value-220.0/3) > 0.01 {
+			t.Errorf("expected 1fr column to be %f, got %f", 220.0/3, b.Width.Value)
+		}
+		if math.Abs(c.Width.Value-440.0/3) > 0.01 {
+			t.Errorf("expected 2fr column to be %f, got %f", 440.0/3, c.Width.Value)
+		}
+		if math.Abs(c.Position.X-(10+60+10+220.0/3+10)) > 0.01 {
+			t.Errorf("unexpected X position of last column: %f", c.Position.X)
+		}
+		if a.Height.Value != 80 {
+			t.Errorf("expected grow child to fill the row height of 80, got %f", a.Height.Value)
+		}
+	})
+
+	t.Run("percent tracks use the grid content size", func(t *testing.T) {
+		a := Box(Sizing(Grow(), Fixed(10)))
+		b := Box(Sizing(Grow(), Fixed(10)))
+
+		grid := Grid(
+			Sizing(Fixed(200)),
+			Columns(PercentTrack(25), PercentTrack(75)),
+			Children(a, b),
+		)
+
+		Layout(grid)
+
+		if a.Width.Value != 50 || b.Width.Value != 150 {
+			t.Errorf("expected widths 50 and 150, got %f and %f", a.Width.Value, b.Width.Value)
+		}
+	})
+
+	t.Run("fit grid gives fraction tracks room for their content", func(t *testing.T) {
+		a := Box(Sizing(Fixed(40), Fixed(10)))
+		b := Box(Sizing(Fixed(10), Fixed(10)))
+
+		grid := Grid(
+			Columns(FractionTrack(1), FractionTrack(1)),
+			Children(a, b),
+		)
+
+		Layout(grid)
+
+		if grid.Width.Value != 80 {
+			t.Errorf("expected grid width to be 80, got %f", grid.Width.Value)
+		}
+		if b.Position.X != 40 {
+			t.Errorf("expected second column to start at 40, got %f", b.Position.X)
+		}
+	})
+
+	t.Run("spanning cell covers tracks and gaps", func(t *testing.T) {
+		header := Box(Sizing(Grow(), Fixed(20)), Span(1, 2))
+		left := Box(Sizing(Fixed(50), Fixed(30)))
+		right := Box(Sizing(Fixed(70), Fixed(30)))
+
+		grid := Grid(
+			Columns(FitTrack(), FitTrack()),
+			ColumnGap(10),
+			Children(header, left, right),
+		)
+
+		Layout(grid)
+
+		if header.Width.Value != 130 {
+			t.Errorf("expected spanning cell to be 130 wide, got %f", header.Width.Value)
+		}
+		if left.Position.Y != 20 || right.Position.Y != 20 {
+			t.Errorf("expected second row to start at 20, got %f and %f", left.Position.Y, right.Position.Y)
+		}
+		if right.Position.X != 60 {
+			t.Errorf("expected right cell X to be 60, got %f", right.Position.X)
+		}
+	})
+
+	t.Run("spanning content grows the fit tracks it covers", func(t *testing.T) {
+		wide := Box(Sizing(Fixed(100), Fixed(10)), Span(1, 2))
+		a := Box(Sizing(Fixed(10), Fixed(10)))
+		b := Box(Sizing(Fixed(10), Fixed(10)))
+
+		grid := Grid(
+			Columns(FitTrack(), FitTrack()),
+			Children(wide, a, b),
+		)
+
+		Layout(grid)
+
+		if grid.Width.Value != 100 {
+			t.Errorf("expected grid width to be 100, got %f", grid.Width.Value)
+		}
+		if b.Position.X != 50 {
+			t.Errorf("expected second column to start at 50, got %f", b.Position.X)
+		}
+	})
+
+	t.Run("grow cells size fit tracks with their content and fill them", func(t *testing.T) {
+		short := Box(Sizing(Grow(), Grow()), Padding(5, 5, 5, 5), Children(Box(Sizing(Fixed(30), Fixed(10)))))
+		tall := Box(Sizing(Grow(), Grow()), Children(Box(Sizing(Fixed(10), Fixed(40)))))
+
+		grid := Grid(
+			Columns(FitTrack(), FitTrack()),
+			Children(short, tall),
+		)
+
+		Layout(grid)
+
+		if short.Width.Value != 40 || tall.Width.Value != 10 {
+			t.Errorf("expected columns of 40 and 10, got %f and %f", short.Width.Value, tall.Width.Value)
+		}
+		if short.Height.Value != 40 || tall.Height.Value != 40 {
+			t.Errorf("expected both cells to fill the row of 40, got %f and %f", short.Height.Value, tall.Height.Value)
+		}
+	})
+
+	t.Run("explicit cells and auto placement", func(t *testing.T) {
+		placed := Box(Sizing(Fixed(10), Fixed(10)), Cell(0, 1))
+		first := Box(Sizing(Fixed(10), Fixed(10)))
+		second := Box(Sizing(Fixed(10), Fixed(10)))
+
+		grid := Grid(
+			Columns(FixedTrack(20), FixedTrack(20)),
+			Children(placed, first, second),
+		)
+
+		Layout(grid)
+
+		if placed.Position != (Position{20, 0}) {
+			t.Errorf("expected placed cell at (20,0), got %v", placed.Position)
+		}
+		if first.Position != (Position{0, 0}) {
+			t.Errorf("expected first auto cell at (0,0), got %v", first.Position)
+		}
+		if second.Position != (Position{0, 10}) {
+			t.Errorf("expected second auto cell on the implicit row at (0,10), got %v", second.Position)
+		}
+	})
+
+	t.Run("cell alignment uses grid alignment", func(t *testing.T) {
+		small := Box(Sizing(Fixed(10), Fixed(10)))
+
+		grid := Grid(
+			Columns(FixedTrack(50)),
+			Rows(FixedTrack(30)),
+			Alignment(Center, Bottom),
+			Children(small),
+		)
+
+		Layout(grid)
+
+		if small.Position != (Position{20, 20}) {
+			t.Errorf("expected small cell at (20,20), got %v", small.Position)
+		}
+	})
+
+	t.Run("text is wrapped to its column width", func(t *testing.T) {
+		text := Text("This is a long text that needs more than one line", FontSize(12))
+
+		grid := Grid(
+			Columns(FixedTrack(80)),
+			Children(text),
+		)
+
+		Layout(grid)
+
+		if text.Width.Value != 80 {
+			t.Errorf("expected text to be shrunk to the column width, got %f", text.Width.Value)
+		}
+	})
+
+	t.Run("grid inside a box grows with its parent", func(t *testing.T) {
+		a := Box(Sizing(Grow(), Fixed(10)))
+		b := Box(Sizing(Grow(), Fixed(10)))
+		grid := Grid(
+			Sizing(Grow()),
+			Columns(FractionTrack(1), FractionTrack(1)),
+			Children(a, b),
+		)
+		page := Box(
+			Sizing(Fixed(300), Fixed(100)),
+			Direction(TopToBottom),
+			Children(grid),
+		)
+
+		Layout(page)
+
+		if grid.Width.Value != 300 {
+			t.Errorf("expected grid width to be 300, got %f", grid.Width.Value)
+		}
+		if a.Width.Value != 150 || b.Position.X != 150 {
+			t.Errorf("expected two 150 wide columns, got %f and X %f", a.Width.Value, b.Position.X)
+		}
+	})
+}
+
+func TestResolveTracks(t *testing.T) {
+	tests := []struct {
+		name      string
+		tracks    []Track
+		content   []float64
+		available float64
+		want      []float64
+	}{
+		{"fixed ignores content", []Track{FixedTrack(10)}, []float64{50}, 100, []float64{10}},
+		{"fit uses content", []Track{FitTrack()}, []float64{50}, 100, []float64{50}},
+		{"percent of available", []Track{PercentTrack(10)}, []float64{50}, 100, []float64{10}},
+		{"percent without available uses content", []Track{PercentTrack(10)}, []float64{50}, -1, []float64{50}},
+		{"fraction takes the remaining space", []Track{FitTrack(), FractionTrack(1)}, []float64{40, 0}, 100, []float64{40, 60}},
+		{"fraction never negative", []Track{FixedTrack(200), FractionTrack(1)}, []float64{0, 0}, 100, []float64{200, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveTracks(tt.tracks, tt.content, tt.available)
+			for i := range tt.want {
+				if math.Abs(got[i]-tt.want[i]) > 0.001 {
+					t.Errorf("track %d: expected %f, got %f", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
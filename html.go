@@ -0,0 +1,984 @@
+package sahar
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	htmlDefaultFontSize = 12   // 16px, the default font size of browsers
+	htmlPixel           = 0.75 // 1px in points
+)
+
+// HTMLWarning describes a part of an HTML document which is not supported by
+// ImportHTML and was ignored or approximated
+type HTMLWarning struct {
+	Path    string // The elements from the body to the construct, for example body/div[1]/table[0]
+	Message string
+}
+
+func (w HTMLWarning) String() string {
+	return w.Path + ": " + w.Message
+}
+
+type htmlOpt interface {
+	configureHTML(*htmlConverter)
+}
+
+type htmlOptFunc func(*htmlConverter)
+
+func (f htmlOptFunc) configureHTML(c *htmlConverter) {
+	f(c)
+}
+
+// HTMLFonts sets the fonts used by ImportHTML for regular, bold (strong, b, th and headings),
+// italic (em and i) and bold italic text. The fonts should be loaded with LoadFonts,
+// an empty name falls back to the regular font. A font-family set in CSS is used as is
+func HTMLFonts(regular, bold, italic, boldItalic string) htmlOpt {
+	return htmlOptFunc(func(c *htmlConverter) {
+		c.fonts = [4]string{regular, bold, italic, boldItalic}
+	})
+}
+
+// ImportHTML converts a subset of HTML with inline CSS to a node tree which can be
+// passed to Layout and RenderToPDF.
+//
+// Supported elements are div, p, h1-h6, span, strong, b, em, i, br, img and table,
+// and supported CSS properties are display, flex-direction, justify-content, align-items,
+// gap, flex, padding, margin, width, height, color, background-color, border, font-size,
+// font-family, font-weight, font-style and text-align. Everything else is reported as a
+// warning, the error is only returned when the document can not be read.
+//
+// The body grows to fill its parent, so the result is usually placed inside a page,
+// for example Box(Sizing(A4()...), Children(body))
+func ImportHTML(reader io.Reader, opts ...htmlOpt) (*Node, []HTMLWarning, error) {
+	document, err := html.Parse(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	c := &htmlConverter{}
+	for _, opt := range opts {
+		opt.configureHTML(c)
+	}
+
+	var body *html.Node
+	for n := range document.Descendants() {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		if n.Data == "body" {
+			body = n
+			break
+		}
+		if n.Data == "style" || (n.Data == "link" && strings.EqualFold(htmlAttr(n, "rel"), "stylesheet")) {
+			c.warn("head", "style sheets are not supported, only inline styles are used")
+		}
+	}
+
+	root := c.block(body, "body", htmlStyle{fontSize: htmlDefaultFontSize}, TopToBottom)
+	if root == nil {
+		root = Box()
+	}
+	root.Height = Size{Type: GrowType, Min: minNotSet, Max: maxNotSet}
+
+	return root, c.warnings, nil
+}
+
+// htmlConverter walks an HTML document and collects the warnings
+type htmlConverter struct {
+	fonts      [4]string // regular, bold, italic, bold italic
+	warnings   []HTMLWarning
+	fontWarned bool
+}
+
+func (c *htmlConverter) warn(path, format string, args ...any) {
+	c.warnings = append(c.warnings, HTMLWarning{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// htmlStyle holds the inherited text properties of an element
+type htmlStyle struct {
+	fontSize   float64
+	fontColor  string
+	fontFamily string
+	bold       bool
+	italic     bool
+	align      Horizontal
+}
+
+// htmlRun is a piece of text with a single style, or a line break
+type htmlRun struct {
+	text      string
+	style     htmlStyle
+	lineBreak bool
+}
+
+// cssDeclaration is a single property of an inline style, the value is lower case
+// except in raw which keeps names such as font families intact
+type cssDeclaration struct {
+	property, value, raw string
+}
+
+type htmlKind int
+
+const (
+	htmlBlock htmlKind = iota
+	htmlInline
+	htmlSkip
+)
+
+var (
+	htmlBlockTags = map[string]bool{
+		"body": true, "div": true, "p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"section": true, "article": true, "header": true, "footer": true, "main": true, "aside": true, "nav": true,
+		"td": true, "th": true, "span": true, "strong": true, "b": true, "em": true, "i": true,
+	}
+	htmlInlineTags = map[string]bool{
+		"span": true, "strong": true, "b": true, "em": true, "i": true, "br": true,
+	}
+	// unsupported inline elements, only their text is kept
+	htmlTextTags = map[string]bool{
+		"a": true, "u": true, "s": true, "small": true, "code": true, "font": true, "sub": true, "sup": true,
+		"label": true, "abbr": true, "mark": true,
+	}
+	htmlSkipTags = map[string]bool{
+		"head": true, "title": true, "meta": true, "link": true, "script": true, "style": true, "noscript": true, "template": true,
+	}
+	// the roles of the elements in a tagged PDF
+	htmlRoles = map[string]Role{
+		"p": RoleParagraph, "h1": RoleHeading1, "h2": RoleHeading2, "h3": RoleHeading3, "h4": RoleHeading4,
+		"h5": RoleHeading5, "h6": RoleHeading6, "section": RoleSection, "td": RoleTableCell, "th": RoleTableHeader,
+	}
+	htmlHeadingSizes = map[string]float64{
+		"h1": 2, "h2": 1.5, "h3": 1.17, "h4": 1, "h5": 0.83, "h6": 0.67,
+	}
+	htmlTextProperties = map[string]bool{
+		"color": true, "font-size": true, "font-family": true, "font-weight": true, "font-style": true, "text-align": true,
+	}
+	htmlNamedColors = map[string]string{
+		"black": "#000000", "white": "#FFFFFF", "red": "#FF0000", "green": "#008000", "blue": "#0000FF",
+		"yellow": "#FFFF00", "orange": "#FFA500", "purple": "#800080", "gray": "#808080", "grey": "#808080",
+		"silver": "#C0C0C0", "maroon": "#800000", "navy": "#000080", "teal": "#008080",
+	}
+)
+
+// htmlElementKind returns how an element is converted
+func htmlElementKind(n *html.Node) htmlKind {
+	if htmlSkipTags[n.Data] {
+		return htmlSkip
+	}
+
+	switch htmlDisplay(n) {
+	case "none":
+		return htmlSkip
+	case "block", "flex", "inline-flex", "inline-block":
+		return htmlBlock
+	}
+
+	if !htmlInlineTags[n.Data] && !htmlTextTags[n.Data] {
+		return htmlBlock
+	}
+
+	// inline elements holding blocks, such as a span around an image, are converted as blocks
+	for d := range n.Descendants() {
+		if d.Type == html.ElementNode && htmlElementKind(d) == htmlBlock {
+			return htmlBlock
+		}
+	}
+	return htmlInline
+}
+
+// block converts an element and its content to a box
+func (c *htmlConverter) block(n *html.Node, path string, parent htmlStyle, parentDirection direction) *Node {
+	if !htmlBlockTags[n.Data] {
+		c.warn(path, "unsupported element <%s> is converted as a div", n.Data)
+	}
+
+	declarations := parseCSS(htmlAttr(n, "style"))
+	style := c.textStyle(n, declarations, path, parent)
+
+	node := Box(Direction(TopToBottom), Tag(htmlRoles[n.Data]))
+	node.Horizontal = style.align
+
+	// Blocks fill the width of their parent like in a browser, except in a flex row
+	if parentDirection == TopToBottom {
+		node.Width = Size{Type: GrowType, Min: minNotSet, Max: maxNotSet}
+	}
+
+	if !c.applyBox(node, n, declarations, path, style, parentDirection) {
+		return nil
+	}
+
+	c.children(node, n, path, style)
+	return node
+}
+
+// children converts the content of an element, gathering consecutive inline
+// content into text nodes
+func (c *htmlConverter) children(node *Node, n *html.Node, path string, style htmlStyle) {
+	var runs []htmlRun
+	flush := func() {
+		texts := c.texts(runs, path)
+		runs = nil
+
+		if len(texts) > 1 && node.Direction == LeftToRight {
+			// keep the lines of a paragraph together inside a row
+			Box(Direction(TopToBottom), Children(texts...)).configureNode(node)
+			return
+		}
+		for _, text := range texts {
+			text.configureNode(node)
+		}
+	}
+
+	indexes := make(map[string]int)
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.TextNode:
+			runs = append(runs, htmlRun{text: child.Data, style: style})
+		case html.ElementNode:
+			childPath := fmt.Sprintf("%s/%s[%d]", path, child.Data, indexes[child.Data])
+			indexes[child.Data]++
+
+			switch htmlElementKind(child) {
+			case htmlSkip:
+				if child.Data == "style" || child.Data == "script" {
+					c.warn(childPath, "<%s> elements are not supported", child.Data)
+				}
+			case htmlInline:
+				c.inline(child, childPath, style, &runs)
+			default:
+				flush()
+
+				var converted *Node
+				switch child.Data {
+				case "img":
+					converted = c.image(child, childPath, style, node.Direction)
+				case "table":
+					converted = c.table(child, childPath, style, node.Direction)
+				default:
+					converted = c.block(child, childPath, style, node.Direction)
+				}
+				if converted != nil {
+					converted.configureNode(node)
+				}
+			}
+		}
+	}
+	flush()
+}
+
+// inline collects the text of an inline element
+func (c *htmlConverter) inline(n *html.Node, path string, style htmlStyle, runs *[]htmlRun) {
+	if n.Data == "br" {
+		*runs = append(*runs, htmlRun{lineBreak: true})
+		return
+	}
+	if htmlTextTags[n.Data] {
+		c.warn(path, "unsupported element <%s>, only its text is kept", n.Data)
+	}
+
+	declarations := parseCSS(htmlAttr(n, "style"))
+	style = c.textStyle(n, declarations, path, style)
+	for _, d := range declarations {
+		if !htmlTextProperties[d.property] && d.property != "display" {
+			c.warn(path, "CSS property %q is not supported on inline elements", d.property)
+		}
+	}
+
+	indexes := make(map[string]int)
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.TextNode:
+			*runs = append(*runs, htmlRun{text: child.Data, style: style})
+		case html.ElementNode:
+			childPath := fmt.Sprintf("%s/%s[%d]", path, child.Data, indexes[child.Data])
+			indexes[child.Data]++
+			if htmlElementKind(child) == htmlInline {
+				c.inline(child, childPath, style, runs)
+			}
+		}
+	}
+}
+
+// texts converts the collected inline content to one text node per line.
+// Text nodes have a single style, so the lines mixing styles are flattened
+// to the style of their first part
+func (c *htmlConverter) texts(runs []htmlRun, path string) []*Node {
+	var nodes []*Node
+	var line []htmlRun
+
+	addLine := func() {
+		var value strings.Builder
+		var first *htmlStyle
+		mixed := false
+
+		for i, run := range line {
+			value.WriteString(run.text)
+			if strings.TrimSpace(run.text) == "" {
+				continue
+			}
+			if first == nil {
+				first = &line[i].style
+			} else if c.font(run.style, path) != c.font(*first, path) || run.style.fontSize != first.fontSize || run.style.fontColor != first.fontColor {
+				mixed = true
+			}
+		}
+		line = nil
+
+		text := strings.Join(strings.Fields(value.String()), " ")
+		if first == nil || text == "" {
+			return
+		}
+		if mixed {
+			c.warn(path, "text mixing styles is flattened to the style of its first part: %q", text)
+		}
+
+		node := Text(text, FontSize(first.fontSize), FontType(c.font(*first, path)), FontColor(first.fontColor))
+		node.Horizontal = first.align
+		nodes = append(nodes, node)
+	}
+
+	for _, run := range runs {
+		if run.lineBreak {
+			addLine()
+			continue
+		}
+		line = append(line, run)
+	}
+	addLine()
+
+	return nodes
+}
+
+// font returns the font type of the style
+func (c *htmlConverter) font(style htmlStyle, path string) string {
+	if style.fontFamily != "" {
+		return style.fontFamily
+	}
+
+	index := 0
+	if style.bold {
+		index |= 1
+	}
+	if style.italic {
+		index |= 2
+	}
+	if index == 0 || c.fonts[index] != "" {
+		return c.fonts[index]
+	}
+
+	if !c.fontWarned {
+		c.fontWarned = true
+		c.warn(path, "bold and italic text use the regular font, see HTMLFonts")
+	}
+	return c.fonts[0]
+}
+
+// image converts an img element, images need both a width and a height
+func (c *htmlConverter) image(n *html.Node, path string, style htmlStyle, parentDirection direction) *Node {
+	src := htmlAttr(n, "src")
+	if src == "" {
+		c.warn(path, "image without src is skipped")
+		return nil
+	}
+
+	// Images with an empty alt are decoration
+	node := Image(src)
+	for _, attr := range n.Attr {
+		if attr.Key == "alt" {
+			node.Alt = strings.TrimSpace(attr.Val)
+			if node.Alt == "" {
+				node.Role = RoleArtifact
+			}
+		}
+	}
+	if !c.applyBox(node, n, parseCSS(htmlAttr(n, "style")), path, style, parentDirection) {
+		return nil
+	}
+
+	if node.Width.Type != FixedType || node.Height.Type != FixedType {
+		c.warn(path, "image without a width and a height in pixels or points is skipped")
+		return nil
+	}
+	return node
+}
+
+// htmlMaxRowSpan and htmlMaxColumnSpan are the largest spans of table cells, browsers
+// clamp larger ones
+const (
+	htmlMaxRowSpan    = 65534
+	htmlMaxColumnSpan = 1000
+)
+
+// table converts a table element to a grid, honoring colspan and rowspan
+func (c *htmlConverter) table(n *html.Node, path string, parent htmlStyle, parentDirection direction) *Node {
+	declarations := parseCSS(htmlAttr(n, "style"))
+	style := c.textStyle(n, declarations, path, parent)
+
+	node := Grid(Tag(RoleTable))
+	if !c.applyBox(node, n, declarations, path, style, parentDirection) {
+		return nil
+	}
+
+	if spacing, ok := parseHTMLLength(htmlAttr(n, "cellspacing"), style.fontSize); ok {
+		node.ColumnGap, node.RowGap = spacing, spacing
+	}
+	cellPadding, hasCellPadding := parseHTMLLength(htmlAttr(n, "cellpadding"), style.fontSize)
+	cellBorder := htmlAttr(n, "border") != "" && htmlAttr(n, "border") != "0"
+
+	occupied := make(map[[2]int]bool)
+	var widths []float64
+	var cells []*Node
+
+	rows := c.tableRows(n, path)
+	for row, tr := range rows {
+		column := 0
+		indexes := make(map[string]int)
+
+		for child := tr.node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			cellPath := fmt.Sprintf("%s/%s[%d]", tr.path, child.Data, indexes[child.Data])
+			indexes[child.Data]++
+
+			if child.Data != "td" && child.Data != "th" {
+				c.warn(cellPath, "unsupported element <%s> in a table row is skipped", child.Data)
+				continue
+			}
+
+			for occupied[[2]int{row, column}] {
+				column++
+			}
+			rowSpan := htmlAttrInt(child, "rowspan")
+			columnSpan := htmlAttrInt(child, "colspan")
+			if rowSpan > htmlMaxRowSpan {
+				c.warn(cellPath, "rowspan %d is clamped to %d", rowSpan, htmlMaxRowSpan)
+				rowSpan = htmlMaxRowSpan
+			}
+			if columnSpan > htmlMaxColumnSpan {
+				c.warn(cellPath, "colspan %d is clamped to %d", columnSpan, htmlMaxColumnSpan)
+				columnSpan = htmlMaxColumnSpan
+			}
+			// As in browsers, a cell spans at most the rows left in the table
+			rowSpan = min(rowSpan, len(rows)-row)
+
+			cell := c.block(child, cellPath, style, LeftToRight)
+			if cell == nil {
+				continue
+			}
+			if hasCellPadding && cell.Padding == [4]float64{} {
+				cell.Padding = [4]float64{cellPadding, cellPadding, cellPadding, cellPadding}
+			}
+			if cellBorder && cell.Border == 0 {
+				cell.Border = htmlPixel
+			}
+
+			for len(widths) < column+columnSpan {
+				widths = append(widths, 0)
+			}
+			if cell.Width.Type == FixedType && columnSpan == 1 {
+				widths[column] = max(widths[column], cell.Width.Value)
+			}
+
+			for r := row; r < row+rowSpan; r++ {
+				for col := column; col < column+columnSpan; col++ {
+					occupied[[2]int{r, col}] = true
+				}
+			}
+
+			cell.Cell = GridCell{Row: row, Column: column, RowSpan: rowSpan, ColumnSpan: columnSpan, Placed: true}
+			cell.configureNode(node)
+			cells = append(cells, cell)
+			column += columnSpan
+		}
+	}
+
+	if len(cells) == 0 {
+		c.warn(path, "table without cells is skipped")
+		return nil
+	}
+
+	// Columns with a width in HTML are fixed, the others fit their content unless the
+	// table has a width which they share
+	for _, width := range widths {
+		switch {
+		case width > 0:
+			node.Columns = append(node.Columns, FixedTrack(width))
+		case node.Width.Type != FitType:
+			node.Columns = append(node.Columns, FractionTrack(1))
+		default:
+			node.Columns = append(node.Columns, FitTrack())
+		}
+	}
+
+	// Cells fill their tracks, so backgrounds and borders line up like in a browser
+	for _, cell := range cells {
+		if cell.Width.Type == FitType {
+			cell.Width = Size{Type: GrowType, Min: cell.Width.Min, Max: cell.Width.Max}
+		}
+		if cell.Height.Type == FitType {
+			cell.Height = Size{Type: GrowType, Min: cell.Height.Min, Max: cell.Height.Max}
+		}
+	}
+
+	return node
+}
+
+type htmlRow struct {
+	node *html.Node
+	path string
+}
+
+// tableRows returns the rows of a table in order, including the rows of thead, tbody and tfoot
+func (c *htmlConverter) tableRows(n *html.Node, path string) []htmlRow {
+	var rows []htmlRow
+
+	indexes := make(map[string]int)
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		childPath := fmt.Sprintf("%s/%s[%d]", path, child.Data, indexes[child.Data])
+		indexes[child.Data]++
+
+		switch child.Data {
+		case "tr":
+			rows = append(rows, htmlRow{child, childPath})
+		case "thead", "tbody", "tfoot":
+			rows = append(rows, c.tableRows(child, childPath)...)
+		default:
+			c.warn(childPath, "unsupported element <%s> in a table is skipped", child.Data)
+		}
+	}
+
+	return rows
+}
+
+// textStyle applies the defaults of the element and its text CSS properties to the inherited style
+func (c *htmlConverter) textStyle(n *html.Node, declarations []cssDeclaration, path string, style htmlStyle) htmlStyle {
+	if scale, ok := htmlHeadingSizes[n.Data]; ok {
+		style.fontSize *= scale
+		style.bold = true
+	}
+
+	switch n.Data {
+	case "strong", "b":
+		style.bold = true
+	case "em", "i":
+		style.italic = true
+	case "th":
+		style.bold = true
+		style.align = Center
+	}
+
+	for _, d := range declarations {
+		switch d.property {
+		case "color":
+			if color, ok := parseHTMLColor(d.value); ok {
+				style.fontColor = color
+			} else {
+				c.warn(path, "unsupported color %q", d.value)
+			}
+		case "font-size":
+			if percent, ok := strings.CutSuffix(d.value, "%"); ok {
+				if n, err := strconv.ParseFloat(percent, 64); err == nil && n > 0 {
+					style.fontSize *= n / 100
+					continue
+				}
+			}
+			if size, ok := parseHTMLLength(d.value, style.fontSize); ok && size > 0 {
+				style.fontSize = size
+			} else {
+				c.warn(path, "unsupported font-size %q", d.value)
+			}
+		case "font-family":
+			family, _, _ := strings.Cut(d.raw, ",")
+			style.fontFamily = strings.Trim(strings.TrimSpace(family), `"'`)
+		case "font-weight":
+			weight, err := strconv.Atoi(d.value)
+			style.bold = d.value == "bold" || d.value == "bolder" || (err == nil && weight >= 600)
+		case "font-style":
+			style.italic = d.value == "italic" || d.value == "oblique"
+		case "text-align":
+			switch d.value {
+			case "left", "start", "justify":
+				style.align = Left
+			case "center":
+				style.align = Center
+			case "right", "end":
+				style.align = Right
+			default:
+				c.warn(path, "unsupported text-align %q", d.value)
+			}
+		}
+	}
+
+	return style
+}
+
+// applyBox applies the HTML attributes and the CSS properties of the element to the node.
+// It returns false if the element is not displayed
+func (c *htmlConverter) applyBox(node *Node, n *html.Node, declarations []cssDeclaration, path string, style htmlStyle, parentDirection direction) bool {
+	size := func(target *Size, property, value string) {
+		switch value {
+		case "auto":
+			*target = Size{Type: FitType, Min: minNotSet, Max: maxNotSet}
+		case "100%":
+			*target = Size{Type: GrowType, Min: target.Min, Max: target.Max}
+		default:
+			if n, ok := parseHTMLLength(value, style.fontSize); ok {
+				target.Type = FixedType
+				target.Value = n
+			} else {
+				c.warn(path, "unsupported %s %q, only lengths, auto and 100%% are supported", property, value)
+			}
+		}
+	}
+	color := func(target *string, property, value string) {
+		if parsed, ok := parseHTMLColor(value); ok {
+			*target = parsed
+		} else {
+			c.warn(path, "unsupported %s %q", property, value)
+		}
+	}
+	alignment := func(value string) (int, bool) {
+		switch value {
+		case "flex-start", "start", "left", "top":
+			return 0, true
+		case "center", "middle":
+			return 1, true
+		case "flex-end", "end", "right", "bottom":
+			return 2, true
+		}
+		return 0, false
+	}
+
+	// Legacy attributes, common in HTML emails
+	if value := htmlAttr(n, "width"); value != "" {
+		size(&node.Width, "width", value)
+	}
+	if value := htmlAttr(n, "height"); value != "" {
+		size(&node.Height, "height", value)
+	}
+	if value := htmlAttr(n, "bgcolor"); value != "" {
+		color(&node.BackgroundColor, "bgcolor", value)
+	}
+	if i, ok := alignment(htmlAttr(n, "align")); ok {
+		node.Horizontal = Horizontal(i)
+	}
+	if i, ok := alignment(htmlAttr(n, "valign")); ok {
+		node.Vertical = Vertical(i)
+	}
+
+	flex := false
+	var justify, alignItems string
+
+	for _, d := range declarations {
+		if htmlTextProperties[d.property] {
+			continue
+		}
+
+		switch d.property {
+		case "display":
+			switch d.value {
+			case "none":
+				return false
+			case "flex", "inline-flex":
+				flex = true
+				node.Direction = LeftToRight
+			case "block", "inline-block", "inline", "table", "table-cell":
+			default:
+				c.warn(path, "unsupported display %q", d.value)
+			}
+		case "flex-direction":
+			switch d.value {
+			case "row":
+				node.Direction = LeftToRight
+			case "column":
+				node.Direction = TopToBottom
+			default:
+				c.warn(path, "unsupported flex-direction %q", d.value)
+			}
+		case "justify-content":
+			justify = d.value
+		case "align-items":
+			alignItems = d.value
+		case "gap":
+			if gap, ok := parseHTMLLength(d.value, style.fontSize); ok {
+				node.ChildGap = gap
+			} else {
+				c.warn(path, "unsupported gap %q, only a single length is supported", d.value)
+			}
+		case "flex", "flex-grow":
+			grow, _, _ := strings.Cut(d.value, " ")
+			if n, err := strconv.ParseFloat(grow, 64); err == nil && n > 0 {
+				if parentDirection == LeftToRight {
+					node.Width = Size{Type: GrowType, Min: node.Width.Min, Max: node.Width.Max}
+				} else {
+					node.Height = Size{Type: GrowType, Min: node.Height.Min, Max: node.Height.Max}
+				}
+			} else if err != nil && d.value != "none" {
+				c.warn(path, "unsupported %s %q", d.property, d.value)
+			}
+		case "flex-shrink":
+			if n, err := strconv.ParseFloat(d.value, 64); err == nil && n >= 0 {
+				node.Shrink = n
+			} else {
+				c.warn(path, "unsupported flex-shrink %q", d.value)
+			}
+		case "padding", "margin":
+			sides, ok := parseHTMLSides(d.value, style.fontSize)
+			if !ok {
+				c.warn(path, "unsupported %s %q, only lengths are supported", d.property, d.value)
+			} else if d.property == "padding" {
+				node.Padding = sides
+			} else {
+				node.Margin = sides
+			}
+		case "padding-top", "padding-right", "padding-bottom", "padding-left",
+			"margin-top", "margin-right", "margin-bottom", "margin-left":
+			property, side, _ := strings.Cut(d.property, "-")
+			length, ok := parseHTMLLength(d.value, style.fontSize)
+			if !ok {
+				c.warn(path, "unsupported %s %q, only lengths are supported", d.property, d.value)
+				continue
+			}
+			index := map[string]int{"top": 0, "right": 1, "bottom": 2, "left": 3}[side]
+			if property == "padding" {
+				node.Padding[index] = length
+			} else {
+				node.Margin[index] = length
+			}
+		case "background", "background-color":
+			color(&node.BackgroundColor, d.property, d.value)
+		case "border":
+			for _, part := range strings.Fields(d.value) {
+				if width, ok := parseHTMLLength(part, style.fontSize); ok {
+					node.Border = width
+				} else if parsed, ok := parseHTMLColor(part); ok {
+					node.BorderColor = parsed
+				} else if part == "none" || part == "hidden" {
+					node.Border = 0
+				} else if part != "solid" {
+					c.warn(path, "unsupported border %q, borders are drawn solid", part)
+				}
+			}
+		case "border-width":
+			if width, ok := parseHTMLLength(d.value, style.fontSize); ok {
+				node.Border = width
+			} else {
+				c.warn(path, "unsupported border-width %q, only a single length is supported", d.value)
+			}
+		case "border-color":
+			color(&node.BorderColor, d.property, d.value)
+		case "border-style":
+			if d.value == "none" || d.value == "hidden" {
+				node.Border = 0
+			}
+		case "border-spacing":
+			spacing, ok := parseHTMLLength(d.value, style.fontSize)
+			if !ok || node.Type != GridType {
+				c.warn(path, "unsupported border-spacing %q", d.value)
+				continue
+			}
+			node.ColumnGap, node.RowGap = spacing, spacing
+		case "border-collapse":
+		case "width":
+			size(&node.Width, d.property, d.value)
+		case "height":
+			size(&node.Height, d.property, d.value)
+		case "min-width", "max-width", "min-height", "max-height":
+			length, ok := parseHTMLLength(d.value, style.fontSize)
+			if !ok {
+				c.warn(path, "unsupported %s %q, only lengths are supported", d.property, d.value)
+				continue
+			}
+			target := &node.Width
+			if strings.HasSuffix(d.property, "height") {
+				target = &node.Height
+			}
+			if strings.HasPrefix(d.property, "min") {
+				target.Min = length
+			} else {
+				target.Max = length
+			}
+		case "vertical-align":
+			if i, ok := alignment(d.value); ok {
+				node.Vertical = Vertical(i)
+			} else {
+				c.warn(path, "unsupported vertical-align %q", d.value)
+			}
+		default:
+			c.warn(path, "unsupported CSS property %q", d.property)
+		}
+	}
+
+	if flex {
+		var horizontal, vertical *string
+		if node.Direction == LeftToRight {
+			horizontal, vertical = &justify, &alignItems
+		} else {
+			horizontal, vertical = &alignItems, &justify
+		}
+
+		node.Horizontal = Left
+		if i, ok := alignment(*horizontal); ok {
+			node.Horizontal = Horizontal(i)
+		} else if *horizontal != "" && *horizontal != "stretch" && *horizontal != "normal" {
+			c.warn(path, "unsupported flex alignment %q", *horizontal)
+		}
+		if i, ok := alignment(*vertical); ok {
+			node.Vertical = Vertical(i)
+		} else if *vertical != "" && *vertical != "stretch" && *vertical != "normal" {
+			c.warn(path, "unsupported flex alignment %q", *vertical)
+		}
+	}
+
+	return true
+}
+
+// parseCSS parses the declarations of a style attribute
+func parseCSS(style string) []cssDeclaration {
+	var declarations []cssDeclaration
+	for _, part := range strings.Split(style, ";") {
+		property, value, ok := strings.Cut(part, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important"))
+		declarations = append(declarations, cssDeclaration{
+			property: strings.ToLower(strings.TrimSpace(property)),
+			value:    strings.ToLower(value),
+			raw:      value,
+		})
+	}
+	return declarations
+}
+
+// parseHTMLLength converts a CSS length to points. Numbers without a unit, used by
+// attributes such as width, are pixels
+func parseHTMLLength(value string, fontSize float64) (float64, bool) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	if value == "" {
+		return 0, false
+	}
+
+	scale := htmlPixel
+	for _, unit := range []struct {
+		suffix string
+		scale  float64
+	}{{"px", htmlPixel}, {"pt", 1}, {"rem", htmlDefaultFontSize}, {"em", fontSize}} {
+		if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, scale = number, unit.scale
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n * scale, true
+}
+
+// parseHTMLSides parses the 1 to 4 lengths of the padding and margin shorthands
+func parseHTMLSides(value string, fontSize float64) ([4]float64, bool) {
+	var lengths []float64
+	for _, part := range strings.Fields(value) {
+		n, ok := parseHTMLLength(part, fontSize)
+		if !ok {
+			return [4]float64{}, false
+		}
+		lengths = append(lengths, n)
+	}
+
+	switch len(lengths) {
+	case 1:
+		return [4]float64{lengths[0], lengths[0], lengths[0], lengths[0]}, true
+	case 2:
+		return [4]float64{lengths[0], lengths[1], lengths[0], lengths[1]}, true
+	case 3:
+		return [4]float64{lengths[0], lengths[1], lengths[2], lengths[1]}, true
+	case 4:
+		return [4]float64{lengths[0], lengths[1], lengths[2], lengths[3]}, true
+	default:
+		return [4]float64{}, false
+	}
+}
+
+// parseHTMLColor converts #RGB, #RRGGBB, rgb() and a few named colors to #RRGGBB.
+// transparent returns an empty color, which is not drawn
+func parseHTMLColor(value string) (string, bool) {
+	value = strings.TrimSpace(strings.ToLower(value))
+
+	if value == "transparent" {
+		return "", true
+	}
+	if named, ok := htmlNamedColors[value]; ok {
+		return named, true
+	}
+
+	if args, ok := strings.CutPrefix(value, "rgb("); ok {
+		parts := strings.Split(strings.TrimSuffix(args, ")"), ",")
+		if len(parts) != 3 {
+			return "", false
+		}
+		var rgb [3]int
+		for i, part := range parts {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || n < 0 || n > 255 {
+				return "", false
+			}
+			rgb[i] = n
+		}
+		return fmt.Sprintf("#%02X%02X%02X", rgb[0], rgb[1], rgb[2]), true
+	}
+
+	hex, ok := strings.CutPrefix(value, "#")
+	if !ok {
+		return "", false
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if _, _, _, err := hexToRGB(hex, ""); err != nil {
+		return "", false
+	}
+	return "#" + strings.ToUpper(hex), true
+}
+
+// htmlAttr returns the value of the attribute or an empty string
+func htmlAttr(n *html.Node, name string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == name {
+			return strings.TrimSpace(attr.Val)
+		}
+	}
+	return ""
+}
+
+// htmlAttrInt returns the value of a span attribute, 1 when it is missing or invalid
+func htmlAttrInt(n *html.Node, name string) int {
+	value, err := strconv.Atoi(htmlAttr(n, name))
+	if err != nil || value < 1 {
+		return 1
+	}
+	return value
+}
+
+// htmlDisplay returns the display property of the inline style
+func htmlDisplay(n *html.Node) string {
+	var display string
+	for _, d := range parseCSS(htmlAttr(n, "style")) {
+		if d.property == "display" {
+			display = d.value
+		}
+	}
+	return display
+}
@@ -0,0 +1,279 @@
+package sahar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func importHTML(t *testing.T, document string, opts ...htmlOpt) (*Node, []HTMLWarning) {
+	t.Helper()
+
+	root, warnings, err := ImportHTML(strings.NewReader(document), opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return root, warnings
+}
+
+func findWarning(warnings []HTMLWarning, path, message string) bool {
+	for _, w := range warnings {
+		if w.Path == path && strings.Contains(w.Message, message) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestImportHTML(t *testing.T) {
+	t.Run("converts blocks and text", func(t *testing.T) {
+		root, warnings := importHTML(t, `
+			<h1>Invoice</h1>
+			<p style="color: #333; font-size: 10px">Thank you for your order.</p>
+		`)
+
+		if len(warnings) != 1 || !findWarning(warnings, "body/h1[0]", "see HTMLFonts") {
+			t.Errorf("expected only the bold font warning, got %v", warnings)
+		}
+		if root.Type != BoxType || root.Direction != TopToBottom || len(root.Children) != 2 {
+			t.Fatalf("expected a vertical body with 2 children, got %+v", root)
+		}
+
+		heading := root.Children[0]
+		if heading.Width.Type != GrowType {
+			t.Error("expected blocks to fill the width of their parent")
+		}
+		if text := heading.Children[0]; text.Value != "Invoice" || text.FontSize != 24 {
+			t.Errorf("expected heading text of size 24, got %q of size %f", text.Value, text.FontSize)
+		}
+
+		paragraph := root.Children[1].Children[0]
+		if paragraph.Value != "Thank you for your order." || paragraph.FontSize != 7.5 || paragraph.FontColor != "#333333" {
+			t.Errorf("unexpected paragraph: %q %f %s", paragraph.Value, paragraph.FontSize, paragraph.FontColor)
+		}
+	})
+
+	t.Run("converts flex containers", func(t *testing.T) {
+		root, _ := importHTML(t, `
+			<div style="display: flex; gap: 8px; justify-content: center; align-items: flex-end; padding: 4px 8px; background-color: rgb(255, 0, 0)">
+				<div style="flex: 1; margin: 2pt">A</div>
+				<div style="width: 40px; flex-shrink: 0">B</div>
+			</div>
+		`)
+
+		row := root.Children[0]
+		if row.Direction != LeftToRight || row.ChildGap != 6 || row.Horizontal != Center || row.Vertical != Bottom {
+			t.Errorf("unexpected flex container: %+v", row)
+		}
+		if row.Padding != [4]float64{3, 6, 3, 6} || row.BackgroundColor != "#FF0000" {
+			t.Errorf("unexpected padding or background: %v %s", row.Padding, row.BackgroundColor)
+		}
+
+		a, b := row.Children[0], row.Children[1]
+		if a.Width.Type != GrowType || a.Margin != [4]float64{2, 2, 2, 2} {
+			t.Errorf("expected flex item to grow with margins, got %+v", a)
+		}
+		if b.Width.Type != FixedType || b.Width.Value != 30 || b.Shrink != 0 {
+			t.Errorf("expected fixed item of 30pt which never shrinks, got %+v", b)
+		}
+	})
+
+	t.Run("converts tables to grids", func(t *testing.T) {
+		root, warnings := importHTML(t, `
+			<table cellspacing="4" cellpadding="2" border="1">
+				<thead><tr><th colspan="2">Item</th><th>Price</th></tr></thead>
+				<tbody>
+					<tr><td rowspan="2">A</td><td>B</td><td width="80">10</td></tr>
+					<tr><td>C</td><td>20</td></tr>
+				</tbody>
+			</table>
+		`)
+
+		if len(warnings) != 1 || !strings.Contains(warnings[0].Message, "HTMLFonts") {
+			t.Errorf("expected only the bold font warning, got %v", warnings)
+		}
+
+		grid := root.Children[0]
+		if grid.Type != GridType || grid.ColumnGap != 3 || grid.RowGap != 3 {
+			t.Fatalf("expected a grid with 3pt gaps, got %+v", grid)
+		}
+		if len(grid.Columns) != 3 || grid.Columns[0] != FitTrack() || grid.Columns[2] != FixedTrack(60) {
+			t.Errorf("unexpected columns: %v", grid.Columns)
+		}
+
+		cells := []GridCell{
+			{Row: 0, Column: 0, RowSpan: 1, ColumnSpan: 2, Placed: true},
+			{Row: 0, Column: 2, RowSpan: 1, ColumnSpan: 1, Placed: true},
+			{Row: 1, Column: 0, RowSpan: 2, ColumnSpan: 1, Placed: true},
+			{Row: 1, Column: 1, RowSpan: 1, ColumnSpan: 1, Placed: true},
+			{Row: 1, Column: 2, RowSpan: 1, ColumnSpan: 1, Placed: true},
+			{Row: 2, Column: 1, RowSpan: 1, ColumnSpan: 1, Placed: true},
+			{Row: 2, Column: 2, RowSpan: 1, ColumnSpan: 1, Placed: true},
+		}
+		if len(grid.Children) != len(cells) {
+			t.Fatalf("expected %d cells, got %d", len(cells), len(grid.Children))
+		}
+		for i, cell := range cells {
+			if grid.Children[i].Cell != cell {
+				t.Errorf("cell %d: expected %+v, got %+v", i, cell, grid.Children[i].Cell)
+			}
+		}
+
+		header := grid.Children[0]
+		if header.Padding != [4]float64{1.5, 1.5, 1.5, 1.5} || header.Border != 0.75 || header.Horizontal != Center {
+			t.Errorf("unexpected header cell: %+v", header)
+		}
+		if cell := grid.Children[3]; cell.Width.Type != GrowType || cell.Height.Type != GrowType {
+			t.Error("expected cells to fill their tracks")
+		}
+	})
+
+	t.Run("clamps the spans of table cells", func(t *testing.T) {
+		root, warnings := importHTML(t, `<table>
+			<tr><td rowspan="99999999" colspan="5000">A</td><td>B</td></tr>
+			<tr><td>C</td></tr>
+		</table>`)
+
+		for _, message := range []string{"rowspan 99999999 is clamped to 65534", "colspan 5000 is clamped to 1000"} {
+			if !findWarning(warnings, "body/table[0]/tbody[0]/tr[0]/td[0]", message) {
+				t.Errorf("expected warning %q, got %v", message, warnings)
+			}
+		}
+
+		grid := root.Children[0]
+		if cell := grid.Children[0].Cell; cell.RowSpan != 2 || cell.ColumnSpan != htmlMaxColumnSpan {
+			t.Errorf("expected the cell to span the 2 rows of the table and 1000 columns, got %+v", cell)
+		}
+		if len(grid.Columns) != htmlMaxColumnSpan+1 {
+			t.Errorf("expected %d columns, got %d", htmlMaxColumnSpan+1, len(grid.Columns))
+		}
+	})
+
+	t.Run("converts images with a size", func(t *testing.T) {
+		root, warnings := importHTML(t, `
+			<img src="logo.png" width="100" height="50">
+			<img src="photo.png" style="width: 20pt">
+		`)
+
+		if len(root.Children) != 1 {
+			t.Fatalf("expected a single image, got %d children", len(root.Children))
+		}
+		if image := root.Children[0]; image.Type != ImageType || image.Value != "logo.png" || image.Width.Value != 75 || image.Height.Value != 37.5 {
+			t.Errorf("unexpected image: %+v", image)
+		}
+		if !findWarning(warnings, "body/img[1]", "without a width and a height") {
+			t.Errorf("expected a warning for the image without height, got %v", warnings)
+		}
+	})
+
+	t.Run("uses the HTML fonts for inline styles", func(t *testing.T) {
+		root, warnings := importHTML(t, `<p><strong>Total</strong></p><p><em>Note</em> <span style="font-family: 'Courier New', monospace">x</span></p>`,
+			HTMLFonts("Regular", "Bold", "Italic", ""))
+
+		if text := root.Children[0].Children[0]; text.FontType != "Bold" {
+			t.Errorf("expected bold font, got %q", text.FontType)
+		}
+		if text := root.Children[1].Children[0]; text.Value != "Note x" || text.FontType != "Italic" {
+			t.Errorf("expected flattened italic text, got %q with %q", text.Value, text.FontType)
+		}
+		if !findWarning(warnings, "body/p[1]", "mixing styles") {
+			t.Errorf("expected a warning for mixed styles, got %v", warnings)
+		}
+	})
+
+	t.Run("splits lines at br", func(t *testing.T) {
+		root, _ := importHTML(t, `<p>First line<br>Second   line</p>`)
+
+		paragraph := root.Children[0]
+		if len(paragraph.Children) != 2 || paragraph.Children[0].Value != "First line" || paragraph.Children[1].Value != "Second line" {
+			t.Errorf("expected two lines, got %+v", paragraph.Children)
+		}
+	})
+
+	t.Run("reports unsupported constructs", func(t *testing.T) {
+		root, warnings := importHTML(t, `
+			<html><head><style>p { color: red }</style></head><body>
+			<ul><li>One</li></ul>
+			<p style="line-height: 2; display: none">Hidden</p>
+			<div style="border-radius: 4px; margin: 0 auto">Text with <a href="#">a link</a></div>
+			<script>alert(1)</script>
+			</body></html>
+		`)
+
+		expected := []struct{ path, message string }{
+			{"head", "style sheets"},
+			{"body/ul[0]", "<ul> is converted as a div"},
+			{"body/ul[0]/li[0]", "<li> is converted as a div"},
+			{"body/div[0]", `"border-radius"`},
+			{"body/div[0]", "margin"},
+			{"body/div[0]/a[0]", "only its text is kept"},
+			{"body/script[0]", "<script>"},
+		}
+		for _, e := range expected {
+			if !findWarning(warnings, e.path, e.message) {
+				t.Errorf("expected warning %q at %s, got %v", e.message, e.path, warnings)
+			}
+		}
+
+		// hidden elements are not converted
+		if len(root.Children) != 2 {
+			t.Errorf("expected 2 children, got %d", len(root.Children))
+		}
+	})
+
+	t.Run("renders to PDF", func(t *testing.T) {
+		body, _ := importHTML(t, `
+			<div style="display: flex; padding: 20px; background: #F5F5F5">
+				<h2 style="flex: 1">Order</h2>
+				<table width="200" style="border: 1px solid #CCCCCC"><tr><td>Total</td><td>10</td></tr></table>
+			</div>
+		`)
+		page := Layout(Box(Sizing(A4()...), Children(body)))
+
+		var buf bytes.Buffer
+		if err := RenderToPDF(&buf, page); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if body.Width.Value != 595.28 {
+			t.Errorf("expected body to fill the page, got %f", body.Width.Value)
+		}
+	})
+}
+
+func TestParseHTMLValues(t *testing.T) {
+	lengths := map[string]float64{"10px": 7.5, "10pt": 10, "2em": 20, "1rem": 12, "0": 0, "8": 6}
+	for value, want := range lengths {
+		if got, ok := parseHTMLLength(value, 10); !ok || got != want {
+			t.Errorf("length %q: expected %f, got %f (%t)", value, want, got, ok)
+		}
+	}
+	for _, value := range []string{"", "auto", "-1px", "10vh"} {
+		if _, ok := parseHTMLLength(value, 10); ok {
+			t.Errorf("expected length %q to be invalid", value)
+		}
+	}
+
+	colors := map[string]string{"#abc": "#AABBCC", "#A1B2C3": "#A1B2C3", "rgb(0, 128, 255)": "#0080FF", "navy": "#000080", "transparent": ""}
+	for value, want := range colors {
+		if got, ok := parseHTMLColor(value); !ok || got != want {
+			t.Errorf("color %q: expected %q, got %q (%t)", value, want, got, ok)
+		}
+	}
+	for _, value := range []string{"#12", "rgb(1,2)", "rgba(0,0,0,0.5)", "chartreuse"} {
+		if _, ok := parseHTMLColor(value); ok {
+			t.Errorf("expected color %q to be invalid", value)
+		}
+	}
+
+	sides := map[string][4]float64{
+		"4px":             {3, 3, 3, 3},
+		"4px 8px":         {3, 6, 3, 6},
+		"4px 8px 12px":    {3, 6, 9, 6},
+		"1pt 2pt 3pt 4pt": {1, 2, 3, 4},
+	}
+	for value, want := range sides {
+		if got, ok := parseHTMLSides(value, 12); !ok || got != want {
+			t.Errorf("sides %q: expected %v, got %v (%t)", value, want, got, ok)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package sahar
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Link makes the node a link to a URL, or to the node with the given anchor when the
+// target starts with #, for example Link("#totals"). The whole border box is clickable
+func Link(target string) commonOpt {
+	return commonOptFunc(func(n *Node) {
+		n.Link = target
+	})
+}
+
+// Anchor names the node, so links can point to it with Link("#name").
+// Names are unique among the pages rendered together
+func Anchor(name string) commonOpt {
+	return commonOptFunc(func(n *Node) {
+		n.Anchor = name
+	})
+}
+
+// Bookmark adds the node to the outline of the PDF with the given title. Level 0 is
+// the top level and an entry of level 1 is nested in the last entry of level 0, so
+// bookmarks can't skip a level
+func Bookmark(title string, level int) commonOpt {
+	return commonOptFunc(func(n *Node) {
+		n.Bookmark = title
+		n.BookmarkLevel = level
+	})
+}
+
+// checkLinks reports links to anchors missing from the pages, anchors used twice and
+// bookmarks skipping a level, before anything is drawn
+func checkLinks(pages []*Node) error {
+	anchors := map[string]bool{}
+	var links []string
+	level := -1
+
+	var walk func(node *Node) error
+	walk = func(node *Node) error {
+		if node == nil {
+			return nil
+		}
+
+		if node.Anchor != "" {
+			if anchors[node.Anchor] {
+				return fmt.Errorf("anchor %q is used by several nodes", node.Anchor)
+			}
+			anchors[node.Anchor] = true
+		}
+		if strings.HasPrefix(node.Link, "#") {
+			links = append(links, node.Link[1:])
+		}
+		if node.Bookmark != "" {
+			if node.BookmarkLevel < 0 || node.BookmarkLevel > level+1 {
+				return fmt.Errorf("bookmark %q has level %d, expected a level from 0 to %d", node.Bookmark, node.BookmarkLevel, level+1)
+			}
+			level = node.BookmarkLevel
+		}
+
+		for _, child := range node.Children {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, page := range pages {
+		if err := walk(page); err != nil {
+			return err
+		}
+	}
+
+	for _, anchor := range links {
+		if !anchors[anchor] {
+			return fmt.Errorf("link to unknown anchor %q", anchor)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package sahar
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLink(t *testing.T) {
+	t.Run("draws links, anchors and bookmarks", func(t *testing.T) {
+		first := Layout(Box(
+			Sizing(Fixed(100), Fixed(100)),
+			Children(
+				Text("Contents", FontType("Arial"), FontSize(10), Link("#totals"), Bookmark("Contents", 0)),
+				Box(Sizing(Fixed(20), Fixed(10)), Link("https://example.com")),
+			),
+		))
+		second := Layout(Box(
+			Sizing(Fixed(100), Fixed(100)),
+			Padding(10, 10, 10, 10),
+			Children(Box(Sizing(Fixed(20), Fixed(10)), Anchor("totals"), Bookmark("Totals", 1))),
+		))
+
+		canvas := &recordingCanvas{}
+		if err := Render(canvas, first, second); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var marks []string
+		for _, call := range canvas.calls {
+			if strings.HasPrefix(call, "link") || strings.HasPrefix(call, "anchor") || strings.HasPrefix(call, "bookmark") {
+				marks = append(marks, call)
+			}
+		}
+
+		text := first.Children[0]
+		expected := []string{
+			fmt.Sprintf("link #totals 0,0 %gx%g", text.Width.Value, text.Height.Value),
+			"bookmark Contents 0 0,0",
+			fmt.Sprintf("link https://example.com %g,0 20x10", text.Width.Value),
+			"anchor totals 10,10",
+			"bookmark Totals 1 10,10",
+		}
+		if strings.Join(marks, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(marks, "\n"))
+		}
+	})
+
+	t.Run("reports broken links and outlines", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			pages []*Node
+			err   string
+		}{
+			{
+				name:  "unknown anchor",
+				pages: []*Node{Box(Link("#missing"))},
+				err:   `link to unknown anchor "missing"`,
+			},
+			{
+				name:  "duplicate anchor",
+				pages: []*Node{Box(Anchor("top")), Box(Anchor("top"))},
+				err:   `anchor "top" is used by several nodes`,
+			},
+			{
+				name:  "first bookmark nested",
+				pages: []*Node{Box(Bookmark("Intro", 1))},
+				err:   `bookmark "Intro" has level 1, expected a level from 0 to 0`,
+			},
+			{
+				name:  "skipped level",
+				pages: []*Node{Box(Bookmark("Intro", 0), Children(Box(Bookmark("Details", 2))))},
+				err:   `bookmark "Details" has level 2, expected a level from 0 to 1`,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var opts []RenderOption
+				for _, page := range tt.pages {
+					opts = append(opts, Layout(page))
+				}
+
+				var buf bytes.Buffer
+				if err := RenderPDF(&buf, opts...); err == nil || err.Error() != tt.err {
+					t.Errorf("expected error %q, got %v", tt.err, err)
+				}
+				if err := Render(&recordingCanvas{}, opts...); err == nil || err.Error() != tt.err {
+					t.Errorf("expected error %q from Render, got %v", tt.err, err)
+				}
+			})
+		}
+	})
+
+	t.Run("writes PDF links and outline", func(t *testing.T) {
+		first := Layout(Box(
+			Sizing(Fixed(200), Fixed(200)),
+			Children(
+				Box(Sizing(Fixed(20), Fixed(10)), Link("https://example.com/report")),
+				Box(Sizing(Fixed(20), Fixed(10)), Link("#summary")),
+				Box(Sizing(Fixed(20), Fixed(50)), Margin(50, 0, 0, 0), Bookmark("Überblick", 0)),
+			),
+		))
+		second := Layout(Box(Sizing(Fixed(100), Fixed(100)), Anchor("summary"), Bookmark("Summary", 1)))
+
+		var buf bytes.Buffer
+		if err := RenderToPDF(&buf, first, second); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		output := buf.String()
+		for _, expected := range []string{
+			"/URI (https://example.com/report)",
+			"/Dest [5 0 R /XYZ 0 100.00 null]", // top of the second page
+			"/Type /Outlines",
+			"/Title (Summary)",
+			"/Title (\xfe\xff\x00\xdc\x00b", // UTF-16 with a byte order mark
+			"/XYZ 0 150.00 null",            // the bookmark of the first page, 50pt below the top of a 200pt page
+		} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("expected %q in the PDF", expected)
+			}
+		}
+	})
+}
+
+func TestPDFTextString(t *testing.T) {
+	if s := pdfTextString("Summary"); s != "Summary" {
+		t.Errorf("expected ASCII to be kept, got %q", s)
+	}
+	if s := pdfTextString("é€"); s != "\xfe\xff\x00\xe9\x20\xac" {
+		t.Errorf("expected UTF-16 with a byte order mark, got %q", s)
+	}
+}
@@ -0,0 +1,466 @@
+package sahar
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Theme holds the fonts, sizes and colors used to turn Markdown into nodes
+type Theme struct {
+	FontType           string
+	BoldFontType       string
+	ItalicFontType     string
+	BoldItalicFontType string
+	CodeFontType       string
+	FontSize           float64
+	CodeFontSize       float64
+	HeadingSizes       [6]float64 // Font sizes of the headings from h1 to h6
+	FontColor          string
+	HeadingColor       string
+	LinkColor          string
+	CodeColor          string
+	CodeBackground     string
+	QuoteColor         string
+	QuoteBarColor      string
+	RuleColor          string
+	TableBorderColor   string
+	TableHeaderColor   string  // Background of the table header
+	BlockGap           float64 // Space between paragraphs, lists, tables and other blocks
+	ListIndent         float64 // Width of the list markers
+	Bullet             string  // Marker of unordered list items
+	ImageMaxWidth      float64 // Larger images are scaled down, 0 keeps their size
+}
+
+// DefaultTheme returns the default Markdown theme
+func DefaultTheme() Theme {
+	return Theme{
+		FontType:           "Arial",
+		BoldFontType:       "Arial",
+		ItalicFontType:     "Arial",
+		BoldItalicFontType: "Arial",
+		CodeFontType:       "Courier",
+		FontSize:           11,
+		CodeFontSize:       10,
+		HeadingSizes:       [6]float64{24, 18, 15, 13, 11, 10},
+		FontColor:          "#222222",
+		HeadingColor:       "#111111",
+		LinkColor:          "#1A5FB4",
+		CodeColor:          "#333333",
+		CodeBackground:     "#F3F3F3",
+		QuoteColor:         "#555555",
+		QuoteBarColor:      "#CCCCCC",
+		RuleColor:          "#CCCCCC",
+		TableBorderColor:   "#CCCCCC",
+		TableHeaderColor:   "#EEEEEE",
+		BlockGap:           8,
+		ListIndent:         16,
+		Bullet:             "-",
+		ImageMaxWidth:      0,
+	}
+}
+
+// Markdown converts Markdown to a node tree styled with the theme. Headings, paragraphs,
+// emphasis, lists, code blocks, block quotes, tables, images, links and thematic breaks
+// are supported, raw HTML is ignored.
+//
+// The result is a box growing to the width of its parent with one child per block, so
+// long documents can be split into pages. A text node has a single style, so a paragraph
+// mixing styles, for example a sentence with a single bold word, uses the regular style.
+// Images are sized from their files, which are read relative to the working directory
+func Markdown(source []byte, theme Theme) (*Node, error) {
+	document := goldmark.New(goldmark.WithExtensions(extension.Table)).Parser().Parse(text.NewReader(source))
+
+	c := &markdownConverter{source: source, theme: theme}
+	root := Box(Sizing(Grow()), Direction(TopToBottom), ChildGap(theme.BlockGap))
+	if err := c.blocks(root, document, markdownStyle{}); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// markdownConverter walks the Markdown document
+type markdownConverter struct {
+	source []byte
+	theme  Theme
+}
+
+// markdownStyle is the style of a piece of text
+type markdownStyle struct {
+	bold, italic, code, link, quote bool
+}
+
+// markdownRun is a piece of text with a single style, a line break or an image
+type markdownRun struct {
+	text      string
+	style     markdownStyle
+	lineBreak bool
+	image     *ast.Image
+}
+
+// blocks converts the block children of parent and adds them to node
+func (c *markdownConverter) blocks(node *Node, parent ast.Node, style markdownStyle) error {
+	for child := parent.FirstChild(); child != nil; child = child.NextSibling() {
+		converted, err := c.block(child, style)
+		if err != nil {
+			return err
+		}
+		for _, n := range converted {
+			n.configureNode(node)
+		}
+	}
+	return nil
+}
+
+// block converts a single block, paragraphs with images can become several nodes
+func (c *markdownConverter) block(n ast.Node, style markdownStyle) ([]*Node, error) {
+	theme := c.theme
+
+	switch n := n.(type) {
+	case *ast.Heading:
+		style.bold = true
+		size := theme.HeadingSizes[min(max(n.Level, 1), 6)-1]
+		nodes, err := c.paragraph(n, style, size, theme.HeadingColor)
+		for _, node := range nodes {
+			if node.Type != ImageType {
+				node.Role = HeadingRole(n.Level)
+			}
+		}
+		return nodes, err
+	case *ast.Paragraph, *ast.TextBlock:
+		return c.paragraph(n, style, theme.FontSize, "")
+	case *ast.List:
+		list, err := c.list(n, style)
+		return []*Node{list}, err
+	case *ast.FencedCodeBlock:
+		return []*Node{c.code(n.Lines())}, nil
+	case *ast.CodeBlock:
+		return []*Node{c.code(n.Lines())}, nil
+	case *ast.Blockquote:
+		style.quote = true
+		content := Box(Sizing(Grow()), Direction(TopToBottom), ChildGap(theme.BlockGap))
+		if err := c.blocks(content, n, style); err != nil {
+			return nil, err
+		}
+		bar := Box(Sizing(Fixed(3), Grow()), BackgroundColor(theme.QuoteBarColor))
+		return []*Node{Box(Sizing(Grow()), ChildGap(theme.BlockGap), Tag(RoleQuote), Children(bar, content))}, nil
+	case *ast.ThematicBreak:
+		return []*Node{Box(Sizing(Grow(), Fixed(1)), BackgroundColor(theme.RuleColor))}, nil
+	case *extast.Table:
+		table, err := c.table(n, style)
+		return []*Node{table}, err
+	default:
+		// raw HTML and link reference definitions are not rendered
+		return nil, nil
+	}
+}
+
+// paragraph converts the inline content of n to text lines and images
+func (c *markdownConverter) paragraph(n ast.Node, style markdownStyle, fontSize float64, color string) ([]*Node, error) {
+	var runs []markdownRun
+	c.inline(n, style, &runs)
+
+	var nodes, lines []*Node
+	var line []markdownRun
+	addLines := func() {
+		switch len(lines) {
+		case 0:
+		case 1:
+			nodes = append(nodes, lines[0])
+		default:
+			nodes = append(nodes, Box(Sizing(Grow()), Direction(TopToBottom), Tag(RoleParagraph), Children(lines...)))
+		}
+		lines = nil
+	}
+	addLine := func() {
+		if text := c.text(line, style, fontSize, color); text != nil {
+			lines = append(lines, text)
+		}
+		line = nil
+	}
+
+	for _, run := range runs {
+		switch {
+		case run.image != nil:
+			addLine()
+			addLines()
+			image, err := c.image(run.image)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, image)
+		case run.lineBreak:
+			addLine()
+		default:
+			line = append(line, run)
+		}
+	}
+	addLine()
+	addLines()
+
+	return nodes, nil
+}
+
+// inline collects the text of the inline children of n
+func (c *markdownConverter) inline(n ast.Node, style markdownStyle, runs *[]markdownRun) {
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		switch child := child.(type) {
+		case *ast.Text:
+			*runs = append(*runs, markdownRun{text: string(child.Segment.Value(c.source)), style: style})
+			if child.HardLineBreak() {
+				*runs = append(*runs, markdownRun{lineBreak: true})
+			} else if child.SoftLineBreak() {
+				*runs = append(*runs, markdownRun{text: " ", style: style})
+			}
+		case *ast.String:
+			*runs = append(*runs, markdownRun{text: string(child.Value), style: style})
+		case *ast.CodeSpan:
+			code := style
+			code.code = true
+			c.inline(child, code, runs)
+		case *ast.Emphasis:
+			emphasis := style
+			if child.Level >= 2 {
+				emphasis.bold = true
+			} else {
+				emphasis.italic = true
+			}
+			c.inline(child, emphasis, runs)
+		case *ast.Link:
+			link := style
+			link.link = true
+			c.inline(child, link, runs)
+		case *ast.AutoLink:
+			link := style
+			link.link = true
+			*runs = append(*runs, markdownRun{text: string(child.Label(c.source)), style: link})
+		case *ast.Image:
+			*runs = append(*runs, markdownRun{image: child})
+		case *ast.RawHTML:
+			// raw HTML is not rendered
+		default:
+			c.inline(child, style, runs)
+		}
+	}
+}
+
+// text creates a text node for a line. When the parts of the line do not share a style
+// the line uses the base style
+func (c *markdownConverter) text(line []markdownRun, base markdownStyle, fontSize float64, color string) *Node {
+	var value strings.Builder
+	var style *markdownStyle
+	mixed := false
+
+	for i, run := range line {
+		value.WriteString(run.text)
+		if strings.TrimSpace(run.text) == "" {
+			continue
+		}
+		if style == nil {
+			style = &line[i].style
+		} else if *style != run.style {
+			mixed = true
+		}
+	}
+
+	content := strings.Join(strings.Fields(value.String()), " ")
+	if style == nil || content == "" {
+		return nil
+	}
+	if mixed {
+		style = &base
+	}
+
+	theme := c.theme
+	fontType := theme.FontType
+	switch {
+	case style.code:
+		fontType = theme.CodeFontType
+		if fontSize == theme.FontSize {
+			fontSize = theme.CodeFontSize
+		}
+	case style.bold && style.italic:
+		fontType = theme.BoldItalicFontType
+	case style.bold:
+		fontType = theme.BoldFontType
+	case style.italic:
+		fontType = theme.ItalicFontType
+	}
+
+	switch {
+	case color != "":
+	case style.link:
+		color = theme.LinkColor
+	case style.code:
+		color = theme.CodeColor
+	case style.quote:
+		color = theme.QuoteColor
+	default:
+		color = theme.FontColor
+	}
+
+	return Text(content, FontType(fontType), FontSize(fontSize), FontColor(color))
+}
+
+// list converts an ordered or unordered list, every item is a marker next to its blocks
+func (c *markdownConverter) list(n *ast.List, style markdownStyle) (*Node, error) {
+	theme := c.theme
+
+	gap := theme.BlockGap
+	if n.IsTight {
+		gap /= 2
+	}
+
+	list := Box(Sizing(Grow()), Direction(TopToBottom), ChildGap(gap), Tag(RoleList))
+	number := n.Start
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := theme.Bullet
+		if n.IsOrdered() {
+			marker = fmt.Sprintf("%d.", number)
+			number++
+		}
+
+		markerText := Text(marker, FontType(theme.FontType), FontSize(theme.FontSize), FontColor(theme.FontColor))
+		markerText.Role = RoleListLabel
+		markerText.Width = Size{Type: FixedType, Value: theme.ListIndent, Min: minNotSet, Max: maxNotSet}
+		markerText.Shrink = 0
+
+		content := Box(Sizing(Grow()), Direction(TopToBottom), ChildGap(gap), Tag(RoleListBody))
+		if err := c.blocks(content, item, style); err != nil {
+			return nil, err
+		}
+
+		Box(Sizing(Grow()), Tag(RoleListItem), Children(markerText, content)).configureNode(list)
+	}
+
+	return list, nil
+}
+
+// code converts the lines of a code block, one text node per line so the lines are kept
+func (c *markdownConverter) code(lines *text.Segments) *Node {
+	theme := c.theme
+	block := Box(
+		Sizing(Grow()),
+		Direction(TopToBottom),
+		Padding(theme.BlockGap, theme.BlockGap, theme.BlockGap, theme.BlockGap),
+		BackgroundColor(theme.CodeBackground),
+	)
+
+	spaceWidth := measureTextWidth(" ", theme.CodeFontSize, theme.CodeFontType)
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		line := strings.TrimRight(string(segment.Value(c.source)), "\r\n")
+		line = strings.ReplaceAll(line, "\t", "    ")
+
+		// Text is wrapped on spaces, so the indentation is kept as a margin
+		content := strings.TrimLeft(line, " ")
+		indent := float64(len(line)-len(content)) + float64(segment.Padding)
+		if content == "" {
+			content = " "
+		}
+
+		text := Text(content, FontType(theme.CodeFontType), FontSize(theme.CodeFontSize), FontColor(theme.CodeColor))
+		text.Margin[3] = indent * spaceWidth
+		text.configureNode(block)
+	}
+
+	return block
+}
+
+// table converts a table to a grid with equal columns
+func (c *markdownConverter) table(n *extast.Table, style markdownStyle) (*Node, error) {
+	theme := c.theme
+
+	columns := make([]Track, len(n.Alignments))
+	for i := range columns {
+		columns[i] = FractionTrack(1)
+	}
+	grid := Grid(Sizing(Grow()), Columns(columns...), Tag(RoleTable))
+
+	for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+		_, header := row.(*extast.TableHeader)
+
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cellStyle := style
+			cellStyle.bold = header
+
+			texts, err := c.paragraph(cell, cellStyle, theme.FontSize, "")
+			if err != nil {
+				return nil, err
+			}
+
+			box := Box(
+				Sizing(Grow(), Grow()),
+				Direction(TopToBottom),
+				Padding(theme.BlockGap/2, theme.BlockGap/2, theme.BlockGap/2, theme.BlockGap/2),
+				Border(0.5),
+				BorderColor(theme.TableBorderColor),
+				Children(texts...),
+			)
+			if header {
+				box.BackgroundColor = theme.TableHeaderColor
+				box.Role = RoleTableHeader
+			}
+
+			if cell, ok := cell.(*extast.TableCell); ok {
+				switch cell.Alignment {
+				case extast.AlignCenter:
+					box.Horizontal = Center
+				case extast.AlignRight:
+					box.Horizontal = Right
+				}
+				for _, text := range texts {
+					text.Horizontal = box.Horizontal
+				}
+			}
+
+			box.configureNode(grid)
+		}
+	}
+
+	return grid, nil
+}
+
+// image creates an image node sized from the image file
+func (c *markdownConverter) image(n *ast.Image) (*Node, error) {
+	src := string(n.Destination)
+
+	file, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %s: %w", src, err)
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %s: %w", src, err)
+	}
+
+	// A pixel is 1/96 inch like in a browser
+	width := float64(config.Width) * htmlPixel
+	height := float64(config.Height) * htmlPixel
+	if maxWidth := c.theme.ImageMaxWidth; maxWidth > 0 && width > maxWidth {
+		height = height * maxWidth / width
+		width = maxWidth
+	}
+
+	// The alt text is the text of the image description
+	var runs []markdownRun
+	c.inline(n, markdownStyle{}, &runs)
+	var alt strings.Builder
+	for _, run := range runs {
+		alt.WriteString(run.text)
+	}
+
+	return Image(src, Sizing(Fixed(width), Fixed(height)), AltText(strings.TrimSpace(alt.String()))), nil
+}
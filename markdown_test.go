@@ -0,0 +1,183 @@
+package sahar
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	theme := DefaultTheme()
+
+	convert := func(t *testing.T, source string) *Node {
+		t.Helper()
+		root, err := Markdown([]byte(source), theme)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return root
+	}
+
+	t.Run("converts headings and paragraphs", func(t *testing.T) {
+		root := convert(t, "# Release notes\n\nThis release\nfixes *many* bugs.\n\n## **Fixes**\n")
+
+		if root.Direction != TopToBottom || root.Width.Type != GrowType || root.ChildGap != theme.BlockGap {
+			t.Errorf("unexpected root: %+v", root)
+		}
+		if len(root.Children) != 3 {
+			t.Fatalf("expected 3 blocks, got %d", len(root.Children))
+		}
+
+		heading := root.Children[0]
+		if heading.Value != "Release notes" || heading.FontSize != 24 || heading.FontType != theme.BoldFontType || heading.FontColor != theme.HeadingColor {
+			t.Errorf("unexpected heading: %+v", heading)
+		}
+
+		paragraph := root.Children[1]
+		if paragraph.Value != "This release fixes many bugs." || paragraph.FontType != theme.FontType || paragraph.FontColor != theme.FontColor {
+			t.Errorf("unexpected paragraph: %+v", paragraph)
+		}
+
+		if subheading := root.Children[2]; subheading.FontSize != 18 {
+			t.Errorf("expected h2 size 18, got %f", subheading.FontSize)
+		}
+	})
+
+	t.Run("uses the style shared by the whole line", func(t *testing.T) {
+		root := convert(t, "*all italic*\n\n`code`\n\n[a link](https://example.com)\n\nfirst line  \nsecond line\n")
+
+		if italic := root.Children[0]; italic.FontType != theme.ItalicFontType {
+			t.Errorf("expected italic font, got %q", italic.FontType)
+		}
+		if code := root.Children[1]; code.FontType != theme.CodeFontType || code.FontSize != theme.CodeFontSize {
+			t.Errorf("expected code font, got %q of size %f", code.FontType, code.FontSize)
+		}
+		if link := root.Children[2]; link.Value != "a link" || link.FontColor != theme.LinkColor {
+			t.Errorf("expected link color, got %+v", link)
+		}
+
+		lines := root.Children[3]
+		if len(lines.Children) != 2 || lines.Children[0].Value != "first line" || lines.Children[1].Value != "second line" {
+			t.Errorf("expected hard line break to split the paragraph, got %+v", lines.Children)
+		}
+	})
+
+	t.Run("converts lists", func(t *testing.T) {
+		root := convert(t, "3. three\n4. four\n   - nested\n")
+
+		list := root.Children[0]
+		if len(list.Children) != 2 {
+			t.Fatalf("expected 2 items, got %d", len(list.Children))
+		}
+
+		first := list.Children[0]
+		marker, content := first.Children[0], first.Children[1]
+		if marker.Value != "3." || marker.Width.Type != FixedType || marker.Width.Value != theme.ListIndent {
+			t.Errorf("unexpected marker: %+v", marker)
+		}
+		if content.Children[0].Value != "three" {
+			t.Errorf("unexpected item content: %+v", content.Children[0])
+		}
+
+		nested := list.Children[1].Children[1].Children[1]
+		if nested.Children[0].Children[0].Value != theme.Bullet {
+			t.Errorf("expected nested bullet list, got %+v", nested)
+		}
+	})
+
+	t.Run("keeps code block lines and indentation", func(t *testing.T) {
+		root := convert(t, "```go\nfunc main() {\n    run()\n}\n```\n")
+
+		code := root.Children[0]
+		if code.BackgroundColor != theme.CodeBackground || len(code.Children) != 3 {
+			t.Fatalf("unexpected code block: %+v", code)
+		}
+		if line := code.Children[1]; line.Value != "run()" || line.Margin[3] <= 0 || line.FontType != theme.CodeFontType {
+			t.Errorf("expected indented code line, got %+v", line)
+		}
+	})
+
+	t.Run("converts block quotes and rules", func(t *testing.T) {
+		root := convert(t, "> quoted\n\n---\n")
+
+		quote := root.Children[0]
+		bar, content := quote.Children[0], quote.Children[1]
+		if bar.BackgroundColor != theme.QuoteBarColor || bar.Height.Type != GrowType {
+			t.Errorf("unexpected quote bar: %+v", bar)
+		}
+		if text := content.Children[0]; text.Value != "quoted" || text.FontColor != theme.QuoteColor {
+			t.Errorf("unexpected quote text: %+v", text)
+		}
+
+		if rule := root.Children[1]; rule.Height.Value != 1 || rule.BackgroundColor != theme.RuleColor {
+			t.Errorf("unexpected rule: %+v", rule)
+		}
+	})
+
+	t.Run("converts tables", func(t *testing.T) {
+		root := convert(t, "| Item | Price |\n| :--- | ---: |\n| Pen | 2 |\n| Book | 10 |\n")
+
+		grid := root.Children[0]
+		if grid.Type != GridType || len(grid.Columns) != 2 || len(grid.Children) != 6 {
+			t.Fatalf("unexpected table: %+v", grid)
+		}
+
+		header := grid.Children[0]
+		if header.BackgroundColor != theme.TableHeaderColor || header.Children[0].FontType != theme.BoldFontType {
+			t.Errorf("unexpected header cell: %+v", header)
+		}
+		if price := grid.Children[3]; price.Horizontal != Right || price.Children[0].Value != "2" {
+			t.Errorf("expected right aligned price, got %+v", price)
+		}
+	})
+
+	t.Run("sizes images from their files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chart.png")
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(file, image.NewRGBA(image.Rect(0, 0, 400, 200))); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+
+		theme := DefaultTheme()
+		theme.ImageMaxWidth = 150
+		root, err := Markdown([]byte("Before ![chart]("+path+") after"), theme)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(root.Children) != 3 {
+			t.Fatalf("expected text, image and text, got %d children", len(root.Children))
+		}
+		if image := root.Children[1]; image.Type != ImageType || image.Width.Value != 150 || image.Height.Value != 75 {
+			t.Errorf("expected image scaled to 150x75, got %+v", image)
+		}
+
+		if _, err := Markdown([]byte("![missing](missing.png)"), theme); err == nil {
+			t.Error("expected an error for a missing image")
+		}
+	})
+
+	t.Run("lays out and renders", func(t *testing.T) {
+		root := convert(t, "# Contract\n\nThe parties agree to the following terms, which are long enough to wrap over more than one line on the page.\n\n| a | b |\n|---|---|\n| 1 | 2 |\n")
+		page := Layout(Box(Sizing(A4()...), Padding(40, 40, 40, 40), Children(root)))
+
+		if root.Width.Value != 595.28-80 {
+			t.Errorf("expected content to fill the page width, got %f", root.Width.Value)
+		}
+		if row := root.Children[2].Children; row[2].Height.Value != row[3].Height.Value {
+			t.Error("expected the cells of a row to share a height")
+		}
+
+		var buf bytes.Buffer
+		if err := RenderToPDF(&buf, page); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
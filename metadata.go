@@ -0,0 +1,151 @@
+package sahar
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"codeberg.org/go-pdf/fpdf"
+)
+
+// languageTag matches the language tags of Metadata, such as en or en-US
+var languageTag = regexp.MustCompile(`^[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// Metadata describes the document to viewers and search indexes. It is written to the
+// information dictionary of the PDF and as XMP metadata. Pass it to RenderPDF like
+// the pages, or set it in PDFOptions
+type Metadata struct {
+	Title            string
+	Author           string
+	Subject          string
+	Keywords         []string
+	Creator          string    // The application which made the document
+	Producer         string    // The application which wrote the PDF, sahar when empty
+	Language         string    // The language of the text, such as en-US
+	CreationDate     time.Time // The time of the render when zero
+	ModificationDate time.Time // The creation date when zero
+}
+
+var _ RenderOption = Metadata{}
+
+func (m Metadata) configureRender(c *renderConfig) {
+	c.metadata = m
+}
+
+// empty reports whether no field of the metadata is set
+func (m Metadata) empty() bool {
+	return m.Title == "" && m.Author == "" && m.Subject == "" && len(m.Keywords) == 0 &&
+		m.Creator == "" && m.Producer == "" && m.Language == "" &&
+		m.CreationDate.IsZero() && m.ModificationDate.IsZero()
+}
+
+// applyMetadata sets the information dictionary, the language and the XMP metadata of
+// the document, identifying it as PDF/A when pdfa is set. Dates are written in UTC, so
+// both forms of metadata agree
+func applyMetadata(pdf *fpdf.Fpdf, m Metadata, pdfa PDFA) error {
+	if m.Language != "" && !languageTag.MatchString(m.Language) {
+		return fmt.Errorf("invalid document language %q", m.Language)
+	}
+
+	created := m.CreationDate
+	if created.IsZero() {
+		created = time.Now()
+	}
+	modified := m.ModificationDate
+	if modified.IsZero() {
+		modified = created
+	}
+	created, modified = created.UTC().Truncate(time.Second), modified.UTC().Truncate(time.Second)
+
+	// fpdf names itself otherwise, which the XMP metadata wouldn't match
+	if m.Producer == "" {
+		m.Producer = "sahar"
+	}
+
+	info := []struct {
+		value string
+		set   func(string, bool)
+	}{
+		{m.Title, pdf.SetTitle},
+		{m.Author, pdf.SetAuthor},
+		{m.Subject, pdf.SetSubject},
+		{strings.Join(m.Keywords, ", "), pdf.SetKeywords},
+		{m.Creator, pdf.SetCreator},
+		{m.Producer, pdf.SetProducer},
+	}
+	for _, field := range info {
+		if field.value != "" {
+			field.set(pdfTextString(field.value), false)
+		}
+	}
+
+	pdf.SetCreationDate(created)
+	pdf.SetModificationDate(modified)
+	if m.Language != "" {
+		pdf.SetLang(m.Language)
+	}
+	pdf.SetXmpMetadata(xmpMetadata(m, created, modified, pdfa))
+	return nil
+}
+
+// xmpMetadata returns the XMP packet describing the document, with the Dublin Core,
+// PDF and XMP basic properties matching the information dictionary and the PDF/A
+// identification when pdfa is set
+func xmpMetadata(m Metadata, created, modified time.Time, pdfa PDFA) []byte {
+	var b strings.Builder
+	element := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "<%s>%s</%s>\n", name, xmpEscape(value), name)
+		}
+	}
+	list := func(name, container string, values ...string) {
+		if len(values) == 0 || values[0] == "" {
+			return
+		}
+		fmt.Fprintf(&b, "<%s><rdf:%s>", name, container)
+		for _, value := range values {
+			if container == "Alt" {
+				fmt.Fprintf(&b, `<rdf:li xml:lang="x-default">%s</rdf:li>`, xmpEscape(value))
+			} else {
+				fmt.Fprintf(&b, "<rdf:li>%s</rdf:li>", xmpEscape(value))
+			}
+		}
+		fmt.Fprintf(&b, "</rdf:%s></%s>\n", container, name)
+	}
+
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	b.WriteString(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	b.WriteString(`<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:pdf="http://ns.adobe.com/pdf/1.3/" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/">` + "\n")
+
+	element("dc:format", "application/pdf")
+	list("dc:title", "Alt", m.Title)
+	list("dc:creator", "Seq", m.Author)
+	list("dc:description", "Alt", m.Subject)
+	list("dc:subject", "Bag", m.Keywords...)
+	list("dc:language", "Bag", m.Language)
+	element("pdf:Keywords", strings.Join(m.Keywords, ", "))
+	element("pdf:Producer", m.Producer)
+	element("xmp:CreatorTool", m.Creator)
+	element("xmp:CreateDate", created.Format(time.RFC3339))
+	element("xmp:ModifyDate", modified.Format(time.RFC3339))
+	element("xmp:MetadataDate", modified.Format(time.RFC3339))
+	if pdfa != 0 {
+		element("pdfaid:part", strconv.Itoa(pdfa.part()))
+		element("pdfaid:conformance", "B")
+	}
+
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n")
+	b.WriteString(`<?xpacket end="w"?>`)
+	return []byte(b.String())
+}
+
+// xmpEscape escapes a value of the XMP packet
+func xmpEscape(value string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(value))
+	return escaped.String()
+}
@@ -0,0 +1,101 @@
+package sahar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetadata(t *testing.T) {
+	metadata := Metadata{
+		Title:            "Invoice <42>",
+		Author:           "Acme",
+		Subject:          "Invoice for March",
+		Keywords:         []string{"invoice", "acme"},
+		Creator:          "Billing",
+		Producer:         "sahar",
+		Language:         "en-US",
+		CreationDate:     time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600)),
+		ModificationDate: time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("writes the information dictionary and XMP", func(t *testing.T) {
+		page := Layout(Box(Sizing(Fixed(100), Fixed(100))))
+
+		var buf bytes.Buffer
+		if err := RenderPDF(&buf, page, metadata); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		output := buf.String()
+		for _, expected := range []string{
+			"/Title (Invoice <42>)",
+			"/Author (Acme)",
+			"/Subject (Invoice for March)",
+			"/Keywords (invoice, acme)",
+			"/Creator (Billing)",
+			"/Producer (sahar)",
+			"/CreationDate (D:20240301113000)",
+			"/ModDate (D:20240302080000)",
+			"/Lang (en-US)",
+			"/Type /Metadata /Subtype /XML",
+			`<rdf:li xml:lang="x-default">Invoice &lt;42&gt;</rdf:li>`,
+			"<dc:subject><rdf:Bag><rdf:li>invoice</rdf:li><rdf:li>acme</rdf:li></rdf:Bag></dc:subject>",
+			"<dc:language><rdf:Bag><rdf:li>en-US</rdf:li></rdf:Bag></dc:language>",
+			"<xmp:CreatorTool>Billing</xmp:CreatorTool>",
+			"<xmp:CreateDate>2024-03-01T11:30:00Z</xmp:CreateDate>",
+			"<xmp:ModifyDate>2024-03-02T08:00:00Z</xmp:ModifyDate>",
+		} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("expected %s in the PDF", expected)
+			}
+		}
+	})
+
+	t.Run("is set by PDFOptions", func(t *testing.T) {
+		root := Layout(Box(Sizing(Fixed(100), Fixed(100))))
+		options := DefaultPDFOptions()
+		options.Metadata = Metadata{Title: "Résumé"}
+
+		var buf bytes.Buffer
+		if err := RenderToPDFWithOptions(root, &buf, options); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		output := buf.String()
+		if !strings.Contains(output, "/Title (\xfe\xff\x00R\x00\xe9") {
+			t.Error("expected the title encoded as UTF-16")
+		}
+		if !strings.Contains(output, `<rdf:li xml:lang="x-default">Résumé</rdf:li>`) {
+			t.Error("expected the title in the XMP metadata")
+		}
+
+		buf.Reset()
+		if err := RenderToPDFWithOptions(root, &buf, DefaultPDFOptions()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Contains(buf.String(), "/Metadata") {
+			t.Error("expected no XMP metadata without Metadata")
+		}
+	})
+
+	t.Run("leaves out empty properties", func(t *testing.T) {
+		created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		xmp := string(xmpMetadata(Metadata{}, created, created, 0))
+		if !strings.Contains(xmp, "<xmp:ModifyDate>2024-01-02T03:04:05Z</xmp:ModifyDate>") {
+			t.Errorf("unexpected XMP:\n%s", xmp)
+		}
+		if strings.Contains(xmp, "dc:title") || strings.Contains(xmp, "pdf:Producer") {
+			t.Errorf("expected no empty properties in:\n%s", xmp)
+		}
+	})
+
+	t.Run("reports an invalid language", func(t *testing.T) {
+		page := Layout(Box(Sizing(Fixed(100), Fixed(100))))
+		err := RenderPDF(&bytes.Buffer{}, page, Metadata{Language: "en US)"})
+		if err == nil || !strings.Contains(err.Error(), `invalid document language "en US)"`) {
+			t.Errorf("expected an invalid language error, got %v", err)
+		}
+	})
+}
@@ -43,53 +43,68 @@ func calculateFitWidths(node *Node) {
 		calculateFitWidths(child)
 	}
 
+	if node.Type == GridType {
+		placeGridChildren(node)
+	}
+
 	// Then calculate this node's fit width
 	if node.Width.Type == FitType {
-		var contentWidth float64
+		node.Width.Value = fitContentWidth(node)
+	}
+}
 
-		if len(node.Children) == 0 {
-			// Leaf node - content width depends on type
-			if node.Type == TextType {
-				contentWidth = measureTextWidth(node.Value, node.FontSize, node.FontType)
-			} else {
-				contentWidth = 0
-			}
-		} else if node.Direction == LeftToRight {
-			// Horizontal layout: sum children widths + gaps
-			for i, child := range node.Children {
-				contentWidth += getActualWidth(child)
-				if i < len(node.Children)-1 {
-					contentWidth += node.ChildGap
-				}
-			}
+// fitContentWidth calculates the width the node needs for its content, including
+// padding and the min and max constraints. Children must already be measured
+func fitContentWidth(node *Node) float64 {
+	var contentWidth float64
+
+	if node.Type == GridType {
+		// Grid layout: sum of column tracks + gaps
+		contentWidth = gridFitWidth(node)
+	} else if len(node.Children) == 0 {
+		// Leaf node - content width depends on type
+		if node.Type == TextType {
+			contentWidth = measureTextWidth(node.Value, node.FontSize, node.FontType)
 		} else {
-			// Vertical layout: max child width
-			for _, child := range node.Children {
-				childWidth := getActualWidth(child)
-				if childWidth > contentWidth {
-					contentWidth = childWidth
-				}
-			}
+			contentWidth = 0
 		}
-
-		// Add padding
-		contentWidth += node.Padding[1] + node.Padding[3] // right + left
-
-		// Apply min/max constraints
-		if node.Width.Min != minNotSet && contentWidth < node.Width.Min {
-			contentWidth = node.Width.Min
+	} else if node.Direction == LeftToRight {
+		// Horizontal layout: sum children widths + gaps
+		for i, child := range node.Children {
+			contentWidth += getOuterWidth(child)
+			if i < len(node.Children)-1 {
+				contentWidth += node.ChildGap
+			}
 		}
-		if node.Width.Max != maxNotSet && contentWidth > node.Width.Max {
-			contentWidth = node.Width.Max
+	} else {
+		// Vertical layout: max child width
+		for _, child := range node.Children {
+			childWidth := getOuterWidth(child)
+			if childWidth > contentWidth {
+				contentWidth = childWidth
+			}
 		}
+	}
 
-		node.Width.Value = contentWidth
+	// Add padding
+	contentWidth += node.Padding[1] + node.Padding[3] // right + left
+
+	// Apply min/max constraints
+	if node.Width.Min != minNotSet && contentWidth < node.Width.Min {
+		contentWidth = node.Width.Min
+	}
+	if node.Width.Max != maxNotSet && contentWidth > node.Width.Max {
+		contentWidth = node.Width.Max
 	}
+
+	return contentWidth
 }
 
 // Pass 2: Calculate grow widths top-down
 func calculateGrowWidths(node *Node) {
-	if len(node.Children) > 0 {
+	if node.Type == GridType {
+		distributeGridWidths(node)
+	} else if len(node.Children) > 0 {
 		availableWidth := getAvailableWidth(node)
 		distributeGrowWidths(node, availableWidth)
 	}
@@ -145,7 +160,7 @@ func calculateUsedWidthAndGrowCount(node *Node) (usedWidth float64, growCount in
 		if child.Width.Type == GrowType {
 			growCount++
 		} else {
-			usedWidth += getActualWidth(child)
+			usedWidth += getOuterWidth(child)
 		}
 	}
 
@@ -155,46 +170,42 @@ func calculateUsedWidthAndGrowCount(node *Node) (usedWidth float64, growCount in
 	return
 }
 
-// setGrowChildrenWidth sets width for all grow children
+// setGrowChildrenWidth sets width for all grow children,
+// the margins of each child are taken from the given width
 func setGrowChildrenWidth(children []*Node, width float64) {
 	for _, child := range children {
 		if child.Width.Type == GrowType {
-			child.Width.Value = width
+			child.Width.Value = math.Max(0, width-child.Margin[1]-child.Margin[3])
 		}
 	}
 }
 
 // Shrink widths when content exceeds available space
 func shrinkWidths(node *Node) {
+	node.overflow[0] = 0
 	if len(node.Children) == 0 {
 		return
 	}
 
-	var availableWidth float64
-	if node.Width.Type == FixedType || node.Width.Type == FitType {
-		availableWidth = node.Width.Value - node.Padding[1] - node.Padding[3]
-	}
+	availableWidth := getAvailableWidth(node)
 
-	if node.Direction == LeftToRight {
-		// Calculate total required width
-		var totalRequiredWidth float64
-		for i, child := range node.Children {
-			totalRequiredWidth += getActualWidth(child)
-			if i < len(node.Children)-1 {
-				totalRequiredWidth += node.ChildGap
-			}
-		}
+	if node.Type == GridType {
+		shrinkGridWidths(node)
+	} else if availableWidth > 0 {
+		if node.Direction == LeftToRight {
+			// Calculate total required width
+			totalRequiredWidth := calculateTotalChildrenWidth(node)
 
-		// If content exceeds available space, shrink proportionally
-		if totalRequiredWidth > availableWidth && availableWidth > 0 {
-			shrinkRatio := availableWidth / totalRequiredWidth
+			// If content exceeds available space, take it from the shrinkable children
+			if totalRequiredWidth > availableWidth {
+				node.overflow[0] = shrinkChildren(node.Children, totalRequiredWidth-availableWidth, true)
+			}
+		} else {
+			// Vertical layout: no child can be wider than the content area
 			for _, child := range node.Children {
-				newWidth := getActualWidth(child) * shrinkRatio
-				// Respect minimum constraints
-				if child.Width.Min != minNotSet && newWidth < child.Width.Min {
-					newWidth = child.Width.Min
+				if excess := getOuterWidth(child) - availableWidth; excess > 0 {
+					node.overflow[0] = math.Max(node.overflow[0], shrinkChildren([]*Node{child}, excess, true))
 				}
-				child.Width.Value = newWidth
 			}
 		}
 	}
@@ -229,51 +240,62 @@ func calculateFitHeights(node *Node) {
 
 	// Then calculate this node's fit height
 	if node.Height.Type == FitType {
-		var contentHeight float64
+		node.Height.Value = fitContentHeight(node)
+	}
+}
 
-		if len(node.Children) == 0 {
-			// Leaf node - content height depends on type
-			if node.Type == TextType {
-				contentHeight = measureTextHeight(node.Value, node.FontSize, node.FontType)
-			} else {
-				contentHeight = 0
-			}
-		} else if node.Direction == TopToBottom {
-			// Vertical layout: sum children heights + gaps
-			for i, child := range node.Children {
-				contentHeight += getActualHeight(child)
-				if i < len(node.Children)-1 {
-					contentHeight += node.ChildGap
-				}
-			}
+// fitContentHeight calculates the height the node needs for its content, including
+// padding and the min and max constraints. Children must already be measured
+func fitContentHeight(node *Node) float64 {
+	var contentHeight float64
+
+	if node.Type == GridType {
+		// Grid layout: sum of row tracks + gaps
+		contentHeight = gridFitHeight(node)
+	} else if len(node.Children) == 0 {
+		// Leaf node - content height depends on type
+		if node.Type == TextType {
+			contentHeight = measureTextHeight(node.Value, node.FontSize, node.FontType)
 		} else {
-			// Horizontal layout: max child height
-			for _, child := range node.Children {
-				childHeight := getActualHeight(child)
-				if childHeight > contentHeight {
-					contentHeight = childHeight
-				}
-			}
+			contentHeight = 0
 		}
-
-		// Add padding
-		contentHeight += node.Padding[0] + node.Padding[2] // top + bottom
-
-		// Apply min/max constraints
-		if node.Height.Min != minNotSet && contentHeight < node.Height.Min {
-			contentHeight = node.Height.Min
+	} else if node.Direction == TopToBottom {
+		// Vertical layout: sum children heights + gaps
+		for i, child := range node.Children {
+			contentHeight += getOuterHeight(child)
+			if i < len(node.Children)-1 {
+				contentHeight += node.ChildGap
+			}
 		}
-		if node.Height.Max != maxNotSet && contentHeight > node.Height.Max {
-			contentHeight = node.Height.Max
+	} else {
+		// Horizontal layout: max child height
+		for _, child := range node.Children {
+			childHeight := getOuterHeight(child)
+			if childHeight > contentHeight {
+				contentHeight = childHeight
+			}
 		}
+	}
 
-		node.Height.Value = contentHeight
+	// Add padding
+	contentHeight += node.Padding[0] + node.Padding[2] // top + bottom
+
+	// Apply min/max constraints
+	if node.Height.Min != minNotSet && contentHeight < node.Height.Min {
+		contentHeight = node.Height.Min
+	}
+	if node.Height.Max != maxNotSet && contentHeight > node.Height.Max {
+		contentHeight = node.Height.Max
 	}
+
+	return contentHeight
 }
 
 // Pass 5: Calculate grow heights top-down
 func calculateGrowHeights(node *Node) {
-	if len(node.Children) > 0 {
+	if node.Type == GridType {
+		distributeGridHeights(node)
+	} else if len(node.Children) > 0 {
 		availableHeight := getAvailableHeight(node)
 		distributeGrowHeights(node, availableHeight)
 	}
@@ -329,7 +351,7 @@ func calculateUsedHeightAndGrowCount(node *Node) (usedHeight float64, growCount
 		if child.Height.Type == GrowType {
 			growCount++
 		} else {
-			usedHeight += getActualHeight(child)
+			usedHeight += getOuterHeight(child)
 		}
 	}
 
@@ -339,46 +361,42 @@ func calculateUsedHeightAndGrowCount(node *Node) (usedHeight float64, growCount
 	return
 }
 
-// setGrowChildrenHeight sets height for all grow children
+// setGrowChildrenHeight sets height for all grow children,
+// the margins of each child are taken from the given height
 func setGrowChildrenHeight(children []*Node, height float64) {
 	for _, child := range children {
 		if child.Height.Type == GrowType {
-			child.Height.Value = height
+			child.Height.Value = math.Max(0, height-child.Margin[0]-child.Margin[2])
 		}
 	}
 }
 
 // Shrink heights when content exceeds available space
 func shrinkHeights(node *Node) {
+	node.overflow[1] = 0
 	if len(node.Children) == 0 {
 		return
 	}
 
-	var availableHeight float64
-	if node.Height.Type == FixedType || node.Height.Type == FitType {
-		availableHeight = node.Height.Value - node.Padding[0] - node.Padding[2]
-	}
+	availableHeight := getAvailableHeight(node)
 
-	if node.Direction == TopToBottom {
-		// Calculate total required height
-		var totalRequiredHeight float64
-		for i, child := range node.Children {
-			totalRequiredHeight += getActualHeight(child)
-			if i < len(node.Children)-1 {
-				totalRequiredHeight += node.ChildGap
-			}
-		}
+	if node.Type == GridType {
+		shrinkGridHeights(node)
+	} else if availableHeight > 0 {
+		if node.Direction == TopToBottom {
+			// Calculate total required height
+			totalRequiredHeight := calculateTotalChildrenHeight(node)
 
-		// If content exceeds available space, shrink proportionally
-		if totalRequiredHeight > availableHeight && availableHeight > 0 {
-			shrinkRatio := availableHeight / totalRequiredHeight
+			// If content exceeds available space, take it from the shrinkable children
+			if totalRequiredHeight > availableHeight {
+				node.overflow[1] = shrinkChildren(node.Children, totalRequiredHeight-availableHeight, false)
+			}
+		} else {
+			// Horizontal layout: no child can be taller than the content area
 			for _, child := range node.Children {
-				newHeight := getActualHeight(child) * shrinkRatio
-				// Respect minimum constraints
-				if child.Height.Min != minNotSet && newHeight < child.Height.Min {
-					newHeight = child.Height.Min
+				if excess := getOuterHeight(child) - availableHeight; excess > 0 {
+					node.overflow[1] = math.Max(node.overflow[1], shrinkChildren([]*Node{child}, excess, false))
 				}
-				child.Height.Value = newHeight
 			}
 		}
 	}
@@ -389,6 +407,112 @@ func shrinkHeights(node *Node) {
 	}
 }
 
+// shrinkChildren takes the overflow from the children in proportion to their shrink
+// weight and size. Fixed children and children with a zero shrink weight are never
+// shrunk, and no child goes below its minimum content size. It returns the part of
+// the overflow that could not be taken from the children.
+func shrinkChildren(children []*Node, overflow float64, horizontal bool) float64 {
+	floors := make([]float64, len(children))
+	for i, child := range children {
+		floors[i] = minContentSize(child, horizontal)
+	}
+
+	for overflow > layoutEpsilon {
+		var totalWeight float64
+		for i, child := range children {
+			size := axisSize(child, horizontal)
+			if canShrink(child, horizontal) && size.Value-floors[i] > layoutEpsilon {
+				totalWeight += child.Shrink * size.Value
+			}
+		}
+
+		if totalWeight == 0 {
+			break
+		}
+
+		var shrunk float64
+		for i, child := range children {
+			size := axisSize(child, horizontal)
+			if !canShrink(child, horizontal) || size.Value-floors[i] <= layoutEpsilon {
+				continue
+			}
+			reduction := overflow * child.Shrink * size.Value / totalWeight
+			newValue := math.Max(size.Value-reduction, floors[i])
+			shrunk += size.Value - newValue
+			size.Value = newValue
+		}
+		overflow -= shrunk
+	}
+
+	return math.Max(0, overflow)
+}
+
+// canShrink reports whether the node may give up space on the given axis
+func canShrink(node *Node, horizontal bool) bool {
+	return axisSize(node, horizontal).Type != FixedType && node.Shrink > 0
+}
+
+// minContentSize calculates the smallest size a node can take on the given axis without
+// its content overflowing: the longest word for texts and the minimum content of the
+// children for boxes and grids
+func minContentSize(node *Node, horizontal bool) float64 {
+	size := axisSize(node, horizontal)
+	if size.Type == FixedType {
+		return size.Value
+	}
+
+	var content float64
+	switch {
+	case node.Type == TextType && horizontal:
+		content = measureMinTextWidth(node.Value, node.FontSize, node.FontType)
+	case node.Type == TextType:
+		content = measureTextHeight(node.Value, node.FontSize, node.FontType)
+	case node.Type == GridType:
+		content = gridMinContentSize(node, horizontal)
+	default:
+		mainAxis := (node.Direction == LeftToRight) == horizontal
+		for i, child := range node.Children {
+			childSize := minContentSize(child, horizontal) + axisMargin(child, horizontal)
+			if mainAxis {
+				content += childSize
+				if i < len(node.Children)-1 {
+					content += node.ChildGap
+				}
+			} else {
+				content = math.Max(content, childSize)
+			}
+		}
+	}
+
+	if horizontal {
+		content += node.Padding[1] + node.Padding[3]
+	} else {
+		content += node.Padding[0] + node.Padding[2]
+	}
+
+	if size.Min != minNotSet {
+		content = math.Max(content, size.Min)
+	}
+
+	return content
+}
+
+// axisSize returns the width or the height of the node
+func axisSize(node *Node, horizontal bool) *Size {
+	if horizontal {
+		return &node.Width
+	}
+	return &node.Height
+}
+
+// axisMargin returns the sum of the margins of the node on the given axis
+func axisMargin(node *Node, horizontal bool) float64 {
+	if horizontal {
+		return node.Margin[1] + node.Margin[3]
+	}
+	return node.Margin[0] + node.Margin[2]
+}
+
 // Pass 6: Calculate positions and apply alignments
 func calculatePositions(node *Node) {
 	initRootPosition(node)
@@ -431,7 +555,9 @@ func positionChildren(node *Node) {
 
 	content := getContentArea(node)
 
-	if node.Direction == LeftToRight {
+	if node.Type == GridType {
+		positionGridChildren(node, content)
+	} else if node.Direction == LeftToRight {
 		positionChildrenHorizontally(node, content)
 	} else {
 		positionChildrenVertically(node, content)
@@ -442,7 +568,7 @@ func positionChildren(node *Node) {
 func calculateTotalChildrenWidth(node *Node) float64 {
 	var total float64
 	for i, child := range node.Children {
-		total += getActualWidth(child)
+		total += getOuterWidth(child)
 		if i < len(node.Children)-1 {
 			total += node.ChildGap
 		}
@@ -454,7 +580,7 @@ func calculateTotalChildrenWidth(node *Node) float64 {
 func calculateTotalChildrenHeight(node *Node) float64 {
 	var total float64
 	for i, child := range node.Children {
-		total += getActualHeight(child)
+		total += getOuterHeight(child)
 		if i < len(node.Children)-1 {
 			total += node.ChildGap
 		}
@@ -492,12 +618,12 @@ func positionChildrenHorizontally(node *Node, content contentArea) {
 	currentX := getAlignedX(node.Horizontal, content.x, content.width, totalWidth)
 
 	for _, child := range node.Children {
-		childHeight := getActualHeight(child)
+		childHeight := getOuterHeight(child)
 		currentY := getAlignedY(node.Vertical, content.y, content.height, childHeight)
 
-		child.Position.X = currentX
-		child.Position.Y = currentY
-		currentX += getActualWidth(child) + node.ChildGap
+		child.Position.X = currentX + child.Margin[3]
+		child.Position.Y = currentY + child.Margin[0]
+		currentX += getOuterWidth(child) + node.ChildGap
 	}
 }
 
@@ -507,12 +633,12 @@ func positionChildrenVertically(node *Node, content contentArea) {
 	currentY := getAlignedY(node.Vertical, content.y, content.height, totalHeight)
 
 	for _, child := range node.Children {
-		childWidth := getActualWidth(child)
+		childWidth := getOuterWidth(child)
 		currentX := getAlignedX(node.Horizontal, content.x, content.width, childWidth)
 
-		child.Position.X = currentX
-		child.Position.Y = currentY
-		currentY += getActualHeight(child) + node.ChildGap
+		child.Position.X = currentX + child.Margin[3]
+		child.Position.Y = currentY + child.Margin[0]
+		currentY += getOuterHeight(child) + node.ChildGap
 	}
 }
 
@@ -545,6 +671,16 @@ func measureTextWidth(text string, fontSize float64, fontType string) float64 {
 	return maxWidth
 }
 
+// measureMinTextWidth measures the width of the longest word, which
+// is the narrowest the text can get by wrapping
+func measureMinTextWidth(text string, fontSize float64, fontType string) float64 {
+	var maxWidth float64
+	for _, word := range strings.Fields(text) {
+		maxWidth = math.Max(maxWidth, measureTextWidth(word, fontSize, fontType))
+	}
+	return maxWidth
+}
+
 // measureTextHeight measures the height of text using the specified font
 func measureTextHeight(text string, fontSize float64, fontType string) float64 {
 	face := getFontFace(fontType, fontSize)
@@ -706,6 +842,9 @@ func wrapTextByCharCount(text string, maxCharsPerLine int) string {
 	return result.String()
 }
 
+// layoutEpsilon is the smallest difference in points the layout engine cares about
+const layoutEpsilon = 0.001
+
 // Helper functions
 func getActualWidth(node *Node) float64 {
 	return node.Width.Value
@@ -714,3 +853,13 @@ func getActualWidth(node *Node) float64 {
 func getActualHeight(node *Node) float64 {
 	return node.Height.Value
 }
+
+// getOuterWidth returns the width of the node including its margins
+func getOuterWidth(node *Node) float64 {
+	return node.Width.Value + node.Margin[1] + node.Margin[3]
+}
+
+// getOuterHeight returns the height of the node including its margins
+func getOuterHeight(node *Node) float64 {
+	return node.Height.Value + node.Margin[0] + node.Margin[2]
+}
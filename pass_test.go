@@ -327,6 +327,99 @@ func TestPadding(t *testing.T) {
 	})
 }
 
+func TestMargin(t *testing.T) {
+	t.Run("margin adds space around a child in a vertical layout", func(t *testing.T) {
+		first := Box(Sizing(Fixed(50), Fixed(20)))
+		second := Box(Sizing(Fixed(50), Fixed(20)), Margin(15, 0, 5, 10))
+		container := Box(
+			Direction(TopToBottom),
+			ChildGap(10),
+			Children(first, second))
+
+		Layout(container)
+
+		if second.Position.X != 10 {
+			t.Errorf("expected second X position to be 10, got %f", second.Position.X)
+		}
+		// first height + gap + top margin
+		if second.Position.Y != 45 {
+			t.Errorf("expected second Y position to be 45, got %f", second.Position.Y)
+		}
+		if container.Width.Value != 60 {
+			t.Errorf("expected container width to include the margin, got %f", container.Width.Value)
+		}
+		if container.Height.Value != 70 {
+			t.Errorf("expected container height to include the margins, got %f", container.Height.Value)
+		}
+	})
+
+	t.Run("margin adds space between children in a horizontal layout", func(t *testing.T) {
+		first := Box(Sizing(Fixed(50), Fixed(20)), Margin(0, 5, 0, 0))
+		second := Box(Sizing(Fixed(50), Fixed(20)), Margin(0, 0, 0, 10))
+		container := Box(Children(first, second))
+
+		Layout(container)
+
+		if second.Position.X != 65 {
+			t.Errorf("expected second X position to be 65, got %f", second.Position.X)
+		}
+		if container.Width.Value != 115 {
+			t.Errorf("expected container width to be 115, got %f", container.Width.Value)
+		}
+	})
+
+	t.Run("grow children leave room for their margins", func(t *testing.T) {
+		fixed := Box(Sizing(Fixed(50), Fixed(20)))
+		grow := Box(Sizing(Grow(), Grow()), Margin(5, 10, 5, 10))
+		container := Box(
+			Sizing(Fixed(200), Fixed(100)),
+			Children(fixed, grow))
+
+		Layout(container)
+
+		if grow.Width.Value != 130 {
+			t.Errorf("expected grow width to be 130, got %f", grow.Width.Value)
+		}
+		if grow.Height.Value != 90 {
+			t.Errorf("expected grow height to be 90, got %f", grow.Height.Value)
+		}
+		if grow.Position.X != 60 || grow.Position.Y != 5 {
+			t.Errorf("expected grow position to be (60,5), got (%f,%f)", grow.Position.X, grow.Position.Y)
+		}
+	})
+
+	t.Run("alignment uses the size including margins", func(t *testing.T) {
+		child := Box(Sizing(Fixed(50), Fixed(20)), Margin(0, 20, 10, 0))
+		container := Box(
+			Sizing(Fixed(200), Fixed(100)),
+			Alignment(Right, Bottom),
+			Children(child))
+
+		Layout(container)
+
+		if child.Position.X != 130 || child.Position.Y != 70 {
+			t.Errorf("expected child position to be (130,70), got (%f,%f)", child.Position.X, child.Position.Y)
+		}
+	})
+
+	t.Run("margin inside a grid cell", func(t *testing.T) {
+		child := Box(Sizing(Grow(), Grow()), Margin(5, 5, 5, 5))
+		grid := Grid(
+			Columns(FixedTrack(100)),
+			Rows(FixedTrack(50)),
+			Children(child))
+
+		Layout(grid)
+
+		if child.Width.Value != 90 || child.Height.Value != 40 {
+			t.Errorf("expected child size to be 90x40, got %fx%f", child.Width.Value, child.Height.Value)
+		}
+		if child.Position.X != 5 || child.Position.Y != 5 {
+			t.Errorf("expected child position to be (5,5), got (%f,%f)", child.Position.X, child.Position.Y)
+		}
+	})
+}
+
 func TestComplexLayout(t *testing.T) {
 	t.Run("nested layout with mixed sizing", func(t *testing.T) {
 		// Create a complex nested layout
@@ -543,8 +636,7 @@ func TestLayoutIdempotency(t *testing.T) {
 }
 
 func TestShrinkingBehavior(t *testing.T) {
-	t.Run("content exceeds container width", func(t *testing.T) {
-		// Create children that would exceed container width
+	t.Run("fixed children are never shrunk and the overflow is reported", func(t *testing.T) {
 		child1 := Box(Sizing(Fixed(100)))
 		child2 := Box(Sizing(Fixed(100)))
 		child3 := Box(Sizing(Fixed(100)))
@@ -556,18 +648,119 @@ func TestShrinkingBehavior(t *testing.T) {
 
 		Layout(container)
 
-		// Children should be shrunk proportionally
-		// Available width = 200, required = 300, so shrink ratio = 200/300 = 2/3
-		expectedChildWidth := 100 * (2.0 / 3.0)
+		for i, child := range []*Node{child1, child2, child3} {
+			if child.Width.Value != 100 {
+				t.Errorf("expected child%d width to stay 100, got %f", i+1, child.Width.Value)
+			}
+		}
+
+		overflowWidth, overflowHeight := container.Overflow()
+		if math.Abs(overflowWidth-100) > 0.1 {
+			t.Errorf("expected horizontal overflow to be 100, got %f", overflowWidth)
+		}
+		if overflowHeight != 0 {
+			t.Errorf("expected no vertical overflow, got %f", overflowHeight)
+		}
+	})
+
+	t.Run("shrinkable children share the overflow proportionally", func(t *testing.T) {
+		child1 := Text("aa bb cc dd ee ff gg hh", FontSize(10))
+		child2 := Text("aa bb cc dd ee ff gg hh", FontSize(10))
+		fixed := Box(Sizing(Fixed(50)))
+
+		container := Box(
+			Sizing(Fixed(150)),
+			Children(child1, fixed, child2))
+
+		Layout(container)
+
+		if fixed.Width.Value != 50 {
+			t.Errorf("expected fixed child to stay 50, got %f", fixed.Width.Value)
+		}
+		if math.Abs(child1.Width.Value-50) > 0.1 || math.Abs(child2.Width.Value-50) > 0.1 {
+			t.Errorf("expected texts to shrink to 50, got %f and %f", child1.Width.Value, child2.Width.Value)
+		}
+		if w, _ := container.Overflow(); w != 0 {
+			t.Errorf("expected no overflow, got %f", w)
+		}
+		if !strings.Contains(child1.Value, "\n") {
+			t.Error("expected shrunk text to be wrapped")
+		}
+	})
+
+	t.Run("shrink weights", func(t *testing.T) {
+		rigid := Text("aa bb cc dd ee ff gg hh", FontSize(10), Shrink(0))
+		heavy := Text("aa bb cc dd ee ff gg hh", FontSize(10), Shrink(3))
+		light := Text("aa bb cc dd ee ff gg hh", FontSize(10))
+
+		Layout(Box(Children(rigid, heavy, light)))
+		natural := rigid.Width.Value
 
-		if math.Abs(child1.Width.Value-expectedChildWidth) > 0.1 {
-			t.Errorf("expected child1 width to be shrunk to %f, got %f", expectedChildWidth, child1.Width.Value)
+		container := Box(
+			Sizing(Fixed(natural*3-40)),
+			Children(rigid, heavy, light))
+
+		Layout(container)
+
+		if rigid.Width.Value != natural {
+			t.Errorf("expected zero weight child to keep %f, got %f", natural, rigid.Width.Value)
+		}
+		if math.Abs(natural-heavy.Width.Value-30) > 0.1 {
+			t.Errorf("expected heavy child to give up 30, got %f", natural-heavy.Width.Value)
+		}
+		if math.Abs(natural-light.Width.Value-10) > 0.1 {
+			t.Errorf("expected light child to give up 10, got %f", natural-light.Width.Value)
 		}
-		if math.Abs(child2.Width.Value-expectedChildWidth) > 0.1 {
-			t.Errorf("expected child2 width to be shrunk to %f, got %f", expectedChildWidth, child2.Width.Value)
+	})
+
+	t.Run("text is never narrower than its longest word", func(t *testing.T) {
+		text := Text("extraordinarily long words", FontSize(12))
+		container := Box(
+			Sizing(Fixed(20)),
+			Children(text))
+
+		Layout(container)
+
+		longest := measureTextWidth("extraordinarily", 12, "")
+		if math.Abs(text.Width.Value-longest) > 0.1 {
+			t.Errorf("expected text width to be the longest word %f, got %f", longest, text.Width.Value)
+		}
+		if w, _ := container.Overflow(); math.Abs(w-(longest-20)) > 0.1 {
+			t.Errorf("expected overflow to be %f, got %f", longest-20, w)
+		}
+	})
+
+	t.Run("children of a vertical layout are shrunk to its width", func(t *testing.T) {
+		text := Text("This is a very long text that should wrap", FontSize(12))
+		container := Box(
+			Sizing(Fixed(100)),
+			Direction(TopToBottom),
+			Children(text))
+
+		Layout(container)
+
+		if text.Width.Value > 100 {
+			t.Errorf("expected text to be at most 100 wide, got %f", text.Width.Value)
+		}
+		if !strings.Contains(text.Value, "\n") {
+			t.Error("expected text to be wrapped")
+		}
+	})
+
+	t.Run("shrinking passes through fit boxes", func(t *testing.T) {
+		text := Text("aa bb cc dd ee ff gg hh ii jj", FontSize(10))
+		wrapper := Box(Padding(0, 5, 0, 5), Children(text))
+		container := Box(
+			Sizing(Fixed(80)),
+			Children(wrapper))
+
+		Layout(container)
+
+		if wrapper.Width.Value != 80 {
+			t.Errorf("expected wrapper width to be 80, got %f", wrapper.Width.Value)
 		}
-		if math.Abs(child3.Width.Value-expectedChildWidth) > 0.1 {
-			t.Errorf("expected child3 width to be shrunk to %f, got %f", expectedChildWidth, child3.Width.Value)
+		if text.Width.Value != 70 {
+			t.Errorf("expected text width to be 70, got %f", text.Width.Value)
 		}
 	})
 }
@@ -1,278 +1,640 @@
 package sahar
 
 import (
+	"bytes"
+	"crypto/md5"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"codeberg.org/go-pdf/fpdf"
 )
 
-// RenderToPDF renders the node tree to a PDF and writes it to the provided writer
+// RenderToPDF renders the node tree to a PDF and writes it to the provided writer.
+// Every node passed is rendered as a page sized to the node, use RenderPDF to pass
+// render options such as DebugOverlay
 func RenderToPDF(writer io.Writer, nodes ...*Node) error {
-	if len(nodes) == 0 {
+	return RenderPDF(writer, Pages(nodes...))
+}
+
+// RenderPDF renders the pages passed among the render options to a PDF, like
+// RenderToPDF. Render options such as DebugOverlay are passed alongside the nodes,
+// use Pages to pass a slice of nodes. Tables of contents are filled with the bookmarks
+// of all the pages, see TableOfContents. Passing a Metadata sets the title, author and
+// other information of the document
+func RenderPDF(writer io.Writer, opts ...RenderOption) error {
+	var config renderConfig
+	for _, opt := range opts {
+		opt.configureRender(&config)
+	}
+
+	if len(config.pages) == 0 {
 		return fmt.Errorf("there is no node to render")
 	}
 
+	pages, err := fillContents(config.pages)
+	if err != nil {
+		return err
+	}
+	config.pages = pages
+	if err := checkLinks(config.pages); err != nil {
+		return err
+	}
+	if err := checkAttachments(config.pages, config.attachments); err != nil {
+		return err
+	}
+	if err := checkFields(config.pages); err != nil {
+		return err
+	}
+	if err := checkPDFA(config.pages, config, defaultFont); err != nil {
+		return err
+	}
+	if err := checkEncryption(config); err != nil {
+		return err
+	}
+	if err := checkCompression(config); err != nil {
+		return err
+	}
+	if err := checkTags(config.pages, config); err != nil {
+		return err
+	}
+
 	// Create a new PDF document
 	pdf := fpdf.New("P", "pt", "A4", "")
+	if err := setupDocument(pdf, config); err != nil {
+		return err
+	}
+	canvas := &pdfCanvas{pdf: pdf, embedFonts: config.embedFonts || config.pdfa != 0, fullFonts: config.fullFonts, structure: newPDFStructure(config), maxDPI: config.maxDPI, quality: config.quality}
+	r := &canvasRenderer{canvas: canvas, font: defaultFont, tagged: config.tagged}
 
-	for _, node := range nodes {
-		pdf.AddPageFormat("P", fpdf.SizeType{
-			Wd: node.Width.Value,
-			Ht: node.Height.Value,
-		})
-
-		// Set default font if not already set
-		pdf.SetFont("Arial", "", 12)
+	for _, node := range config.pages {
+		if err := canvas.BeginPage(node.Width.Value, node.Height.Value); err != nil {
+			return err
+		}
 
 		// Render the node tree
-		if err := renderNode(pdf, node); err != nil {
+		if err := r.node(node); err != nil {
 			return fmt.Errorf("failed to render node: %w", err)
 		}
+
+		if config.debug {
+			if err := r.debugOverlay(node); err != nil {
+				return err
+			}
+		}
+		if err := canvas.EndPage(); err != nil {
+			return err
+		}
 	}
+	canvas.writeOutline()
 
 	// Write PDF to the writer
-	return pdf.Output(writer)
+	return outputDocument(canvas, writer, config)
+}
+
+// renderConfig holds the pages and options collected from the RenderPDF arguments
+type renderConfig struct {
+	pages         []*Node
+	debug         bool
+	dpi           float64 // The resolution of the images, see DPI
+	quality       int     // The quality of JPEG images, see JPEGQuality
+	embedFonts    bool    // Embed the loaded fonts in SVG images, see EmbedFonts
+	metadata      Metadata
+	deterministic bool // Write the same bytes for the same pages, see Deterministic
+	pdfa          PDFA // The PDF/A conformance level, see PDFA
+	attachments   []Attachment
+	tagged        bool // Write the structure tree, see Tagged
+	encryption    *Encryption
+	maxDPI        float64 // Downsample the images of PDFs above, see MaxImageDPI
+	uncompressed  bool
+	fullFonts     bool     // Embed every glyph of the fonts, see FullFonts
+	size          *PDFSize // Filled once the PDF is written, see SizeReport
+}
+
+// RenderOption is an option of RenderPDF and of the other renderers. Nodes are render
+// options passing the pages to render
+type RenderOption interface {
+	configureRender(*renderConfig)
+}
+
+type renderOptFunc func(*renderConfig)
+
+func (f renderOptFunc) configureRender(c *renderConfig) {
+	f(c)
+}
+
+var _ RenderOption = (*Node)(nil)
+
+func (n *Node) configureRender(c *renderConfig) {
+	c.pages = append(c.pages, n)
+}
+
+// Pages passes the nodes as pages, to render a slice of nodes along with other options
+func Pages(nodes ...*Node) RenderOption {
+	return renderOptFunc(func(c *renderConfig) {
+		c.pages = append(c.pages, nodes...)
+	})
+}
+
+// DebugOverlay draws the layout of every node on top of the rendered pages: the outline
+// of the node, its padding, the gaps between its children, the baselines of text and
+// a label with the calculated size. It is meant to inspect a document without changing it,
+// and works with every renderer
+func DebugOverlay() RenderOption {
+	return renderOptFunc(func(c *renderConfig) {
+		c.debug = true
+	})
 }
 
-// renderNode recursively renders a node and its children
-func renderNode(pdf *fpdf.Fpdf, node *Node) error {
-	if node == nil {
+// deterministicDate is the date of the documents rendered with Deterministic, when the
+// Metadata doesn't set one
+var deterministicDate = time.Unix(0, 0).UTC()
+
+// Deterministic makes RenderPDF write the same bytes every time it renders the same
+// pages, for golden-file tests and content-addressed storage. The dates of the document
+// are the Unix epoch unless the Metadata sets them, the fonts and images are written in
+// the order of their names and the file identifier is the MD5 of the content
+func Deterministic() RenderOption {
+	return renderOptFunc(func(c *renderConfig) {
+		c.deterministic = true
+	})
+}
+
+// setupDocument applies the metadata, the compression and the deterministic mode to a
+// new document. PDF/A documents always have XMP metadata
+func setupDocument(pdf *fpdf.Fpdf, config renderConfig) error {
+	metadata := config.metadata
+	pdf.SetCompression(!config.uncompressed)
+	if config.deterministic {
+		// Resources are kept in maps, which fpdf only writes in order when sorting
+		pdf.SetCatalogSort(true)
+		pdf.SetCreationDate(deterministicDate)
+		pdf.SetModificationDate(deterministicDate)
+	}
+
+	if metadata.empty() && config.pdfa == 0 {
 		return nil
 	}
+	// Also when only PDF/A writes metadata, which would be dated now otherwise
+	if config.deterministic && metadata.CreationDate.IsZero() {
+		metadata.CreationDate = deterministicDate
+	}
+	return applyMetadata(pdf, metadata, config.pdfa)
+}
 
-	switch node.Type {
-	case BoxType:
-		if err := renderBox(pdf, node); err != nil {
-			return err
-		}
-	case TextType:
-		if err := renderText(pdf, node); err != nil {
+// outputDocument writes the document, adding what fpdf can't write, see finishDocument,
+// and the file identifier
+func outputDocument(canvas *pdfCanvas, writer io.Writer, config renderConfig) error {
+	finish := config.pdfa != 0 || config.encryption != nil || len(config.attachments) > 0 || len(canvas.attached) > 0 || len(canvas.fields) > 0 || canvas.structure != nil
+	if !config.deterministic && !finish && config.size == nil {
+		return canvas.pdf.Output(writer)
+	}
+
+	var buf bytes.Buffer
+	if err := canvas.pdf.Output(&buf); err != nil {
+		return err
+	}
+
+	date := time.Now()
+	if config.deterministic {
+		date = deterministicDate
+	}
+	data, err := finishDocument(buf.Bytes(), config, canvas, date)
+	if err != nil {
+		return err
+	}
+	data = withDocumentID(data)
+	if config.size != nil {
+		size, err := measurePDF(data)
+		if err != nil {
 			return err
 		}
-	case ImageType:
-		if err := renderImage(pdf, node); err != nil {
-			return err
+		size.ImagesWritten, size.ImagesReused = len(canvas.images), canvas.reused
+		for _, downsampled := range canvas.images {
+			if downsampled {
+				size.ImagesDownsampled++
+			}
 		}
+		*config.size = size
 	}
+	_, err = writer.Write(data)
+	return err
+}
 
-	// Render children
-	for _, child := range node.Children {
-		if err := renderNode(pdf, child); err != nil {
-			return err
-		}
+// withDocumentID adds a file identifier to the trailer of a PDF, made of the MD5 of
+// everything before the trailer, which fpdf only writes for encrypted documents. The
+// trailer follows the cross-reference table, so no offset moves
+func withDocumentID(data []byte) []byte {
+	trailer := []byte("\ntrailer\n<<\n")
+	i := bytes.LastIndex(data, trailer)
+	if i < 0 || bytes.Contains(data[i:], []byte("/ID ")) {
+		return data
 	}
 
-	return nil
+	sum := md5.Sum(data[:i])
+	id := fmt.Sprintf("/ID [<%X> <%X>]\n", sum, sum)
+	at := i + len(trailer)
+	return append(data[:at:at], append([]byte(id), data[at:]...)...)
 }
 
-// renderBox renders a box node (draws a rectangle)
-func renderBox(pdf *fpdf.Fpdf, node *Node) error {
-	// Skip rendering if no visual properties are set
-	hasBorder := node.Border > 0
-	hasBackground := node.BackgroundColor != ""
+// pdfCanvas draws on the pages of an fpdf document
+type pdfCanvas struct {
+	pdf        *fpdf.Fpdf
+	anchors    map[string]int // The internal links of the anchors, see AddLink
+	bookmarks  []pdfBookmark
+	embedFonts bool            // Draw with the loaded fonts instead of the standard ones
+	fullFonts  bool            // Embed every glyph of the loaded fonts, see FullFonts
+	fonts      map[string]bool // The loaded fonts added to the document
+	attached   []pdfAttachment // The files attached to nodes, see pdfFile.attach
+	structure  *pdfStructure   // The structure tree of tagged documents, nil otherwise
+	fields     []pdfField      // The form fields, see pdfFile.form
+
+	// images drawn, see prepareImage
+	images  map[string]bool // The registered images by name, whether they are downsampled
+	maxDPI  float64
+	quality int
+	reused  int
+}
 
-	if !hasBorder && !hasBackground {
+// newPDFStructure returns the structure tree of a tagged document, nil otherwise
+func newPDFStructure(config renderConfig) *pdfStructure {
+	if !config.tagged {
 		return nil
 	}
+	return &pdfStructure{root: &pdfTag{role: RoleDocument}}
+}
 
-	x := node.Position.X
-	y := node.Position.Y
-	width := node.Width.Value
-	height := node.Height.Value
-
-	// Determine draw style based on what's set
-	drawStyle := ""
+// pdfBookmark is an entry of the outline, written by writeOutline once all the pages
+// are added
+type pdfBookmark struct {
+	title      string
+	level      int
+	page       int
+	y          float64
+	pageHeight float64
+}
 
-	if hasBackground {
-		r, g, b, err := hexToRGB(node.BackgroundColor, "")
-		if err != nil {
-			return fmt.Errorf("invalid background color: %w", err)
-		}
-		pdf.SetFillColor(r, g, b)
-		drawStyle = "F"
-	}
+var (
+	_ LinkCanvas   = (*pdfCanvas)(nil)
+	_ AttachCanvas = (*pdfCanvas)(nil)
+	_ FormCanvas   = (*pdfCanvas)(nil)
+	_ TagCanvas    = (*pdfCanvas)(nil)
+)
 
-	if hasBorder {
-		pdf.SetLineWidth(node.Border)
-		r, g, b, err := hexToRGB(node.BorderColor, "#000000")
-		if err != nil {
-			return fmt.Errorf("invalid border color: %w", err)
-		}
-		pdf.SetDrawColor(r, g, b)
-		if drawStyle == "F" {
-			drawStyle = "FD"
-		} else {
-			drawStyle = "D"
-		}
+func (c *pdfCanvas) BeginPage(width, height float64) error {
+	c.pdf.AddPageFormat("P", fpdf.SizeType{Wd: width, Ht: height})
+	if c.structure != nil {
+		c.structure.pages = append(c.structure.pages, nil)
 	}
+	return c.pdf.Error()
+}
 
-	pdf.Rect(x, y, width, height, drawStyle)
+func (c *pdfCanvas) EndPage() error {
+	c.endContent()
+	return c.pdf.Error()
+}
 
+func (c *pdfCanvas) Rect(rect Rect, style Style) error {
+	if drawStyle := c.setStyle(style); drawStyle != "" {
+		c.content()
+		defer c.opacity(style)()
+		c.pdf.Rect(rect.X, rect.Y, rect.Width, rect.Height, drawStyle)
+	}
 	return nil
 }
 
-// renderText renders a text node
-func renderText(pdf *fpdf.Fpdf, node *Node) error {
-	if node.Value == "" {
+func (c *pdfCanvas) Path(points []Point, closed bool, style Style) error {
+	drawStyle := c.setStyle(style)
+	if drawStyle == "" || len(points) == 0 {
 		return nil
 	}
+	c.content()
+	defer c.opacity(style)()
 
-	if node.Border > 0 {
-		err := renderBox(pdf, node)
-		if err != nil {
-			return err
-		}
+	c.pdf.MoveTo(points[0].X, points[0].Y)
+	for _, point := range points[1:] {
+		c.pdf.LineTo(point.X, point.Y)
 	}
+	if closed {
+		c.pdf.ClosePath()
+	}
+	c.pdf.DrawPath(drawStyle)
+	return nil
+}
 
-	if err := setupTextFont(pdf, node); err != nil {
-		return err
+// opacity sets the opacity of a translucent style and returns the function resetting it
+func (c *pdfCanvas) opacity(style Style) func() {
+	if !style.translucent() {
+		return func() {}
 	}
+	c.pdf.SetAlpha(style.Opacity, "Normal")
+	return func() { c.pdf.SetAlpha(1, "Normal") }
+}
 
-	if err := setupTextColor(pdf, node); err != nil {
-		return err
+// setStyle sets the colors and the line width of the style and returns the matching
+// draw style of fpdf, empty when there is nothing to draw
+func (c *pdfCanvas) setStyle(style Style) string {
+	drawStyle := ""
+	if style.Fill != nil {
+		c.pdf.SetFillColor(int(style.Fill.R), int(style.Fill.G), int(style.Fill.B))
+		drawStyle = "F"
+	}
+	if style.Stroke != nil && style.StrokeWidth > 0 {
+		c.pdf.SetLineWidth(style.StrokeWidth)
+		c.pdf.SetDrawColor(int(style.Stroke.R), int(style.Stroke.G), int(style.Stroke.B))
+		drawStyle += "D"
 	}
+	return drawStyle
+}
 
-	return renderTextLines(pdf, node)
+// MeasureText measures the text with the font drawing it: a font loaded with LoadFonts
+// when EmbedFonts or PDF/A embeds it, the closest standard font otherwise
+func (c *pdfCanvas) MeasureText(text string, font Font) float64 {
+	c.pdf.SetFont(c.family(font), "", font.Size)
+	return c.pdf.GetStringWidth(text)
 }
 
-// setupTextFont sets up the font for text rendering
-func setupTextFont(pdf *fpdf.Fpdf, node *Node) error {
-	if node.FontType != "" && node.FontSize > 0 {
-		fontName := mapFontName(node.FontType)
-		pdf.SetFont(fontName, "", node.FontSize)
-	}
+func (c *pdfCanvas) Text(run TextRun) error {
+	c.pdf.SetFont(c.family(run.Font), "", run.Font.Size)
+	c.pdf.SetTextColor(int(run.Color.R), int(run.Color.G), int(run.Color.B))
+	c.content()
+	c.pdf.Text(run.X, run.Y, run.Text)
 	return nil
 }
 
-// setupTextColor sets up the text color
-func setupTextColor(pdf *fpdf.Fpdf, node *Node) error {
-	r, g, b, err := hexToRGB(node.FontColor, "#000000")
+func (c *pdfCanvas) Image(src string, rect Rect) error {
+	name, imageType, err := c.prepareImage(src, rect)
 	if err != nil {
-		return fmt.Errorf("invalid font color: %w", err)
+		return err
 	}
-	pdf.SetTextColor(r, g, b)
+
+	c.content()
+	c.pdf.ImageOptions(name, rect.X, rect.Y, rect.Width, rect.Height, false, fpdf.ImageOptions{
+		ReadDpi:   false,
+		ImageType: imageType,
+	}, 0, "")
+	return nil
+}
+
+// PushClip saves the graphics state and clips to the rectangle. The saved states are
+// nested with the elements of tagged documents, so the open marked content is closed
+func (c *pdfCanvas) PushClip(rect Rect) error {
+	c.endContent()
+	c.pdf.ClipRect(rect.X, rect.Y, rect.Width, rect.Height, false)
 	return nil
 }
 
-// renderTextLines handles the rendering of multiple text lines
-func renderTextLines(pdf *fpdf.Fpdf, node *Node) error {
-	lines := strings.Split(node.Value, "\n")
-	_, fontPtSize := pdf.GetFontSize()
+func (c *pdfCanvas) PopClip() error {
+	c.endContent()
+	c.pdf.ClipEnd()
+	return c.pdf.Error()
+}
+
+func (c *pdfCanvas) Link(rect Rect, url string) error {
+	if anchor, ok := strings.CutPrefix(url, "#"); ok {
+		c.pdf.Link(rect.X, rect.Y, rect.Width, rect.Height, c.anchorLink(anchor))
+		return nil
+	}
+	c.pdf.LinkString(rect.X, rect.Y, rect.Width, rect.Height, url)
+	return nil
+}
 
-	// Approximate ascender height (where baseline should be from top of text)
-	// Most fonts have ascender at ~75-80% of em-square
-	ascender := fontPtSize * 0.75
+// Attach records the file, fpdf can't write the attachments PDF/A-3 requires so they
+// are added once the document is written
+func (c *pdfCanvas) Attach(rect Rect, file Attachment) error {
+	_, height := c.pdf.GetPageSize()
+	rect.Y = height - rect.Y - rect.Height
+	c.attached = append(c.attached, pdfAttachment{page: c.pdf.PageNo(), rect: rect, file: file})
+	return nil
+}
 
-	// Line spacing for multiple lines
-	lineSpacing := fontPtSize * 1.2
+// Field records the form field with the appearance of its value, fpdf can't write
+// forms so they are added once the document is written
+func (c *pdfCanvas) Field(rect Rect, field FormField, align Horizontal) error {
+	pf := pdfField{page: c.pdf.PageNo(), field: field}
+	_, height := c.pdf.GetPageSize()
+	pf.rect = rect
+	pf.rect.Y = height - rect.Y - rect.Height
+
+	size := field.FontSize
+	if size == 0 {
+		size = defaultFieldFontSize
+	}
+	switch field.Kind {
+	case FieldCheckbox, FieldRadio:
+		// A check mark or a dot of ZapfDingbats, centered
+		mark, width := "4", 0.846
+		if field.Kind == FieldRadio {
+			mark, width = "l", 0.791
+		}
+		size = min(rect.Width, rect.Height) * 0.8
+		color, _ := parseColor(field.FontColor, "#000000")
+		pf.on = fmt.Sprintf("q BT /ZaDb %.2f Tf %.3f %.3f %.3f rg %.2f %.2f Td (%s) Tj ET Q",
+			size, float64(color.R)/255, float64(color.G)/255, float64(color.B)/255,
+			(rect.Width-size*width)/2, (rect.Height-size*0.7)/2, mark)
+	case FieldText, FieldDropdown:
+		switch align {
+		case Center:
+			pf.quadding = 1
+		case Right:
+			pf.quadding = 2
+		}
 
-	startY := calculateVerticalPosition(node, lines, lineSpacing, ascender, fontPtSize)
+		// The value in Helvetica, with the padding viewers use
+		const padding = 2
+		c.pdf.SetFont("Helvetica", "", size)
+		lines := []string{field.Value}
+		y := (rect.Height-size)/2 + size*0.22
+		if field.Multiline {
+			lines = nil
+			for _, line := range strings.Split(field.Value, "\n") {
+				lines = append(lines, c.pdf.SplitText(line, rect.Width-2*padding)...)
+			}
+			y = rect.Height - padding - size*0.78
+		}
 
-	for i, line := range lines {
-		// Skip rendering empty lines but maintain line position
-		if line == "" {
-			continue
+		var b strings.Builder
+		fmt.Fprintf(&b, "/Tx BMC\nq\n1 1 %.2f %.2f re W n\nBT\n%s\n", rect.Width-2, rect.Height-2, fieldFont(field))
+		for i, line := range lines {
+			x := float64(padding)
+			switch pf.quadding {
+			case 1:
+				x = (rect.Width - c.pdf.GetStringWidth(line)) / 2
+			case 2:
+				x = rect.Width - padding - c.pdf.GetStringWidth(line)
+			}
+			fmt.Fprintf(&b, "1 0 0 1 %.2f %.2f Tm %s Tj\n", x, y-float64(i)*size*1.15, pdfWinAnsi(line))
 		}
-		renderSingleLine(pdf, node, line, startY, i, lineSpacing)
+		b.WriteString("ET\nQ\nEMC")
+		pf.on = b.String()
 	}
 
-	return nil
+	c.fields = append(c.fields, pf)
+	return c.pdf.Error()
 }
 
-// calculateVerticalPosition calculates the starting Y position based on vertical alignment
-func calculateVerticalPosition(node *Node, lines []string, lineSpacing, ascender, fontSize float64) float64 {
-	y := node.Position.Y
-	height := node.Height.Value
+// BeginTag starts an element of the structure tree, or an artifact, of a tagged document
+func (c *pdfCanvas) BeginTag(role Role, alt string) error {
+	s := c.structure
+	if s == nil {
+		return nil
+	}
 
-	// Calculate total text height matching measureTextHeight logic
-	var totalTextHeight float64
-	if len(lines) == 1 {
-		totalTextHeight = fontSize // Single line uses just fontSize
-	} else {
-		totalTextHeight = fontSize + float64(len(lines)-1)*lineSpacing
+	c.endContent()
+	if role == RoleArtifact {
+		c.pdf.RawWriteStr("/Artifact BMC\n")
+		s.stack = append(s.stack, nil)
+		return nil
 	}
 
-	// Baseline offset from top of text block is the ascender
-	switch node.Vertical {
-	case Top:
-		return y + ascender
-	case Middle:
-		return y + (height-totalTextHeight)/2 + ascender
-	case Bottom:
-		return y + height - totalTextHeight + ascender
-	default:
-		return y + ascender
+	parent := s.root
+	if len(s.stack) > 0 {
+		parent = s.stack[len(s.stack)-1]
+	}
+	if parent == nil {
+		return fmt.Errorf("element %s inside an artifact", role)
 	}
+	tag := &pdfTag{role: role, alt: alt, parent: parent}
+	parent.kids = append(parent.kids, pdfTagKid{tag: tag})
+	s.stack = append(s.stack, tag)
+	return nil
 }
 
-// renderSingleLine renders a single line of text
-func renderSingleLine(pdf *fpdf.Fpdf, node *Node, line string, startY float64, lineIndex int, lineSpacing float64) {
-	lineY := startY + float64(lineIndex)*lineSpacing
-	lineX := calculateHorizontalPosition(pdf, node, line)
-	pdf.Text(lineX, lineY, line)
+func (c *pdfCanvas) EndTag() error {
+	s := c.structure
+	if s == nil {
+		return nil
+	}
+	if len(s.stack) == 0 {
+		return fmt.Errorf("EndTag without BeginTag")
+	}
+
+	c.endContent()
+	if s.stack[len(s.stack)-1] == nil {
+		c.pdf.RawWriteStr("EMC\n")
+	}
+	s.stack = s.stack[:len(s.stack)-1]
+	return nil
 }
 
-// calculateHorizontalPosition calculates the X position based on horizontal alignment
-func calculateHorizontalPosition(pdf *fpdf.Fpdf, node *Node, line string) float64 {
-	x := node.Position.X
-	width := node.Width.Value
-	textWidth := pdf.GetStringWidth(line)
+// content opens a marked-content sequence for the content about to be drawn, belonging
+// to the innermost element. Content outside of the elements is an artifact
+func (c *pdfCanvas) content() {
+	s := c.structure
+	if s == nil || s.open {
+		return
+	}
 
-	var lineX float64
-	switch node.Horizontal {
-	case Left:
-		lineX = x + node.Padding[3]
-	case Center:
-		lineX = x + (width-textWidth)/2
-	case Right:
-		lineX = x + width - textWidth - node.Padding[1]
+	switch {
+	case len(s.stack) == 0:
+		c.pdf.RawWriteStr("/Artifact BMC\n")
+	case s.stack[len(s.stack)-1] == nil:
+		// Already inside an artifact
+		return
 	default:
-		lineX = x + node.Padding[3]
+		tag := s.stack[len(s.stack)-1]
+		page := len(s.pages)
+		mcid := len(s.pages[page-1])
+		c.pdf.RawWriteStr(fmt.Sprintf("/%s <</MCID %d>> BDC\n", tag.role, mcid))
+		tag.kids = append(tag.kids, pdfTagKid{page: page, mcid: mcid})
+		s.pages[page-1] = append(s.pages[page-1], tag)
+	}
+	s.open = true
+}
+
+// endContent closes the open marked-content sequence
+func (c *pdfCanvas) endContent() {
+	if s := c.structure; s != nil && s.open {
+		c.pdf.RawWriteStr("EMC\n")
+		s.open = false
 	}
+}
 
-	// Ensure text doesn't go outside the node bounds (only clamp if width is positive)
-	if width > 0 {
-		if lineX < x {
-			lineX = x
-		}
-		if textWidth < width && lineX+textWidth > x+width {
-			lineX = x + width - textWidth
-		}
+func (c *pdfCanvas) Anchor(name string, at Point) error {
+	c.pdf.SetLink(c.anchorLink(name), at.Y, -1)
+	return nil
+}
+
+// anchorLink returns the internal link of an anchor, links can be added before
+// the position of their anchor is set
+func (c *pdfCanvas) anchorLink(name string) int {
+	if c.anchors == nil {
+		c.anchors = map[string]int{}
+	}
+	link, ok := c.anchors[name]
+	if !ok {
+		link = c.pdf.AddLink()
+		c.anchors[name] = link
 	}
+	return link
+}
 
-	return lineX
+func (c *pdfCanvas) Bookmark(title string, level int, at Point) error {
+	_, height := c.pdf.GetPageSize()
+	c.bookmarks = append(c.bookmarks, pdfBookmark{title, level, c.pdf.PageNo(), at.Y, height})
+	return nil
 }
 
-// renderImage renders an image node (placeholder implementation)
-func renderImage(pdf *fpdf.Fpdf, node *Node) error {
-	if node.Border > 0 {
-		err := renderBox(pdf, node)
-		if err != nil {
-			return err
-		}
+// writeOutline adds the bookmarks to the document. fpdf places every bookmark as if its
+// page had the height of the last page, so the positions are moved by the difference
+func (c *pdfCanvas) writeOutline() {
+	page := c.pdf.PageNo()
+	_, lastHeight := c.pdf.GetPageSize()
+
+	for _, b := range c.bookmarks {
+		c.pdf.SetPage(b.page)
+		c.pdf.Bookmark(pdfTextString(b.title), b.level, b.y+lastHeight-b.pageHeight)
 	}
+	c.pdf.SetPage(page)
+}
 
-	x := node.Position.X
-	y := node.Position.Y
-	width := node.Width.Value
-	height := node.Height.Value
+// pdfTextString encodes text outside of the content of the pages, such as bookmark
+// titles, as UTF-16 with a byte order mark when it is not ASCII
+func pdfTextString(text string) string {
+	ascii := true
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return text
+	}
 
-	imageType, err := detectImageType(node.Value)
-	if err != nil {
-		return fmt.Errorf("failed to detect image type: %w", err)
+	encoded := []byte{0xfe, 0xff}
+	for _, unit := range utf16.Encode([]rune(text)) {
+		encoded = append(encoded, byte(unit>>8), byte(unit))
 	}
+	return string(encoded)
+}
 
-	pdf.ImageOptions(node.Value, x, y, width, height, false, fpdf.ImageOptions{
-		ReadDpi:   false,
-		ImageType: imageType,
-	}, 0, "")
+// family returns the fpdf family of a font. With embedFonts a font loaded with
+// LoadFonts is added to the document the first time it is used, other fonts are
+// mapped to the standard ones
+func (c *pdfCanvas) family(font Font) string {
+	data := loadedFontData(font.Type)
+	if !c.embedFonts || data == nil {
+		return mapFontName(font.Type)
+	}
 
-	return nil
+	if !c.fonts[font.Type] {
+		if c.fonts == nil {
+			c.fonts = map[string]bool{}
+		}
+		c.pdf.AddUTF8FontFromBytes(font.Type, "", data)
+		if c.fullFonts {
+			c.useAllGlyphs(font.Type)
+		}
+		c.fonts[font.Type] = true
+	}
+	return font.Type
 }
 
 // mapFontName maps common font names to FPDF-compatible font names
@@ -294,6 +656,20 @@ func RenderToPDFWithOptions(root *Node, writer io.Writer, options PDFOptions) er
 	if root == nil {
 		return fmt.Errorf("root node cannot be nil")
 	}
+	pages, err := fillContents([]*Node{root})
+	if err != nil {
+		return err
+	}
+	root = pages[0]
+	if err := checkLinks([]*Node{root}); err != nil {
+		return err
+	}
+	if err := checkAttachments([]*Node{root}, options.Attachments); err != nil {
+		return err
+	}
+	if err := checkFields([]*Node{root}); err != nil {
+		return err
+	}
 
 	// Create PDF with specified options
 	orientation := "P"
@@ -306,7 +682,25 @@ func RenderToPDFWithOptions(root *Node, writer io.Writer, options PDFOptions) er
 		pageSize = options.PageSize
 	}
 
+	config := renderConfig{
+		pages:         []*Node{root},
+		debug:         options.Debug,
+		metadata:      options.Metadata,
+		deterministic: options.Deterministic,
+		pdfa:          options.PDFA,
+		attachments:   options.Attachments,
+		tagged:        options.Tagged,
+		encryption:    options.Encryption,
+		uncompressed:  options.Uncompressed,
+		fullFonts:     options.FullFonts,
+		maxDPI:        options.MaxImageDPI,
+		quality:       options.JPEGQuality,
+		size:          options.SizeReport,
+	}
 	pdf := fpdf.New(orientation, "pt", pageSize, "")
+	if err := setupDocument(pdf, config); err != nil {
+		return err
+	}
 
 	// Set margins if specified
 	if options.MarginTop > 0 || options.MarginRight > 0 || options.MarginBottom > 0 || options.MarginLeft > 0 {
@@ -317,23 +711,49 @@ func RenderToPDFWithOptions(root *Node, writer io.Writer, options PDFOptions) er
 	pdf.AddPage()
 
 	// Set default font
-	defaultFont := "Arial"
-	defaultSize := 12.0
+	font := defaultFont
 	if options.DefaultFont != "" {
-		defaultFont = options.DefaultFont
+		font.Type = options.DefaultFont
 	}
 	if options.DefaultFontSize > 0 {
-		defaultSize = options.DefaultFontSize
+		font.Size = options.DefaultFontSize
+	}
+	if err := checkPDFA(config.pages, config, font); err != nil {
+		return err
+	}
+	if err := checkEncryption(config); err != nil {
+		return err
+	}
+	if err := checkCompression(config); err != nil {
+		return err
+	}
+	if err := checkTags(config.pages, config); err != nil {
+		return err
 	}
-	pdf.SetFont(defaultFont, "", defaultSize)
 
 	// Render the node tree
-	if err := renderNode(pdf, root); err != nil {
+	canvas := &pdfCanvas{pdf: pdf, embedFonts: config.pdfa != 0, fullFonts: config.fullFonts, structure: newPDFStructure(config), maxDPI: config.maxDPI, quality: config.quality}
+	if canvas.structure != nil {
+		// The page is added above rather than by BeginPage
+		canvas.structure.pages = append(canvas.structure.pages, nil)
+	}
+	r := &canvasRenderer{canvas: canvas, font: font, tagged: config.tagged}
+	if err := r.node(root); err != nil {
 		return fmt.Errorf("failed to render node: %w", err)
 	}
 
+	if options.Debug {
+		if err := r.debugOverlay(root); err != nil {
+			return err
+		}
+	}
+	if err := canvas.EndPage(); err != nil {
+		return err
+	}
+	canvas.writeOutline()
+
 	// Write PDF to the writer
-	return pdf.Output(writer)
+	return outputDocument(canvas, writer, config)
 }
 
 // PDFOptions contains options for PDF rendering
@@ -346,6 +766,18 @@ type PDFOptions struct {
 	MarginLeft      float64
 	DefaultFont     string
 	DefaultFontSize float64
+	Debug           bool         // Draw the layout overlay, see DebugOverlay
+	Metadata        Metadata     // The title, author and other document information
+	Deterministic   bool         // Write the same bytes for the same pages, see Deterministic
+	PDFA            PDFA         // The PDF/A conformance level, none when zero
+	Attachments     []Attachment // Files attached to the document
+	Tagged          bool         // Write the structure tree for accessibility, see Tagged
+	Encryption      *Encryption  // The passwords and permissions, not encrypted when nil
+	Uncompressed    bool         // Write the content of the pages uncompressed, see Uncompressed
+	FullFonts       bool         // Embed every glyph of the fonts, see FullFonts
+	MaxImageDPI     float64      // Downsample denser images, see MaxImageDPI
+	JPEGQuality     int          // The quality of recompressed JPEG images, see JPEGQuality
+	SizeReport      *PDFSize     // Filled with the size of the document, see SizeReport
 }
 
 // DefaultPDFOptions returns default PDF rendering options
@@ -376,9 +808,12 @@ func detectImageType(filepath string) (string, error) {
 		return "", fmt.Errorf("failed to read image file: %w", err)
 	}
 
-	// Detect MIME type
-	contentType := http.DetectContentType(buf)
+	return imageTypeOf(buf)
+}
 
+// imageTypeOf returns the fpdf type of an image from its first bytes
+func imageTypeOf(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
 	switch {
 	case strings.HasPrefix(contentType, "image/jpeg"):
 		return "JPG", nil
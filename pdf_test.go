@@ -2,10 +2,13 @@ package sahar
 
 import (
 	"bytes"
+	"image"
+	"image/png"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestHexToRGB(t *testing.T) {
@@ -225,6 +228,22 @@ func TestRenderToPDF(t *testing.T) {
 		}
 	})
 
+	t.Run("renders a slice of pages with options", func(t *testing.T) {
+		pages := []*Node{Layout(Box(Sizing(Fixed(100), Fixed(100)))), Layout(Box(Sizing(Fixed(100), Fixed(100))))}
+
+		var buf bytes.Buffer
+		if err := RenderPDF(&buf, Pages(pages...), Metadata{Title: "Pages"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		f, err := parsePDF(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(f.pages()); n != 2 || !strings.Contains(buf.String(), "/Title (Pages)") {
+			t.Errorf("expected 2 pages with the title, got %d", n)
+		}
+	})
+
 	t.Run("renders nested boxes", func(t *testing.T) {
 		node := Box(
 			Sizing(Fixed(300), Fixed(200)),
@@ -409,6 +428,116 @@ func TestDefaultPDFOptions(t *testing.T) {
 	}
 }
 
+func TestDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.png", "b.png"} {
+		file, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(file, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+	}
+
+	fonts := []textOpt{FontType("Times"), FontSize(10)}
+	if _, err := os.Stat("./examples/basic/Arial.ttf"); err == nil {
+		if err := LoadFonts("DeterministicArial", "./examples/basic/Arial.ttf"); err != nil {
+			t.Fatal(err)
+		}
+		fonts = []textOpt{FontType("DeterministicArial"), FontSize(10)}
+	}
+
+	pages := func() []*Node {
+		return []*Node{
+			Layout(Box(
+				Sizing(Fixed(200), Fixed(200)),
+				Direction(TopToBottom),
+				Children(
+					Text("Überblick", append(fonts, Bookmark("Overview", 0))...),
+					Text("Courier", FontType("Courier"), FontSize(10), Link("#end")),
+					Text("Helvetica", FontType("Helvetica"), FontSize(10)),
+					Image(filepath.Join(dir, "a.png"), Sizing(Fixed(10), Fixed(10))),
+					Image(filepath.Join(dir, "b.png"), Sizing(Fixed(10), Fixed(10))),
+				),
+			)),
+			Layout(Box(Sizing(Fixed(200), Fixed(200)), Anchor("end"), Bookmark("End", 1))),
+		}
+	}
+	render := func(opts ...RenderOption) []byte {
+		var buf bytes.Buffer
+		for _, page := range pages() {
+			opts = append(opts, page)
+		}
+		if err := RenderPDF(&buf, opts...); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	t.Run("writes the same bytes", func(t *testing.T) {
+		first := render(Deterministic())
+		for i := 0; i < 5; i++ {
+			if !bytes.Equal(first, render(Deterministic())) {
+				t.Fatal("expected identical output for identical pages")
+			}
+		}
+
+		output := string(first)
+		for _, expected := range []string{"/CreationDate (D:19700101000000)", "/ModDate (D:19700101000000)", "/ID [<"} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("expected %s in the PDF", expected)
+			}
+		}
+		if !strings.HasSuffix(output, "%%EOF\n") {
+			t.Error("expected the PDF to end after the trailer")
+		}
+	})
+
+	t.Run("keeps the dates of the metadata", func(t *testing.T) {
+		created := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		first := render(Deterministic(), Metadata{Title: "Report", CreationDate: created})
+		if !bytes.Equal(first, render(Deterministic(), Metadata{Title: "Report", CreationDate: created})) {
+			t.Error("expected identical output with metadata")
+		}
+		if !strings.Contains(string(first), "/CreationDate (D:20240301000000)") {
+			t.Error("expected the creation date of the metadata")
+		}
+
+		untitled := render(Deterministic(), Metadata{CreationDate: created})
+		id := func(data []byte) string {
+			i := bytes.Index(data, []byte("/ID ["))
+			return string(data[i : i+bytes.IndexByte(data[i:], ']')])
+		}
+		if id(first) == id(untitled) {
+			t.Error("expected the identifier to depend on the content")
+		}
+	})
+
+	t.Run("is set by PDFOptions", func(t *testing.T) {
+		options := DefaultPDFOptions()
+		options.Deterministic = true
+		root := func() *Node {
+			return Layout(Box(
+				Sizing(Fixed(200), Fixed(200)),
+				Children(Text("Page", fonts...), Image(filepath.Join(dir, "a.png"), Sizing(Fixed(10), Fixed(10)))),
+			))
+		}
+
+		var first, second bytes.Buffer
+		if err := RenderToPDFWithOptions(root(), &first, options); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := RenderToPDFWithOptions(root(), &second, options); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Error("expected identical output for identical pages")
+		}
+	})
+}
+
 func TestDetectImageType(t *testing.T) {
 	t.Run("returns error for non-existent file", func(t *testing.T) {
 		_, err := detectImageType("/nonexistent/file.png")
@@ -0,0 +1,220 @@
+package sahar
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"regexp"
+)
+
+// PDFA is a PDF/A conformance level for archival. Pass it to RenderPDF like the pages,
+// or set it in PDFOptions. The loaded fonts are embedded, the document gets an sRGB
+// output intent, XMP metadata identifying it and a file identifier, and links are marked
+// printable. Text in fonts which are not loaded with LoadFonts, CMYK images and the debug
+// overlay can't conform, they are reported as errors before anything is drawn
+type PDFA int
+
+const (
+	PDFA2B PDFA = iota + 2 // PDF/A-2b, the visual appearance is preserved
+	PDFA3B                 // PDF/A-3b, which also allows files of any type to be attached
+)
+
+var _ RenderOption = PDFA2B
+
+func (p PDFA) configureRender(c *renderConfig) {
+	c.pdfa = p
+}
+
+// part returns the part of ISO 19005 defining the level
+func (p PDFA) part() int {
+	return int(p)
+}
+
+func (p PDFA) String() string {
+	return fmt.Sprintf("PDF/A-%db", p.part())
+}
+
+// srgbICC is the compressed ICC profile of the output intent of PDF/A documents
+var srgbICC = deflate(srgbProfile())
+
+// pdfaDate matches the dates of the information dictionary written by fpdf, which are
+// in UTC without saying so
+var pdfaDate = regexp.MustCompile(`(/(?:CreationDate|ModDate) \(D:\d{14})\)`)
+
+// checkPDFA reports everything the pages use that a PDF/A document can't hold. Text
+// without a font is drawn with font
+func checkPDFA(pages []*Node, config renderConfig, font Font) error {
+	if config.pdfa == 0 {
+		return nil
+	}
+
+	var errs []error
+	if config.debug {
+		errs = append(errs, errors.New("the debug overlay is drawn with standard fonts, which are not embedded"))
+	}
+	attachment := func(path, name string) {
+		if config.pdfa == PDFA2B {
+			errs = append(errs, fmt.Errorf("%sattachment %q can't be checked to be PDF/A, PDF/A-3b allows attaching any file", path, name))
+		}
+	}
+	for _, a := range config.attachments {
+		attachment("", a.Name)
+	}
+
+	var walk func(node *Node, path string)
+	walk = func(node *Node, path string) {
+		if node == nil {
+			return
+		}
+
+		switch node.Type {
+		case TextType:
+			name := node.FontType
+			if name == "" || node.FontSize <= 0 {
+				name = font.Type
+			}
+			if node.Value != "" && loadedFontData(name) == nil {
+				errs = append(errs, fmt.Errorf("%s: font %q is not loaded with LoadFonts, so it can't be embedded", path, name))
+			}
+		case ImageType:
+			if isCMYKImage(node.Value) {
+				errs = append(errs, fmt.Errorf("%s: image %q is CMYK, which doesn't match the sRGB output intent", path, node.Value))
+			}
+		}
+
+		if node.Attachment != nil {
+			attachment(path+": ", node.Attachment.Name)
+		}
+		if node.Field != nil {
+			errs = append(errs, fmt.Errorf("%s: form field %q is drawn with standard fonts, which are not embedded", path, node.Field.Name))
+		}
+
+		for i, child := range node.Children {
+			walk(child, fmt.Sprintf("%s/%s[%d]", path, child.Type, i))
+		}
+	}
+	for i, page := range pages {
+		walk(page, fmt.Sprintf("page %d: %s", i+1, page.Type))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("the document can't conform to %s: %w", config.pdfa, errors.Join(errs...))
+	}
+	return nil
+}
+
+// isCMYKImage reports whether an image file is in CMYK, files which can't be read are
+// reported by the renderer
+func isCMYKImage(src string) bool {
+	file, err := os.Open(src)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	return err == nil && config.ColorModel == color.CMYKModel
+}
+
+// conformToPDFA adds what fpdf can't write for PDF/A: the catalog gets an output intent
+// with the sRGB profile, links get the print flag and the dates say they are in UTC
+func (f *pdfFile) conformToPDFA() {
+	profile := f.add(fmt.Sprintf("<</N 3\n/Filter /FlateDecode\n/Length %d>>", len(srgbICC)), srgbICC)
+	intent := f.add(fmt.Sprintf("<</Type /OutputIntent /S /GTS_PDFA1 /OutputConditionIdentifier (sRGB IEC61966-2.1) /Info (sRGB IEC61966-2.1) /DestOutputProfile %d 0 R>>", profile), nil)
+	f.catalog(fmt.Sprintf("/OutputIntents [%d 0 R]", intent))
+
+	f.objects[f.info] = pdfaDate.ReplaceAll(f.objects[f.info], []byte("${1}Z)"))
+	for _, page := range f.pages() {
+		f.objects[page] = bytes.ReplaceAll(f.objects[page], []byte("/Subtype /Link "), []byte("/Subtype /Link /F 4 "))
+	}
+}
+
+// srgbProfile builds an ICC version 2 display profile of the sRGB color space, with the
+// primaries adapted to the D50 white of the profile connection space and the tone curve
+// sampled
+func srgbProfile() []byte {
+	fixed := func(b []byte, values ...float64) []byte {
+		for _, v := range values {
+			b = binary.BigEndian.AppendUint32(b, uint32(int32(math.Round(v*65536))))
+		}
+		return b
+	}
+	xyz := func(x, y, z float64) []byte {
+		return fixed([]byte("XYZ \x00\x00\x00\x00"), x, y, z)
+	}
+
+	const name = "sRGB IEC61966-2.1"
+	desc := binary.BigEndian.AppendUint32([]byte("desc\x00\x00\x00\x00"), uint32(len(name)+1))
+	desc = append(desc, name+"\x00"...)
+	desc = append(desc, make([]byte, 4+4+2+1+67)...) // Empty Unicode and ScriptCode names
+
+	curve := binary.BigEndian.AppendUint32([]byte("curv\x00\x00\x00\x00"), 1024)
+	for i := 0; i < 1024; i++ {
+		v := float64(i) / 1023
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		curve = binary.BigEndian.AppendUint16(curve, uint16(math.Round(v*65535)))
+	}
+
+	tags := []struct {
+		signature string
+		data      []byte
+	}{
+		{"desc", desc},
+		{"cprt", []byte("text\x00\x00\x00\x00No copyright, use freely\x00")},
+		{"wtpt", xyz(0.9505, 1, 1.0891)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[8:], 0x02100000) // Version 2.1
+	copy(header[12:], "mntrRGB XYZ ")
+	for i, v := range []uint16{2024, 1, 1} {
+		binary.BigEndian.PutUint16(header[24+2*i:], v)
+	}
+	copy(header[36:], "acsp")
+	// The D50 illuminant as the specification encodes it
+	for i, v := range []uint32{0xf6d6, 0x10000, 0xd32d} {
+		binary.BigEndian.PutUint32(header[68+4*i:], v)
+	}
+
+	table := binary.BigEndian.AppendUint32(nil, uint32(len(tags)))
+	var body []byte
+	offset := len(header) + 4 + 12*len(tags)
+	for _, tag := range tags {
+		table = append(table, tag.signature...)
+		table = binary.BigEndian.AppendUint32(table, uint32(offset+len(body)))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(tag.data)))
+		body = append(body, tag.data...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+	}
+
+	profile := append(append(header, table...), body...)
+	binary.BigEndian.PutUint32(profile, uint32(len(profile)))
+	return profile
+}
+
+// deflate compresses data for a stream with the FlateDecode filter
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,192 @@
+package sahar
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// checkXref fails the test when the cross-reference table of a PDF doesn't point to
+// the objects
+func checkXref(t *testing.T, data []byte) {
+	t.Helper()
+
+	start := bytes.LastIndex(data, []byte("startxref\n"))
+	xref, err := strconv.Atoi(string(bytes.Fields(data[start+len("startxref\n"):])[0]))
+	if err != nil || !bytes.HasPrefix(data[xref:], []byte("xref\n0 ")) {
+		t.Fatalf("startxref doesn't point to the cross-reference table")
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(data[xref:start], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if !bytes.HasPrefix(data[offset:], fmt.Appendf(nil, "%d 0 obj\n", i+1)) {
+			t.Errorf("entry %d doesn't point to its object", i+1)
+		}
+	}
+}
+
+func TestPDFA(t *testing.T) {
+	if _, err := os.Stat("./examples/basic/Arial.ttf"); err != nil {
+		t.Skip("Arial.ttf not found in examples/basic")
+	}
+	if err := LoadFonts("PDFAArial", "./examples/basic/Arial.ttf"); err != nil {
+		t.Fatal(err)
+	}
+
+	logo := filepath.Join(t.TempDir(), "logo.png")
+	file, err := os.Create(logo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, image.NewNRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	page := func() *Node {
+		return Layout(Box(
+			Sizing(Fixed(200), Fixed(200)),
+			Direction(TopToBottom),
+			Children(
+				Text("Überblick", FontType("PDFAArial"), FontSize(10), Bookmark("Overview", 0), Link("https://example.com")),
+				Image(logo, Sizing(Fixed(10), Fixed(10))),
+			),
+		))
+	}
+
+	t.Run("writes a conforming document", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := RenderPDF(&buf, page(), PDFA2B, Metadata{Title: "Archive"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		checkXref(t, buf.Bytes())
+		output := buf.String()
+		for _, expected := range []string{
+			"/Type /Catalog\n/OutputIntents [",
+			"/S /GTS_PDFA1 /OutputConditionIdentifier (sRGB IEC61966-2.1)",
+			"/Subtype /Link /F 4 ",
+			"/FontFile2",
+			"<pdfaid:part>2</pdfaid:part>",
+			"<pdfaid:conformance>B</pdfaid:conformance>",
+			"/Producer (sahar)",
+			"/ID [<",
+		} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("expected %s in the PDF", expected)
+			}
+		}
+		if !regexp.MustCompile(`/CreationDate \(D:\d{14}Z\)`).MatchString(output) {
+			t.Error("expected the creation date in UTC")
+		}
+		if strings.Contains(output, "/BaseFont /Helvetica") {
+			t.Error("expected no standard font")
+		}
+	})
+
+	t.Run("is set by PDFOptions", func(t *testing.T) {
+		options := DefaultPDFOptions()
+		options.PDFA = PDFA3B
+		options.DefaultFont = "PDFAArial"
+		options.Deterministic = true
+
+		render := func() []byte {
+			var buf bytes.Buffer
+			root := Layout(Box(Sizing(Fixed(100), Fixed(100)), Children(Text("Total"))))
+			if err := RenderToPDFWithOptions(root, &buf, options); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return buf.Bytes()
+		}
+
+		first := render()
+		checkXref(t, first)
+		if !bytes.Contains(first, []byte("<pdfaid:part>3</pdfaid:part>")) {
+			t.Error("expected the PDF/A-3 identification")
+		}
+		if !bytes.Equal(first, render()) {
+			t.Error("expected identical output in deterministic mode")
+		}
+	})
+
+	t.Run("is deterministic without metadata", func(t *testing.T) {
+		render := func() []byte {
+			var buf bytes.Buffer
+			if err := RenderPDF(&buf, page(), PDFA2B, Deterministic()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return buf.Bytes()
+		}
+
+		first := render()
+		if !bytes.Contains(first, []byte("<xmp:CreateDate>1970-01-01T00:00:00Z</xmp:CreateDate>")) {
+			t.Error("expected the XMP metadata dated at the Unix epoch")
+		}
+		if !bytes.Equal(first, render()) {
+			t.Error("expected identical output in deterministic mode")
+		}
+	})
+
+	t.Run("reports what can't conform", func(t *testing.T) {
+		root := Layout(Box(
+			Sizing(Fixed(100), Fixed(100)),
+			Children(Box(Children(Text("Total", FontType("Times"), FontSize(10)))), Text("Sum")),
+		))
+
+		err := RenderPDF(&bytes.Buffer{}, root, PDFA2B, DebugOverlay())
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		for _, expected := range []string{
+			"the document can't conform to PDF/A-2b",
+			"the debug overlay is drawn with standard fonts",
+			`page 1: Box/Box[0]/Text[0]: font "Times" is not loaded`,
+			`page 1: Box/Text[1]: font "Arial" is not loaded`,
+		} {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("expected %q in the error, got:\n%v", expected, err)
+			}
+		}
+	})
+}
+
+func TestSRGBProfile(t *testing.T) {
+	r, err := zlib.NewReader(bytes.NewReader(srgbICC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size := binary.BigEndian.Uint32(profile); int(size) != len(profile) {
+		t.Errorf("expected the size %d in the header, got %d", len(profile), size)
+	}
+	if string(profile[12:24]) != "mntrRGB XYZ " || string(profile[36:40]) != "acsp" {
+		t.Errorf("unexpected header % x", profile[:40])
+	}
+
+	count := binary.BigEndian.Uint32(profile[128:])
+	for i := 0; i < int(count); i++ {
+		entry := profile[132+12*i:]
+		offset, size := binary.BigEndian.Uint32(entry[4:]), binary.BigEndian.Uint32(entry[8:])
+		if offset%4 != 0 || int(offset+size) > len(profile) {
+			t.Errorf("tag %s is out of the profile", entry[:4])
+		}
+	}
+	if count != 9 {
+		t.Errorf("expected 9 tags, got %d", count)
+	}
+}
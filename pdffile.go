@@ -0,0 +1,231 @@
+package sahar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pdfFile is a document written by fpdf split into its objects, so the objects fpdf
+// can't write can be added before writing it again. The objects keep their order and
+// the cross-reference table is written again
+type pdfFile struct {
+	header     []byte
+	objects    [][]byte // By number, from "n 0 obj" to the end of line after endobj
+	order      []int    // The object numbers in the order of the file
+	root       int      // The number of the catalog
+	info       int      // The number of the information dictionary
+	encryption int      // The number of the encryption dictionary, zero when not encrypted
+}
+
+// pdfReference matches the references to objects of a dictionary
+var pdfReference = regexp.MustCompile(`(\d+) 0 R`)
+
+// parsePDF splits a document written by fpdf, which writes a single cross-reference
+// table of fixed-width entries
+func parsePDF(data []byte) (*pdfFile, error) {
+	start := bytes.LastIndex(data, []byte("startxref\n"))
+	if start < 0 {
+		return nil, errors.New("invalid PDF: missing startxref")
+	}
+	xref, err := strconv.Atoi(string(bytes.Fields(data[start+len("startxref\n"):])[0]))
+	if err != nil || xref >= start {
+		return nil, errors.New("invalid PDF: bad cross-reference offset")
+	}
+
+	lines := strings.Split(string(data[xref:start]), "\n")
+	var count int
+	if len(lines) < 2 || lines[0] != "xref" {
+		return nil, errors.New("invalid PDF: missing cross-reference table")
+	}
+	if _, err := fmt.Sscanf(lines[1], "0 %d", &count); err != nil || count < 2 || len(lines) < count+2 {
+		return nil, errors.New("invalid PDF: bad cross-reference table")
+	}
+
+	f := &pdfFile{objects: make([][]byte, count)}
+	offsets := make([]int, count)
+	for j := 1; j < count; j++ {
+		if offsets[j], err = strconv.Atoi(lines[2+j][:10]); err != nil || offsets[j] >= xref {
+			return nil, fmt.Errorf("invalid PDF: bad offset of object %d", j)
+		}
+		f.order = append(f.order, j)
+	}
+	sort.Slice(f.order, func(a, b int) bool { return offsets[f.order[a]] < offsets[f.order[b]] })
+
+	for i, j := range f.order {
+		end := xref
+		if i+1 < len(f.order) {
+			end = offsets[f.order[i+1]]
+		}
+		f.objects[j] = data[offsets[j]:end:end]
+	}
+	f.header = data[:offsets[f.order[0]]]
+
+	trailer := strings.Join(lines[2+count:], "\n")
+	for _, ref := range []struct {
+		key string
+		n   *int
+	}{{"/Root ", &f.root}, {"/Info ", &f.info}} {
+		i := strings.Index(trailer, ref.key)
+		if i < 0 {
+			return nil, fmt.Errorf("invalid PDF: missing %s in the trailer", strings.TrimSpace(ref.key))
+		}
+		if _, err := fmt.Sscanf(trailer[i+len(ref.key):], "%d", ref.n); err != nil || *ref.n >= count {
+			return nil, fmt.Errorf("invalid PDF: bad %s in the trailer", strings.TrimSpace(ref.key))
+		}
+	}
+	return f, nil
+}
+
+// add appends an object made of a dictionary, followed by a stream when stream is not
+// nil, and returns its number. The dictionary holds the length of the stream
+func (f *pdfFile) add(dictionary string, stream []byte) int {
+	n := len(f.objects)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d 0 obj\n%s\n", n, dictionary)
+	if stream != nil {
+		b.WriteString("stream\n")
+		b.Write(stream)
+		b.WriteString("\nendstream\n")
+	}
+	b.WriteString("endobj\n")
+
+	f.objects = append(f.objects, b.Bytes())
+	f.order = append(f.order, n)
+	return n
+}
+
+// edit replaces the first occurrence of old in an object, reporting whether it was found
+func (f *pdfFile) edit(n int, old, new string) bool {
+	i := bytes.Index(f.objects[n], []byte(old))
+	if i < 0 {
+		return false
+	}
+
+	edited := append([]byte(nil), f.objects[n][:i]...)
+	edited = append(edited, new...)
+	f.objects[n] = append(edited, f.objects[n][i+len(old):]...)
+	return true
+}
+
+// catalog adds an entry to the catalog
+func (f *pdfFile) catalog(entry string) {
+	f.edit(f.root, "/Type /Catalog\n", "/Type /Catalog\n"+entry+"\n")
+}
+
+// annotate adds an annotation to a page
+func (f *pdfFile) annotate(page, annotation int) {
+	if !f.edit(page, "/Annots [", fmt.Sprintf("/Annots [%d 0 R ", annotation)) {
+		f.edit(page, "<</Type /Page\n", fmt.Sprintf("<</Type /Page\n/Annots [%d 0 R]\n", annotation))
+	}
+}
+
+// pages returns the numbers of the page objects in order, fpdf lists them all in the
+// page tree, which is object 1
+func (f *pdfFile) pages() []int {
+	object := f.objects[1]
+	start := bytes.Index(object, []byte("/Kids ["))
+	if start < 0 {
+		return nil
+	}
+	end := bytes.IndexByte(object[start:], ']')
+
+	var pages []int
+	for _, match := range pdfReference.FindAllSubmatch(object[start:start+end], -1) {
+		n, _ := strconv.Atoi(string(match[1]))
+		pages = append(pages, n)
+	}
+	return pages
+}
+
+// bytes writes the document with its cross-reference table and trailer
+func (f *pdfFile) bytes() []byte {
+	var out bytes.Buffer
+	out.Write(f.header)
+
+	offsets := make([]int, len(f.objects))
+	for _, n := range f.order {
+		offsets[n] = out.Len()
+		out.Write(f.objects[n])
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets))
+	for _, offset := range offsets[1:] {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<<\n/Size %d\n/Root %d 0 R\n/Info %d 0 R\n", len(offsets), f.root, f.info)
+	if f.encryption != 0 {
+		fmt.Fprintf(&out, "/Encrypt %d 0 R\n", f.encryption)
+	}
+	fmt.Fprintf(&out, ">>\nstartxref\n%d\n%%%%EOF\n", xref)
+	return out.Bytes()
+}
+
+// finishDocument adds the attachments, the form, the structure tree, the PDF/A
+// requirements and the encryption fpdf can't write to a document written by fpdf on
+// the canvas. Files without a modification date get date
+func finishDocument(data []byte, config renderConfig, canvas *pdfCanvas, date time.Time) ([]byte, error) {
+	if config.pdfa == 0 && config.encryption == nil && len(config.attachments) == 0 && len(canvas.attached) == 0 && len(canvas.fields) == 0 && canvas.structure == nil {
+		return data, nil
+	}
+
+	f, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.attach(config.attachments, canvas.attached, date); err != nil {
+		return nil, err
+	}
+	if len(canvas.fields) > 0 {
+		if err := f.form(canvas.fields); err != nil {
+			return nil, err
+		}
+	}
+	if canvas.structure != nil {
+		if err := f.tag(canvas.structure); err != nil {
+			return nil, err
+		}
+	}
+	if config.pdfa != 0 {
+		f.conformToPDFA()
+	}
+	if config.encryption != nil {
+		if err := f.encrypt(*config.encryption, encryptionRandom(config, f.bytes())); err != nil {
+			return nil, err
+		}
+	}
+	return f.bytes(), nil
+}
+
+// pdfLiteral returns text as a PDF string, see pdfTextString
+func pdfLiteral(text string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", `\r`)
+	return "(" + escaper.Replace(pdfTextString(text)) + ")"
+}
+
+// pdfName returns a PDF name, with the characters other than letters, digits and a
+// few punctuation marks escaped, such as /text#2Fxml
+func pdfName(name string) string {
+	var b strings.Builder
+	b.WriteByte('/')
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c > ' ' && c < 0x7f && !strings.ContainsRune("#/%()<>[]{}", rune(c)) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "#%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// pdfDate returns a date as written in PDF, in UTC
+func pdfDate(date time.Time) string {
+	return "D:" + date.UTC().Format("20060102150405") + "Z"
+}
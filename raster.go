@@ -0,0 +1,326 @@
+package sahar
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/golang/freetype/truetype"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// Fallback fonts of the raster renderer, used for text whose font is not loaded
+var (
+	goRegularFont = sync.OnceValue(func() *truetype.Font {
+		f, _ := truetype.Parse(goregular.TTF)
+		return f
+	})
+	goMonoFont = sync.OnceValue(func() *truetype.Font {
+		f, _ := truetype.Parse(gomono.TTF)
+		return f
+	})
+)
+
+// DPI sets the resolution of RenderToPNG and RenderToJPEG in pixels per inch.
+// The default is 72, which draws a point as a pixel
+func DPI(dpi float64) RenderOption {
+	return renderOptFunc(func(c *renderConfig) {
+		c.dpi = dpi
+	})
+}
+
+// JPEGQuality sets the quality of RenderToJPEG, from 1 to 100. The default is 75. With
+// RenderPDF the JPEG images are encoded again with it, unless they would get larger
+func JPEGQuality(quality int) RenderOption {
+	return renderOptFunc(func(c *renderConfig) {
+		c.quality = quality
+	})
+}
+
+// RenderToPNG renders the node tree to a PNG image and writes it to the provided writer.
+// The image holds a single page, so a single node is expected, and DPI sets its resolution
+func RenderToPNG(writer io.Writer, opts ...RenderOption) error {
+	img, _, err := renderRaster(opts)
+	if err != nil {
+		return err
+	}
+	return png.Encode(writer, img)
+}
+
+// RenderToJPEG renders the node tree to a JPEG image and writes it to the provided writer.
+// The image holds a single page, so a single node is expected, DPI sets its resolution and
+// JPEGQuality its quality
+func RenderToJPEG(writer io.Writer, opts ...RenderOption) error {
+	img, config, err := renderRaster(opts)
+	if err != nil {
+		return err
+	}
+	return jpeg.Encode(writer, img, &jpeg.Options{Quality: config.quality})
+}
+
+// renderRaster collects the options of RenderToPNG and RenderToJPEG and draws their page
+func renderRaster(opts []RenderOption) (*image.RGBA, renderConfig, error) {
+	config := renderConfig{dpi: 72, quality: jpeg.DefaultQuality}
+	for _, opt := range opts {
+		opt.configureRender(&config)
+	}
+
+	if len(config.pages) != 1 {
+		return nil, config, fmt.Errorf("an image holds a single page, got %d nodes", len(config.pages))
+	}
+	if config.quality < 1 || config.quality > 100 {
+		return nil, config, fmt.Errorf("JPEG quality must be between 1 and 100, got %d", config.quality)
+	}
+
+	pages := []RenderOption{config.pages[0]}
+	if config.debug {
+		pages = append(pages, DebugOverlay())
+	}
+	img, err := renderImage(config.dpi, pages)
+	return img, config, err
+}
+
+// RenderToImage draws the laid-out node tree on a white image with dpi pixels per inch,
+// like RenderToPDF draws it on a page. Text uses the fonts loaded with LoadFonts, text
+// using another font is drawn with the Go fonts
+func RenderToImage(node *Node, dpi float64) (*image.RGBA, error) {
+	if node == nil {
+		return nil, fmt.Errorf("there is no node to render")
+	}
+	return renderImage(dpi, []RenderOption{node})
+}
+
+// renderImage draws the page passed with the options on an image
+func renderImage(dpi float64, opts []RenderOption) (*image.RGBA, error) {
+	if dpi <= 0 {
+		return nil, fmt.Errorf("DPI must be positive, got %f", dpi)
+	}
+
+	canvas := &rasterCanvas{scale: dpi / 72}
+	if err := Render(canvas, opts...); err != nil {
+		return nil, err
+	}
+	return canvas.img, nil
+}
+
+// rasterCanvas draws on an image, positions in points are multiplied by scale
+type rasterCanvas struct {
+	scale float64
+	img   *image.RGBA
+	clips []image.Rectangle  // The clips pushed on the page, in pixels
+	faces map[Font]font.Face // The faces used on the page
+}
+
+var _ Canvas = (*rasterCanvas)(nil)
+
+// BeginPage starts a new white image, an image holds a single page
+func (r *rasterCanvas) BeginPage(width, height float64) error {
+	r.img = image.NewRGBA(image.Rect(0, 0, int(math.Ceil(width*r.scale)), int(math.Ceil(height*r.scale))))
+	draw.Draw(r.img, r.img.Bounds(), image.White, image.Point{}, draw.Src)
+	r.clips = nil
+	r.faces = map[Font]font.Face{}
+	return nil
+}
+
+func (r *rasterCanvas) EndPage() error {
+	for _, face := range r.faces {
+		face.Close()
+	}
+	r.faces = nil
+	return nil
+}
+
+// Rect draws the rectangle, the stroke is an outer rectangle with the inner one cut out
+func (r *rasterCanvas) Rect(rect Rect, style Style) error {
+	x, y, width, height := rect.X, rect.Y, rect.Width, rect.Height
+
+	if style.Fill != nil {
+		r.fill(rasterPaint(*style.Fill, style), [][]Point{{{x, y}, {x + width, y}, {x + width, y + height}, {x, y + height}}})
+	}
+
+	if style.Stroke != nil && style.StrokeWidth > 0 {
+		half := style.StrokeWidth / 2
+		shapes := [][]Point{{{x - half, y - half}, {x + width + half, y - half}, {x + width + half, y + height + half}, {x - half, y + height + half}}}
+		if width > style.StrokeWidth && height > style.StrokeWidth {
+			// drawn in the opposite direction, the inner rectangle cuts a hole
+			shapes = append(shapes, []Point{{x + half, y + half}, {x + half, y + height - half}, {x + width - half, y + height - half}, {x + width - half, y + half}})
+		}
+		r.fill(rasterPaint(*style.Stroke, style), shapes)
+	}
+
+	return nil
+}
+
+// Path fills the points as a polygon, the stroke is a quadrilateral along every segment
+func (r *rasterCanvas) Path(points []Point, closed bool, style Style) error {
+	if len(points) < 2 {
+		return nil
+	}
+
+	if style.Fill != nil {
+		r.fill(rasterPaint(*style.Fill, style), [][]Point{points})
+	}
+
+	if style.Stroke != nil && style.StrokeWidth > 0 {
+		if closed {
+			points = append(points[:len(points):len(points)], points[0])
+		}
+
+		var shapes [][]Point
+		for i := 1; i < len(points); i++ {
+			p, q := points[i-1], points[i]
+			length := math.Hypot(q.X-p.X, q.Y-p.Y)
+			if length == 0 {
+				continue
+			}
+			// Every quadrilateral turns the same way, so overlapping ones don't cut holes
+			nx, ny := -(q.Y-p.Y)/length*style.StrokeWidth/2, (q.X-p.X)/length*style.StrokeWidth/2
+			shapes = append(shapes, []Point{{p.X + nx, p.Y + ny}, {q.X + nx, q.Y + ny}, {q.X - nx, q.Y - ny}, {p.X - nx, p.Y - ny}})
+		}
+		r.fill(rasterPaint(*style.Stroke, style), shapes)
+	}
+
+	return nil
+}
+
+// rasterPaint returns the color with the opacity of the style
+func rasterPaint(c Color, style Style) color.Color {
+	if !style.translucent() {
+		return c
+	}
+	return color.NRGBA{R: c.R, G: c.G, B: c.B, A: uint8(math.Round(style.Opacity * 255))}
+}
+
+// fill draws polygons given in points with anti-aliasing, inside the current clip
+func (r *rasterCanvas) fill(c color.Color, shapes [][]Point) {
+	bounds := r.img.Bounds()
+	z := vector.NewRasterizer(bounds.Dx(), bounds.Dy())
+
+	for _, shape := range shapes {
+		z.MoveTo(float32(shape[0].X*r.scale), float32(shape[0].Y*r.scale))
+		for _, point := range shape[1:] {
+			z.LineTo(float32(point.X*r.scale), float32(point.Y*r.scale))
+		}
+		z.ClosePath()
+	}
+
+	if len(r.clips) == 0 {
+		z.Draw(r.img, bounds, image.NewUniform(c), image.Point{})
+		return
+	}
+
+	mask := image.NewAlpha(bounds)
+	z.Draw(mask, bounds, image.Opaque, image.Point{})
+	clip := r.clips[len(r.clips)-1]
+	draw.DrawMask(r.img, clip, image.NewUniform(c), image.Point{}, mask, clip.Min, draw.Over)
+}
+
+// target returns the part of the image inside the current clip
+func (r *rasterCanvas) target() *image.RGBA {
+	if len(r.clips) == 0 {
+		return r.img
+	}
+	return r.img.SubImage(r.clips[len(r.clips)-1]).(*image.RGBA)
+}
+
+// face returns the face of the font at the resolution of the image
+func (r *rasterCanvas) face(f Font) font.Face {
+	face := r.faces[f]
+	if face == nil {
+		face = truetype.NewFace(rasterFont(f.Type), &truetype.Options{
+			Size:    f.Size,
+			DPI:     72 * r.scale,
+			Hinting: font.HintingNone,
+		})
+		r.faces[f] = face
+	}
+	return face
+}
+
+func (r *rasterCanvas) MeasureText(text string, f Font) float64 {
+	return float64(font.MeasureString(r.face(f), text)) / 64 / r.scale
+}
+
+func (r *rasterCanvas) Text(run TextRun) error {
+	drawer := font.Drawer{
+		Dst:  r.target(),
+		Src:  image.NewUniform(run.Color),
+		Face: r.face(run.Font),
+		Dot:  fixed.Point26_6{X: fixed.Int26_6(run.X * r.scale * 64), Y: fixed.Int26_6(run.Y * r.scale * 64)},
+	}
+	drawer.DrawString(run.Text)
+	return nil
+}
+
+// rasterFont returns the loaded font of the given type, or the Go font closest to it
+func rasterFont(fontType string) *truetype.Font {
+	if f := loadedFont(fontType); f != nil {
+		return f
+	}
+	if mapFontName(fontType) == "Courier" {
+		return goMonoFont()
+	}
+	return goRegularFont()
+}
+
+// Image draws an image file scaled to the rectangle
+func (r *rasterCanvas) Image(src string, rect Rect) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to read image: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("failed to decode image %s: %w", src, err)
+	}
+
+	x, y := rect.X*r.scale, rect.Y*r.scale
+	dst := image.Rect(
+		int(math.Round(x)),
+		int(math.Round(y)),
+		int(math.Round(x+rect.Width*r.scale)),
+		int(math.Round(y+rect.Height*r.scale)),
+	)
+	xdraw.CatmullRom.Scale(r.target(), dst, img, img.Bounds(), xdraw.Over, nil)
+
+	return nil
+}
+
+func (r *rasterCanvas) PushClip(rect Rect) error {
+	clip := image.Rect(
+		int(math.Floor(rect.X*r.scale)),
+		int(math.Floor(rect.Y*r.scale)),
+		int(math.Ceil((rect.X+rect.Width)*r.scale)),
+		int(math.Ceil((rect.Y+rect.Height)*r.scale)),
+	).Intersect(r.target().Bounds())
+	r.clips = append(r.clips, clip)
+	return nil
+}
+
+func (r *rasterCanvas) PopClip() error {
+	if len(r.clips) == 0 {
+		return fmt.Errorf("PopClip without PushClip")
+	}
+	r.clips = r.clips[:len(r.clips)-1]
+	return nil
+}
+
+// Link does nothing, images have no links
+func (r *rasterCanvas) Link(rect Rect, url string) error {
+	return nil
+}
@@ -0,0 +1,188 @@
+package sahar
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderToImage(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+
+	t.Run("draws backgrounds and borders", func(t *testing.T) {
+		page := Layout(Box(
+			Sizing(Fixed(100), Fixed(60)),
+			Padding(10, 10, 10, 10),
+			Children(
+				Box(Sizing(Fixed(20), Fixed(20)), BackgroundColor("#FF0000")),
+				Box(Sizing(Fixed(30), Fixed(30)), Border(2), BorderColor("#0000FF")),
+			),
+		))
+
+		img, err := RenderToImage(page, 72)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if img.Bounds() != image.Rect(0, 0, 100, 60) {
+			t.Errorf("expected a 100x60 image, got %v", img.Bounds())
+		}
+		if c := img.RGBAAt(2, 2); c != white {
+			t.Errorf("expected white page, got %v", c)
+		}
+		if c := img.RGBAAt(20, 20); c != red {
+			t.Errorf("expected red background, got %v", c)
+		}
+		if c := img.RGBAAt(30, 20); c != blue {
+			t.Errorf("expected blue border on the left edge, got %v", c)
+		}
+		if c := img.RGBAAt(45, 25); c != white {
+			t.Errorf("expected the inside of the border to be empty, got %v", c)
+		}
+	})
+
+	t.Run("scales with the DPI", func(t *testing.T) {
+		page := Layout(Box(Sizing(Fixed(100), Fixed(60)), Children(
+			Box(Sizing(Fixed(20), Fixed(20)), BackgroundColor("#FF0000")),
+		)))
+
+		img, err := RenderToImage(page, 144)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if img.Bounds() != image.Rect(0, 0, 200, 120) {
+			t.Errorf("expected a 200x120 image, got %v", img.Bounds())
+		}
+		if c := img.RGBAAt(35, 35); c != red {
+			t.Errorf("expected the background to be scaled, got %v", c)
+		}
+	})
+
+	t.Run("draws text inside its node", func(t *testing.T) {
+		text := Text("Hello", FontType("Arial"), FontSize(20), FontColor("#000000"))
+		page := Layout(Box(Sizing(Fixed(200), Fixed(100)), Alignment(Center, Middle), Children(text)))
+
+		img, err := RenderToImage(page, 72)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		inside, outside := 0, 0
+		for y := 0; y < 100; y++ {
+			for x := 0; x < 200; x++ {
+				if img.RGBAAt(x, y).R > 128 {
+					continue
+				}
+				if x >= int(text.Position.X)-1 && x <= int(text.Position.X+text.Width.Value)+1 && y >= int(text.Position.Y)-1 && y <= int(text.Position.Y+text.Height.Value)+1 {
+					inside++
+				} else {
+					outside++
+				}
+			}
+		}
+		if inside == 0 || outside > 0 {
+			t.Errorf("expected the text in its node, got %d dark pixels inside and %d outside", inside, outside)
+		}
+	})
+
+	t.Run("draws images", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "blue.png")
+		src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		for i := range src.Pix {
+			src.Pix[i] = []uint8{0, 0, 255, 255}[i%4]
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, src); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		page := Layout(Box(Sizing(Fixed(50), Fixed(50)), Padding(5, 5, 5, 5), Children(Image(path, Sizing(Fixed(40), Fixed(40))))))
+		img, err := RenderToImage(page, 72)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if c := img.RGBAAt(25, 25); c != blue {
+			t.Errorf("expected the image to be drawn, got %v", c)
+		}
+
+		if _, err := RenderToImage(Layout(Box(Sizing(Fixed(50), Fixed(50)), Children(Image("missing.png", Sizing(Fixed(10), Fixed(10)))))), 72); err == nil {
+			t.Error("expected an error for a missing image")
+		}
+	})
+}
+
+func TestRenderToPNG(t *testing.T) {
+	page := Layout(Box(Sizing(Fixed(100), Fixed(50)), BackgroundColor("#EEEEEE"), Children(Text("Preview"))))
+
+	t.Run("draws the debug overlay", func(t *testing.T) {
+		white := color.RGBA{255, 255, 255, 255}
+		page := Layout(Box(Sizing(Fixed(100), Fixed(60)), Padding(10, 10, 10, 10)))
+
+		var buf bytes.Buffer
+		if err := RenderToPNG(&buf, page, DebugOverlay()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		img, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The padding is shaded with the translucent color of boxes
+		if c := color.RGBAModel.Convert(img.At(50, 55)).(color.RGBA); c == white || c.R < 200 {
+			t.Errorf("expected shaded padding, got %v", c)
+		}
+		if c := color.RGBAModel.Convert(img.At(50, 30)).(color.RGBA); c != white {
+			t.Errorf("expected the content to be left white, got %v", c)
+		}
+	})
+
+	t.Run("encodes PNG", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := RenderToPNG(&buf, page, DPI(144)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		config, err := png.DecodeConfig(&buf)
+		if err != nil || config.Width != 200 || config.Height != 100 {
+			t.Errorf("expected a 200x100 PNG, got %+v %v", config, err)
+		}
+	})
+
+	t.Run("encodes JPEG", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := RenderToJPEG(&buf, page, JPEGQuality(90)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		config, err := jpeg.DecodeConfig(&buf)
+		if err != nil || config.Width != 100 || config.Height != 50 {
+			t.Errorf("expected a 100x50 JPEG, got %+v %v", config, err)
+		}
+	})
+
+	t.Run("reports invalid options", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := RenderToPNG(&buf); err == nil {
+			t.Error("expected an error without node")
+		}
+		if err := RenderToPNG(&buf, page, page); err == nil {
+			t.Error("expected an error for several pages")
+		}
+		if err := RenderToPNG(&buf, page, DPI(0)); err == nil {
+			t.Error("expected an error for a zero DPI")
+		}
+		if err := RenderToJPEG(&buf, page, JPEGQuality(101)); err == nil {
+			t.Error("expected an error for an invalid quality")
+		}
+	})
+}
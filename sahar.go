@@ -1,6 +1,7 @@
 package sahar
 
 import (
+	"fmt"
 	"math"
 )
 
@@ -32,15 +33,31 @@ const (
 )
 
 // Type represents the type of a node.
-// It can be BoxType, TextType, or ImageType.
+// It can be BoxType, TextType, ImageType, or GridType.
 type Type int
 
 const (
 	BoxType Type = iota
 	TextType
 	ImageType
+	GridType
 )
 
+func (t Type) String() string {
+	switch t {
+	case BoxType:
+		return "Box"
+	case TextType:
+		return "Text"
+	case ImageType:
+		return "Image"
+	case GridType:
+		return "Grid"
+	default:
+		return fmt.Sprintf("Type(%d)", int(t))
+	}
+}
+
 // Position represents the position of a node in the layout.
 // It contains X and Y coordinates and it will be calculated by the layout engine.
 type Position struct {
@@ -75,7 +92,7 @@ type Size struct {
 }
 
 // Node represents a layout node.
-// It can be a box, text, or image.
+// It can be a box, text, image, or grid.
 // It contains properties for alignment, size, padding, and children nodes.
 type Node struct {
 	Direction       direction
@@ -89,17 +106,54 @@ type Node struct {
 	ChildGap        float64 // Space between children
 	Width, Height   Size
 	Padding         [4]float64 // Top, Right, Bottom, Left
+	Margin          [4]float64 // Top, Right, Bottom, Left
 	Horizontal      Horizontal
 	Vertical        Vertical
 	Parent          *Node
 	Children        []*Node
-	Border          float64 // Border width for Box nodes
-	BorderColor     string  // Border color for Box nodes
-	BackgroundColor string  // Background color for Box nodes
+	Border          float64  // Border width for Box nodes
+	BorderColor     string   // Border color for Box nodes
+	BackgroundColor string   // Background color for Box nodes
+	Columns, Rows   []Track  // Track definitions for Grid nodes
+	ColumnGap       float64  // Space between columns for Grid nodes
+	RowGap          float64  // Space between rows for Grid nodes
+	Cell            GridCell // Placement inside a Grid parent
+	Shrink          float64  // Shrink weight, nodes with 0 never shrink
+	Link            string   // URL or #anchor the node links to
+	Anchor          string   // Name links to the node refer to
+	Bookmark        string   // Title of the node in the outline of the document
+	BookmarkLevel   int      // Nesting level of the bookmark, 0 is the top level
+	Role            Role     // Meaning of the node in a tagged PDF, see Tag
+	Alt             string   // Text read instead of the node, see AltText
+
+	// file attached to the node, see Attach
+	Attachment *Attachment
+
+	// form field drawn over the node, see TextField
+	Field *FormField
+
+	// calculated by the layout engine when the content does not fit, width and height
+	overflow [2]float64
+
+	// calculated by the layout engine for Grid nodes
+	gridCells   []gridPlacement
+	gridColumns []float64
+	gridRows    []float64
+
+	// set for tables of contents, builds their entries
+	contents func(TOCEntry) *Node
 }
 
 var _ nodeOpt = (*Node)(nil)
 
+// Overflow returns how much the content of the node exceeds its content area after
+// Layout, horizontally and vertically. The layout engine shrinks children to make them
+// fit, but fixed children and text narrower than its longest word can not be shrunk,
+// so a positive value means the children overlap or stick out of the node.
+func (n *Node) Overflow() (width, height float64) {
+	return n.overflow[0], n.overflow[1]
+}
+
 func (n *Node) configureNode(node *Node) {
 	n.Parent = node
 	node.Children = append(node.Children, n)
@@ -131,12 +185,13 @@ func Border(width float64) border {
 
 // Text creates a new text node with the specified value and options.
 // A text node is used to display text with specific font, size, and color.
-// It can also have a border for debugging purposes.
+// It can also have a border, to inspect the layout of a whole document see DebugOverlay.
 func Text(value string, opts ...textOpt) *Node {
 	n := &Node{
 		Type:      TextType,
 		Direction: LeftToRight,
 		Value:     value,
+		Shrink:    1,
 		Width: Size{
 			Type:  FitType,
 			Value: 0,
@@ -165,6 +220,7 @@ func Box(opts ...nodeOpt) *Node {
 	n := &Node{
 		Type:      BoxType,
 		Direction: LeftToRight,
+		Shrink:    1,
 		Width: Size{
 			Type:  FitType,
 			Value: 0,
@@ -192,6 +248,7 @@ func Image(src string, opts ...nodeOpt) *Node {
 		Type:      ImageType,
 		Direction: LeftToRight,
 		Value:     src,
+		Shrink:    1,
 		Width: Size{
 			Type:  FitType,
 			Value: 0,
@@ -261,6 +318,25 @@ func Padding(top, right, bottom, left float64) nodeOpt {
 	})
 }
 
+// Margin sets the space around the node, outside of its border.
+// Unlike ChildGap which is the same between all children, margin belongs to a single node
+func Margin(top, right, bottom, left float64) commonOpt {
+	return commonOptFunc(func(n *Node) {
+		n.Margin[0] = top
+		n.Margin[1] = right
+		n.Margin[2] = bottom
+		n.Margin[3] = left
+	})
+}
+
+// Shrink sets how much of the overflow a node takes compared to its siblings when
+// they do not fit in their parent. The default weight is 1, and 0 means the node never shrinks
+func Shrink(weight float64) commonOpt {
+	return commonOptFunc(func(n *Node) {
+		n.Shrink = weight
+	})
+}
+
 // Min is set the value for Width or Height if they are set to either Fit or Grow
 func Min(value float64) fitOpt {
 	return fitOptFunc(func(s *Size) {
@@ -468,6 +544,21 @@ func (f sizingOptFunc) configureSizing(s *Size) {
 	f(s)
 }
 
+type commonOpt interface {
+	nodeOpt
+	textOpt
+}
+
+type commonOptFunc func(*Node)
+
+func (f commonOptFunc) configureNode(n *Node) {
+	f(n)
+}
+
+func (f commonOptFunc) configureText(n *Node) {
+	f(n)
+}
+
 type textOpt interface {
 	configureText(*Node)
 }
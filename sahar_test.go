@@ -333,6 +333,18 @@ func TestPaddingDef(t *testing.T) {
 	}
 }
 
+func TestMarginDef(t *testing.T) {
+	box := Box(Margin(1, 2, 3, 4))
+	text := Text("Hi", Margin(5, 6, 7, 8))
+
+	if box.Margin != [4]float64{1, 2, 3, 4} {
+		t.Errorf("expected Margin to be [1 2 3 4], got %v", box.Margin)
+	}
+	if text.Margin != [4]float64{5, 6, 7, 8} {
+		t.Errorf("expected Margin to be [5 6 7 8], got %v", text.Margin)
+	}
+}
+
 func TestChildGap(t *testing.T) {
 	node := Box(ChildGap(25))
 
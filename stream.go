@@ -0,0 +1,544 @@
+package sahar
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+
+	"codeberg.org/go-pdf/fpdf"
+)
+
+// PDFStream writes a PDF one page at a time, for documents too large to hold in
+// memory. Every page is drawn on a document of its own whose objects are written to
+// the writer right away, so only the positions of the objects, the bookmarks and the
+// hashes of the resources are kept until Close writes the end of the document.
+// Resources identical on several pages, such as images and standard fonts, are written
+// once, while embedded fonts are subset for every page, unless FullFonts embeds them
+// whole, once.
+//
+// Anchors, links to anchors, tables of contents, form fields, attached files, tagged
+// PDF and PDF/A need the whole document and are reported as errors, see RenderPDF
+type PDFStream struct {
+	writer    io.Writer
+	hash      hash.Hash // The MD5 of everything written, the file identifier
+	written   int
+	config    renderConfig
+	encrypter *pdfEncrypter
+
+	offsets   []int // The position of every object by number
+	pages     []int // The numbers of the page objects
+	shared    map[[sha256.Size]byte]int
+	catalog   []byte // The catalog of the first page, written by Close
+	info      int
+	bookmarks []pdfStreamBookmark
+	level     int // The level of the last bookmark
+
+	images map[string]bool // The images drawn, whether they are downsampled
+	drawn  int             // The number of drawings of images
+	size   PDFSize
+	err    error // The first failed write, after which the document can't be finished
+}
+
+// pdfStreamBookmark is a bookmark of a PDFStream on the page object page
+type pdfStreamBookmark struct {
+	title string
+	level int
+	page  int
+	top   float64 // The position of the bookmark from the bottom of the page
+}
+
+// The objects a PDFStream writes last have the first numbers
+const (
+	pdfStreamPages   = 1
+	pdfStreamCatalog = 2
+)
+
+// NewPDFStream starts a PDF written to writer. The options are the render options of
+// RenderPDF, except for the pages which are passed to WritePage, and Deterministic
+// can't be combined with Encryption
+func NewPDFStream(writer io.Writer, opts ...RenderOption) (*PDFStream, error) {
+	var config renderConfig
+	for _, opt := range opts {
+		opt.configureRender(&config)
+	}
+
+	switch {
+	case len(config.pages) > 0:
+		return nil, errors.New("the pages of a stream are passed to WritePage")
+	case config.pdfa != 0:
+		return nil, fmt.Errorf("%s documents can't be streamed", config.pdfa)
+	case config.tagged:
+		return nil, errors.New("tagged documents can't be streamed")
+	case len(config.attachments) > 0:
+		return nil, errors.New("streamed documents can't have attachments")
+	case config.deterministic && config.encryption != nil:
+		// The keys would only be derived from the passwords, the content is not written yet
+		return nil, errors.New("streamed documents can't be both deterministic and encrypted")
+	}
+	if err := checkEncryption(config); err != nil {
+		return nil, err
+	}
+	if err := checkCompression(config); err != nil {
+		return nil, err
+	}
+
+	s := &PDFStream{
+		writer:  writer,
+		hash:    md5.New(),
+		config:  config,
+		offsets: make([]int, pdfStreamCatalog+1),
+		shared:  map[[sha256.Size]byte]int{},
+		level:   -1,
+		images:  map[string]bool{},
+	}
+	if config.encryption != nil {
+		encrypter, err := newPDFEncrypter(*config.encryption, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		s.encrypter = encrypter
+	}
+	if err := s.write([]byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// RenderPagesToPDF writes a PDF of the pages returned by page, called with the index of
+// every page from 0 until it returns nil, see PDFStream. A page is laid out by page and
+// rendered before the next one is asked for, so it can be released right away
+func RenderPagesToPDF(writer io.Writer, page func(index int) (*Node, error), opts ...RenderOption) error {
+	s, err := NewPDFStream(writer, opts...)
+	if err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		node, err := page(i)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+		if node == nil {
+			break
+		}
+		if err := s.WritePage(node); err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+	}
+	return s.Close()
+}
+
+// WritePage renders a laid-out node as the next page and writes it. Nothing is written
+// when the page is reported as invalid, so the stream can go on
+func (s *PDFStream) WritePage(page *Node) error {
+	if s.err != nil {
+		return s.err
+	}
+	level, err := s.checkPage(page)
+	if err != nil {
+		return err
+	}
+
+	pdf := fpdf.New("P", "pt", "A4", "")
+	if err := setupDocument(pdf, s.config); err != nil {
+		return err
+	}
+	canvas := &pdfCanvas{pdf: pdf, embedFonts: s.config.embedFonts, fullFonts: s.config.fullFonts, maxDPI: s.config.maxDPI, quality: s.config.quality}
+	r := &canvasRenderer{canvas: canvas, font: defaultFont}
+	if err := canvas.BeginPage(page.Width.Value, page.Height.Value); err != nil {
+		return err
+	}
+	if err := r.node(page); err != nil {
+		return fmt.Errorf("failed to render node: %w", err)
+	}
+	if s.config.debug {
+		if err := r.debugOverlay(page); err != nil {
+			return err
+		}
+	}
+	if err := canvas.EndPage(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return err
+	}
+	f, err := parsePDF(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	// Once the first object is written the document is broken by any error
+	s.err = s.transplant(f)
+	if s.err != nil {
+		return s.err
+	}
+
+	s.level = level
+	for _, b := range canvas.bookmarks {
+		s.bookmarks = append(s.bookmarks, pdfStreamBookmark{b.title, b.level, s.pages[len(s.pages)-1], page.Height.Value - b.y})
+	}
+	for name, downsampled := range canvas.images {
+		s.images[name] = s.images[name] || downsampled
+	}
+	s.drawn += len(canvas.images) + canvas.reused
+	return nil
+}
+
+// checkPage reports what a page of a stream can't hold and returns the level of its
+// last bookmark
+func (s *PDFStream) checkPage(page *Node) (int, error) {
+	level := s.level
+	var walk func(node *Node) error
+	walk = func(node *Node) error {
+		if node == nil {
+			return nil
+		}
+
+		switch {
+		case node.Anchor != "":
+			return fmt.Errorf("anchor %q: streamed documents can't have anchors", node.Anchor)
+		case strings.HasPrefix(node.Link, "#"):
+			return fmt.Errorf("link to %q: streamed documents can't link to anchors", node.Link)
+		case node.contents != nil:
+			return errors.New("streamed documents can't have tables of contents")
+		case node.Attachment != nil:
+			return fmt.Errorf("attachment %q: streamed documents can't have attachments", node.Attachment.Name)
+		case node.Field != nil:
+			return fmt.Errorf("form field %q: streamed documents can't have form fields", node.Field.Name)
+		}
+		if node.Bookmark != "" {
+			if node.BookmarkLevel < 0 || node.BookmarkLevel > level+1 {
+				return fmt.Errorf("bookmark %q has level %d, expected a level from 0 to %d", node.Bookmark, node.BookmarkLevel, level+1)
+			}
+			level = node.BookmarkLevel
+		}
+
+		for _, child := range node.Children {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return level, walk(page)
+}
+
+// transplant writes the page of a document written by fpdf with the objects it refers
+// to, numbered after the objects already written. The catalog and the information
+// dictionary of the first page are kept for the document
+func (s *PDFStream) transplant(f *pdfFile) error {
+	pages := f.pages()
+	if len(pages) != 1 {
+		return errors.New("failed to stream the page: unexpected pages")
+	}
+
+	t := &pdfTransplant{
+		stream:  s,
+		f:       f,
+		numbers: map[int]int{1: pdfStreamPages},
+		parts:   map[int]*int{},
+	}
+	for _, match := range pdfContents.FindAllSubmatch(f.objects[pages[0]], -1) {
+		n, _ := strconv.Atoi(string(match[1]))
+		t.parts[n] = &s.size.Content
+	}
+
+	page, err := t.object(pages[0], nil)
+	if err != nil {
+		return err
+	}
+	s.pages = append(s.pages, page)
+
+	if len(s.pages) == 1 {
+		if s.info, err = t.object(f.info, nil); err != nil {
+			return err
+		}
+		if s.catalog, err = t.renumber(f.root, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pdfTransplant numbers the objects of a page of a PDFStream
+type pdfTransplant struct {
+	stream  *PDFStream
+	f       *pdfFile
+	numbers map[int]int  // The numbers in the stream, zero while the object is written
+	parts   map[int]*int // The content streams, which are not shared
+}
+
+// object writes an object once the objects it refers to are written, and returns its
+// number. Objects other than pages and their content are shared by content. part is
+// the part of the size of the object referring to it
+func (t *pdfTransplant) object(n int, part *int) (int, error) {
+	if number, ok := t.numbers[n]; ok {
+		if number == 0 {
+			return 0, fmt.Errorf("failed to stream the page: object %d refers to itself", n)
+		}
+		return number, nil
+	}
+	t.numbers[n] = 0
+
+	if p := t.parts[n]; p != nil {
+		part = p
+	} else if p := pdfPart(t.f.objects[n], &t.stream.size); p != nil {
+		part = p
+	}
+	body, err := t.renumber(n, part)
+	if err != nil {
+		return 0, err
+	}
+
+	s := t.stream
+	shared := t.parts[n] == nil && !bytes.HasPrefix(body, []byte("<</Type /Page\n"))
+	sum := sha256.Sum256(body)
+	if number, ok := s.shared[sum]; ok && shared {
+		t.numbers[n] = number
+		return number, nil
+	}
+
+	number := len(s.offsets)
+	s.offsets = append(s.offsets, 0)
+	if err := s.writeObject(number, body, part); err != nil {
+		return 0, err
+	}
+	if shared {
+		s.shared[sum] = number
+	}
+	t.numbers[n] = number
+	return number, nil
+}
+
+// renumber returns an object without its number, the objects its dictionary refers to
+// written and renumbered
+func (t *pdfTransplant) renumber(n int, part *int) ([]byte, error) {
+	object := t.f.objects[n]
+	start := bytes.Index(object, []byte(" obj\n"))
+	if start < 0 {
+		return nil, fmt.Errorf("failed to stream the page: invalid object %d", n)
+	}
+	body := object[start+len(" obj\n"):]
+	dictionary, stream := body, []byte(nil)
+	if i := bytes.Index(body, []byte("\nstream\n")); i >= 0 {
+		dictionary, stream = body[:i], body[i:]
+	}
+
+	var err error
+	renumbered := pdfReference.ReplaceAllFunc(dictionary, func(ref []byte) []byte {
+		child, _ := strconv.Atoi(string(ref[:bytes.IndexByte(ref, ' ')]))
+		if err != nil || child >= len(t.f.objects) || t.f.objects[child] == nil {
+			return ref
+		}
+		var number int
+		number, err = t.object(child, part)
+		return []byte(fmt.Sprintf("%d 0 R", number))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(renumbered, stream...), nil
+}
+
+// pdfPart returns the part of the size of a document an object is counted in, nil when
+// it is counted in the part of the object referring to it
+func pdfPart(object []byte, size *PDFSize) *int {
+	if i := bytes.Index(object, []byte("stream\n")); i >= 0 {
+		object = object[:i]
+	}
+	switch {
+	case bytes.Contains(object, []byte("/Subtype /Image")):
+		return &size.Images
+	case bytes.Contains(object, []byte("/Type /Font")):
+		return &size.Fonts
+	}
+	return nil
+}
+
+// writeObject writes an object, encrypted when the document is, and counts its size
+// in part
+func (s *PDFStream) writeObject(n int, body []byte, part *int) error {
+	object := append([]byte(fmt.Sprintf("%d 0 obj\n", n)), body...)
+	if s.encrypter != nil {
+		var err error
+		if object, err = s.encrypter.object(object); err != nil {
+			return fmt.Errorf("failed to encrypt object %d: %w", n, err)
+		}
+	}
+
+	s.offsets[n] = s.written
+	if part != nil {
+		*part += len(object)
+	}
+	return s.write(object)
+}
+
+// write writes to the writer and the file identifier
+func (s *PDFStream) write(data []byte) error {
+	n, err := s.writer.Write(data)
+	s.hash.Write(data[:n])
+	s.written += n
+	return err
+}
+
+// Close writes the page tree, the outline and the catalog that end the document. It
+// doesn't close the writer
+func (s *PDFStream) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+	if len(s.pages) == 0 {
+		return errors.New("there is no node to render")
+	}
+	s.err = errors.New("the stream is closed")
+	return s.finish()
+}
+
+// finish writes the end of the document
+func (s *PDFStream) finish() error {
+	var kids strings.Builder
+	for _, page := range s.pages {
+		fmt.Fprintf(&kids, "%d 0 R ", page)
+	}
+	if err := s.writeObject(pdfStreamPages, []byte(fmt.Sprintf("<</Type /Pages\n/Kids [%s]\n/Count %d\n/MediaBox [0 0 595.28 841.89]\n>>\nendobj\n", kids.String(), len(s.pages))), nil); err != nil {
+		return err
+	}
+
+	var entries []string
+	if len(s.bookmarks) > 0 {
+		outlines, err := s.writeOutline()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, fmt.Sprintf("/Outlines %d 0 R", outlines), "/PageMode /UseOutlines")
+	}
+	var encryption int
+	if s.encrypter != nil {
+		entries = append(entries, pdfEncryptionExtension)
+		encryption = len(s.offsets)
+		s.offsets = append(s.offsets, s.written)
+		if err := s.write([]byte(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", encryption, s.encrypter.dictionary))); err != nil {
+			return err
+		}
+	}
+	catalog := s.catalog
+	if len(entries) > 0 {
+		catalog = bytes.Replace(catalog, []byte("/Type /Catalog\n"), []byte("/Type /Catalog\n"+strings.Join(entries, "\n")+"\n"), 1)
+	}
+	if err := s.writeObject(pdfStreamCatalog, catalog, nil); err != nil {
+		return err
+	}
+
+	xref := s.written
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(s.offsets))
+	for _, offset := range s.offsets[1:] {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	if err := s.write(out.Bytes()); err != nil {
+		return err
+	}
+
+	sum := s.hash.Sum(nil)
+	out.Reset()
+	fmt.Fprintf(&out, "trailer\n<<\n/ID [<%X> <%X>]\n/Size %d\n/Root %d 0 R\n/Info %d 0 R\n", sum, sum, len(s.offsets), pdfStreamCatalog, s.info)
+	if encryption != 0 {
+		fmt.Fprintf(&out, "/Encrypt %d 0 R\n", encryption)
+	}
+	fmt.Fprintf(&out, ">>\nstartxref\n%d\n%%%%EOF\n", xref)
+	if err := s.write(out.Bytes()); err != nil {
+		return err
+	}
+
+	if s.config.size != nil {
+		size := s.size
+		size.Total = s.written
+		size.Other = size.Total - size.Content - size.Images - size.Fonts
+		size.ImagesWritten = len(s.images)
+		size.ImagesReused = s.drawn - len(s.images)
+		for _, downsampled := range s.images {
+			if downsampled {
+				size.ImagesDownsampled++
+			}
+		}
+		*s.config.size = size
+	}
+	return nil
+}
+
+// writeOutline writes the bookmarks as the outline of the document and returns the
+// number of its root
+func (s *PDFStream) writeOutline() (int, error) {
+	root := len(s.offsets)
+	first := root + 1
+	items := len(s.bookmarks)
+	s.offsets = append(s.offsets, make([]int, 1+items)...)
+
+	parent := make([]int, items)
+	prev := make([]int, items)
+	next := make([]int, items)
+	firstKid := make([]int, items)
+	lastKid := make([]int, items)
+	count := make([]int, items)
+	var last []int // The last item of every level
+	var top []int
+	for i, b := range s.bookmarks {
+		last = last[:min(len(last), b.level)]
+		parent[i] = -1
+		if b.level > 0 {
+			parent[i] = last[b.level-1]
+		}
+		prev[i], next[i], firstKid[i], lastKid[i] = -1, -1, -1, -1
+
+		siblings := -1
+		if parent[i] >= 0 {
+			siblings = lastKid[parent[i]]
+			if firstKid[parent[i]] < 0 {
+				firstKid[parent[i]] = i
+			}
+			lastKid[parent[i]] = i
+		} else {
+			if len(top) > 0 {
+				siblings = top[len(top)-1]
+			}
+			top = append(top, i)
+		}
+		if siblings >= 0 {
+			prev[i], next[siblings] = siblings, i
+		}
+		for p := parent[i]; p >= 0; p = parent[p] {
+			count[p]++
+		}
+		last = append(last, i)
+	}
+
+	ref := func(key string, i int) string {
+		if i < 0 {
+			return ""
+		}
+		return fmt.Sprintf(" /%s %d 0 R", key, first+i)
+	}
+	if err := s.writeObject(root, []byte(fmt.Sprintf("<</Type /Outlines /First %d 0 R /Last %d 0 R /Count %d>>\nendobj\n", first+top[0], first+top[len(top)-1], items)), nil); err != nil {
+		return 0, err
+	}
+	for i, b := range s.bookmarks {
+		up := root
+		if parent[i] >= 0 {
+			up = first + parent[i]
+		}
+		item := fmt.Sprintf("<</Title %s /Parent %d 0 R%s%s%s%s /Count %d /Dest [%d 0 R /XYZ 0 %.2f null]>>\nendobj\n",
+			pdfLiteral(b.title), up, ref("Prev", prev[i]), ref("Next", next[i]), ref("First", firstKid[i]), ref("Last", lastKid[i]), count[i], b.page, b.top)
+		if err := s.writeObject(first+i, []byte(item), nil); err != nil {
+			return 0, err
+		}
+	}
+	return root, nil
+}
@@ -0,0 +1,183 @@
+package sahar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPDFStream(t *testing.T) {
+	logo := filepath.Join(t.TempDir(), "logo.png")
+	file, err := os.Create(logo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, image.NewNRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	page := func(i int, opts ...nodeOpt) *Node {
+		return Layout(Box(append([]nodeOpt{
+			Sizing(Fixed(200), Fixed(100)),
+			Direction(TopToBottom),
+			Children(
+				Text(fmt.Sprintf("Statement %d", i+1), FontType("Helvetica"), FontSize(10), Bookmark(fmt.Sprintf("Statement %d", i+1), 0)),
+				Text("Details", FontType("Helvetica"), FontSize(10), Bookmark("Details", 1)),
+				Image(logo, Sizing(Fixed(10), Fixed(10))),
+			),
+		}, opts...)...))
+	}
+	pages := func(count int) func(int) (*Node, error) {
+		return func(i int) (*Node, error) {
+			if i == count {
+				return nil, nil
+			}
+			return page(i), nil
+		}
+	}
+
+	t.Run("writes the pages", func(t *testing.T) {
+		var size PDFSize
+		var buf bytes.Buffer
+		if err := RenderPagesToPDF(&buf, pages(3), Metadata{Title: "Statements"}, SizeReport(&size)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data := buf.Bytes()
+		checkXref(t, data)
+
+		f, err := parsePDF(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(f.pages()); n != 3 {
+			t.Fatalf("expected 3 pages, got %d", n)
+		}
+		output := string(data)
+		for _, expected := range []string{
+			"%PDF-1.7\n",
+			"/Title (Statements)",
+			"/Type /Outlines",
+			"/Title (Statement 3) /Parent ",
+			"/PageMode /UseOutlines",
+			"\n/ID [<",
+		} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("expected %s in the PDF", expected)
+			}
+		}
+		// The image and its transparency mask, and the font, are written once
+		for text, count := range map[string]int{"/Subtype /Image": 2, "/BaseFont /Helvetica": 1, "/Type /Catalog": 1, "/Title (Details)": 3} {
+			if n := strings.Count(output, text); n != count {
+				t.Errorf("expected %s %d times, got %d", text, count, n)
+			}
+		}
+
+		// The outline is made of the three statements holding their details
+		if !regexp.MustCompile(`/Type /Outlines /First \d+ 0 R /Last \d+ 0 R /Count 6>>`).MatchString(output) {
+			t.Error("expected six bookmarks in the outline")
+		}
+		if n := len(regexp.MustCompile(`/Title \(Statement \d\) /Parent \d+ 0 R.* /First \d+ 0 R /Last \d+ 0 R /Count 1 /Dest \[\d+ 0 R /XYZ 0 100.00 null\]`).FindAllString(output, -1)); n != 3 {
+			t.Errorf("expected 3 statements with their details, got %d", n)
+		}
+
+		if size.Total != len(data) || size.Content == 0 || size.Images == 0 || size.Fonts == 0 {
+			t.Errorf("unexpected size %+v", size)
+		}
+		if size.ImagesWritten != 1 || size.ImagesReused != 2 {
+			t.Errorf("expected the image written once, got %+v", size)
+		}
+	})
+
+	t.Run("is deterministic", func(t *testing.T) {
+		render := func() []byte {
+			var buf bytes.Buffer
+			if err := RenderPagesToPDF(&buf, pages(2), Deterministic()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return buf.Bytes()
+		}
+		if !bytes.Equal(render(), render()) {
+			t.Error("expected identical output in deterministic mode")
+		}
+	})
+
+	t.Run("encrypts the pages", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := RenderPagesToPDF(&buf, pages(2), Encryption{UserPassword: "open"}, Metadata{Title: "Statements"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		checkXref(t, buf.Bytes())
+		output := buf.String()
+		if !strings.Contains(output, "/CFM /AESV3") || !strings.Contains(output, "\n/Encrypt ") || !strings.Contains(output, pdfEncryptionExtension) {
+			t.Error("expected the document to be encrypted")
+		}
+		if strings.Contains(output, "(Statements)") || strings.Contains(output, "(Statement 1)") {
+			t.Error("expected the strings to be encrypted")
+		}
+	})
+
+	t.Run("goes on after an invalid page", func(t *testing.T) {
+		var buf bytes.Buffer
+		s, err := NewPDFStream(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.WritePage(page(0)); err != nil {
+			t.Fatal(err)
+		}
+		for _, invalid := range []struct {
+			page *Node
+			err  string
+		}{
+			{page(1, Anchor("top")), `anchor "top": streamed documents can't have anchors`},
+			{page(1, Link("#top")), `link to "#top": streamed documents can't link to anchors`},
+			{page(1, Children(TextField("name"))), `form field "name": streamed documents can't have form fields`},
+			{Layout(Box(Sizing(Fixed(100), Fixed(100)), Children(Text("x", Bookmark("x", 3))))), `bookmark "x" has level 3, expected a level from 0 to 2`},
+		} {
+			if err := s.WritePage(invalid.page); err == nil || !strings.Contains(err.Error(), invalid.err) {
+				t.Errorf("expected %q, got %v", invalid.err, err)
+			}
+		}
+		if err := s.WritePage(page(1)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+		checkXref(t, buf.Bytes())
+
+		if err := s.WritePage(page(2)); err == nil {
+			t.Error("expected an error once closed")
+		}
+	})
+
+	t.Run("reports errors", func(t *testing.T) {
+		boom := errors.New("boom")
+		err := RenderPagesToPDF(&bytes.Buffer{}, func(i int) (*Node, error) {
+			if i == 2 {
+				return nil, boom
+			}
+			return page(i), nil
+		})
+		if !errors.Is(err, boom) || !strings.HasPrefix(err.Error(), "page 3: ") {
+			t.Errorf("expected the error of page 3, got %v", err)
+		}
+
+		if err := RenderPagesToPDF(&bytes.Buffer{}, pages(0)); err == nil || err.Error() != "there is no node to render" {
+			t.Errorf("expected an empty document to be reported, got %v", err)
+		}
+		for _, opts := range [][]RenderOption{{page(0)}, {PDFA2B}, {Tagged()}, {Attachment{Name: "a.txt"}}, {Deterministic(), Encryption{OwnerPassword: "owner"}}} {
+			if _, err := NewPDFStream(&bytes.Buffer{}, opts...); err == nil {
+				t.Errorf("expected %T to be reported", opts[0])
+			}
+		}
+	})
+}
@@ -0,0 +1,309 @@
+package sahar
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// svgImageTypes maps the image types of detectImageType to their MIME types
+var svgImageTypes = map[string]string{
+	"JPG": "image/jpeg",
+	"PNG": "image/png",
+	"GIF": "image/gif",
+}
+
+// EmbedFonts embeds the files of the fonts loaded with LoadFonts in the SVG produced by
+// RenderToSVG and in the PDF produced by RenderToPDF, so text looks the same without
+// the fonts installed. Without it, SVG text refers to the fonts by name and PDF text
+// uses the standard Arial, Times or Courier
+func EmbedFonts() RenderOption {
+	return renderOptFunc(func(c *renderConfig) {
+		c.embedFonts = true
+	})
+}
+
+// RenderToSVG renders the node tree to an SVG image and writes it to the provided writer.
+// The image holds a single page, so a single node is expected. Backgrounds become rect
+// elements, borders path elements, text lines text elements and images image elements
+// with the file embedded, at the positions calculated by Layout. The background of the page is transparent
+func RenderToSVG(writer io.Writer, opts ...RenderOption) error {
+	var config renderConfig
+	for _, opt := range opts {
+		opt.configureRender(&config)
+	}
+
+	if len(config.pages) != 1 {
+		return fmt.Errorf("an SVG image holds a single page, got %d nodes", len(config.pages))
+	}
+
+	canvas := &svgCanvas{fonts: map[string]bool{}}
+	pages := []RenderOption{config.pages[0]}
+	if config.tagged {
+		pages = append(pages, Tagged())
+	}
+	if config.debug {
+		pages = append(pages, DebugOverlay())
+	}
+	if err := Render(canvas, pages...); err != nil {
+		return err
+	}
+
+	var svg bytes.Buffer
+	width, height := svgNumber(canvas.width), svgNumber(canvas.height)
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%spt" height="%spt" viewBox="0 0 %s %s" xml:space="preserve">`+"\n", width, height, width, height)
+
+	if config.embedFonts {
+		canvas.embedFonts(&svg)
+	}
+
+	svg.Write(canvas.body.Bytes())
+	svg.WriteString("</svg>\n")
+
+	_, err := writer.Write(svg.Bytes())
+	return err
+}
+
+// svgCanvas writes the elements of a page and collects the fonts they use
+type svgCanvas struct {
+	width, height float64
+	body          bytes.Buffer
+	fonts         map[string]bool
+	clips         int // The number of clips pushed, they are numbered in order
+	open          int // The number of clips not popped yet
+	tags          int // The number of tags not ended yet
+}
+
+var (
+	_ LinkCanvas   = (*svgCanvas)(nil)
+	_ AttachCanvas = (*svgCanvas)(nil)
+	_ TagCanvas    = (*svgCanvas)(nil)
+)
+
+func (c *svgCanvas) BeginPage(width, height float64) error {
+	c.width, c.height = width, height
+	return nil
+}
+
+func (c *svgCanvas) EndPage() error {
+	if c.open > 0 {
+		return fmt.Errorf("%d clips were not popped", c.open)
+	}
+	return nil
+}
+
+// Rect writes a rect, the stroke is centered on the edges like in the PDF
+func (c *svgCanvas) Rect(rect Rect, style Style) error {
+	fmt.Fprintf(&c.body, `<rect x="%s" y="%s" width="%s" height="%s"%s/>`+"\n",
+		svgNumber(rect.X), svgNumber(rect.Y), svgNumber(rect.Width), svgNumber(rect.Height), svgStyle(style))
+	return nil
+}
+
+func (c *svgCanvas) Path(points []Point, closed bool, style Style) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	var data strings.Builder
+	for i, point := range points {
+		command := "L"
+		if i == 0 {
+			command = "M"
+		}
+		fmt.Fprintf(&data, "%s%s %s ", command, svgNumber(point.X), svgNumber(point.Y))
+	}
+	if closed {
+		data.WriteString("Z")
+	}
+
+	fmt.Fprintf(&c.body, `<path d="%s"%s/>`+"\n", strings.TrimSpace(data.String()), svgStyle(style))
+	return nil
+}
+
+func (c *svgCanvas) MeasureText(text string, font Font) float64 {
+	return measureTextWidth(text, font.Size, font.Type)
+}
+
+// Text writes a text element. Runs are anchored on the side they are aligned to, so they
+// stay aligned when the viewer measures the font differently
+func (c *svgCanvas) Text(run TextRun) error {
+	c.fonts[run.Font.Type] = true
+
+	x, anchor := run.X, "start"
+	switch run.Align {
+	case Center:
+		x, anchor = x+run.Width/2, "middle"
+	case Right:
+		x, anchor = x+run.Width, "end"
+	}
+
+	fmt.Fprintf(&c.body, `<text x="%s" y="%s" font-family="%s" font-size="%s" fill="%s" text-anchor="%s">`,
+		svgNumber(x), svgNumber(run.Y), svgFontFamily(run.Font.Type), svgNumber(run.Font.Size), svgColor(run.Color), anchor)
+	xml.EscapeText(&c.body, []byte(run.Text))
+	c.body.WriteString("</text>\n")
+	return nil
+}
+
+// Image writes an image element with the file embedded as a data URL
+func (c *svgCanvas) Image(src string, rect Rect) error {
+	imageType, err := detectImageType(src)
+	if err != nil {
+		return fmt.Errorf("failed to detect image type: %w", err)
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read image: %w", err)
+	}
+
+	fmt.Fprintf(&c.body, `<image x="%s" y="%s" width="%s" height="%s" preserveAspectRatio="none" href="data:%s;base64,%s"/>`+"\n",
+		svgNumber(rect.X), svgNumber(rect.Y), svgNumber(rect.Width), svgNumber(rect.Height),
+		svgImageTypes[imageType], base64.StdEncoding.EncodeToString(data))
+	return nil
+}
+
+// PushClip opens a group clipped to the rectangle
+func (c *svgCanvas) PushClip(rect Rect) error {
+	c.clips++
+	c.open++
+	fmt.Fprintf(&c.body, `<clipPath id="clip%d"><rect x="%s" y="%s" width="%s" height="%s"/></clipPath><g clip-path="url(#clip%d)">`+"\n",
+		c.clips, svgNumber(rect.X), svgNumber(rect.Y), svgNumber(rect.Width), svgNumber(rect.Height), c.clips)
+	return nil
+}
+
+func (c *svgCanvas) PopClip() error {
+	if c.open == 0 {
+		return fmt.Errorf("PopClip without PushClip")
+	}
+	c.open--
+	c.body.WriteString("</g>\n")
+	return nil
+}
+
+// Link writes an invisible rect covering the area inside a link
+func (c *svgCanvas) Link(rect Rect, url string) error {
+	fmt.Fprintf(&c.body, `<a href="%s"><rect x="%s" y="%s" width="%s" height="%s" fill="#FFFFFF" fill-opacity="0"/></a>`+"\n",
+		svgEscape(url), svgNumber(rect.X), svgNumber(rect.Y), svgNumber(rect.Width), svgNumber(rect.Height))
+	return nil
+}
+
+// Anchor writes an empty group with the name as id, the target of links to #name
+func (c *svgCanvas) Anchor(name string, at Point) error {
+	fmt.Fprintf(&c.body, `<g id="%s"/>`+"\n", svgEscape(name))
+	return nil
+}
+
+// Bookmark does nothing, SVG has no outline
+func (c *svgCanvas) Bookmark(title string, level int, at Point) error {
+	return nil
+}
+
+// Attach writes a transparent rectangle downloading the file as a data URL
+func (c *svgCanvas) Attach(rect Rect, file Attachment) error {
+	mimeType := file.MIMEType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	fmt.Fprintf(&c.body, `<a href="data:%s;base64,%s" download="%s"><rect x="%s" y="%s" width="%s" height="%s" fill="#FFFFFF" fill-opacity="0"/></a>`+"\n",
+		svgEscape(mimeType), base64.StdEncoding.EncodeToString(file.Data), svgEscape(file.Name),
+		svgNumber(rect.X), svgNumber(rect.Y), svgNumber(rect.Width), svgNumber(rect.Height))
+	return nil
+}
+
+// BeginTag opens a group, figures with alt text are labelled images for screen
+// readers and artifacts are hidden from them
+func (c *svgCanvas) BeginTag(role Role, alt string) error {
+	switch {
+	case role == RoleFigure && alt != "":
+		fmt.Fprintf(&c.body, `<g role="img" aria-label="%s">`+"\n", svgEscape(alt))
+	case role == RoleArtifact:
+		c.body.WriteString(`<g aria-hidden="true">` + "\n")
+	default:
+		c.body.WriteString("<g>\n")
+	}
+	c.tags++
+	return nil
+}
+
+func (c *svgCanvas) EndTag() error {
+	if c.tags == 0 {
+		return fmt.Errorf("EndTag without BeginTag")
+	}
+	c.tags--
+	c.body.WriteString("</g>\n")
+	return nil
+}
+
+// embedFonts writes the loaded fonts used by the text as font faces
+func (c *svgCanvas) embedFonts(svg *bytes.Buffer) {
+	var names []string
+	for name := range c.fonts {
+		if loadedFontData(name) != nil {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+
+	svg.WriteString("<defs><style>\n")
+	for _, name := range names {
+		fmt.Fprintf(svg, "@font-face { font-family: %q; src: url(data:font/ttf;base64,%s); }\n",
+			svgEscape(name), base64.StdEncoding.EncodeToString(loadedFontData(name)))
+	}
+	svg.WriteString("</style></defs>\n")
+}
+
+// svgFontFamily returns the font family of a font, with a generic family for viewers
+// which don't have it
+func svgFontFamily(fontType string) string {
+	generic := "sans-serif"
+	switch mapFontName(fontType) {
+	case "Times":
+		generic = "serif"
+	case "Courier":
+		generic = "monospace"
+	}
+	return svgEscape("'"+strings.ReplaceAll(fontType, "'", `\'`)+"'") + ", " + generic
+}
+
+// svgStyle returns the fill and stroke attributes of a style
+func svgStyle(style Style) string {
+	fill := "none"
+	if style.Fill != nil {
+		fill = svgColor(*style.Fill)
+	}
+
+	stroke := ""
+	if style.Stroke != nil && style.StrokeWidth > 0 {
+		stroke = fmt.Sprintf(` stroke="%s" stroke-width="%s"`, svgColor(*style.Stroke), svgNumber(style.StrokeWidth))
+	}
+	if style.translucent() {
+		stroke += fmt.Sprintf(` opacity="%s"`, svgNumber(style.Opacity))
+	}
+	return fmt.Sprintf(` fill="%s"%s`, fill, stroke)
+}
+
+// svgColor returns a color in the #RRGGBB form
+func svgColor(c Color) string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// svgNumber formats a position or a size in points, rounded to a thousandth of a point
+func svgNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*1000)/1000, 'f', -1, 64)
+}
+
+// svgEscape escapes text used in an attribute or an element
+func svgEscape(text string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(text))
+	return escaped.String()
+}
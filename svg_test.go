@@ -0,0 +1,179 @@
+package sahar
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// svgElement is a generic element of an SVG image
+type svgElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr   `xml:",any,attr"`
+	Content  string       `xml:",chardata"`
+	Children []svgElement `xml:",any"`
+}
+
+func (e svgElement) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// renderSVG renders the page and parses the elements of the SVG
+func renderSVG(t *testing.T, page *Node, opts ...RenderOption) (string, svgElement) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := RenderToSVG(&buf, append([]RenderOption{page}, opts...)...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var svg svgElement
+	if err := xml.Unmarshal(buf.Bytes(), &svg); err != nil {
+		t.Fatalf("invalid SVG: %v\n%s", err, buf.String())
+	}
+	return buf.String(), svg
+}
+
+func TestRenderToSVG(t *testing.T) {
+	t.Run("writes boxes as rects", func(t *testing.T) {
+		page := Layout(Box(
+			Sizing(Fixed(200), Fixed(100)),
+			Padding(10, 10, 10, 10),
+			Children(
+				Box(Sizing(Fixed(50), Fixed(20)), BackgroundColor("#ff0000"), Border(1.5), BorderColor("#0000FF")),
+				Box(Sizing(Fixed(50), Fixed(20))),
+			),
+		))
+
+		_, svg := renderSVG(t, page)
+
+		if svg.XMLName.Local != "svg" || svg.attr("width") != "200pt" || svg.attr("viewBox") != "0 0 200 100" {
+			t.Errorf("unexpected root element: %+v", svg.Attrs)
+		}
+		if len(svg.Children) != 2 {
+			t.Fatalf("expected a rect and a path for the visible box, got %d elements", len(svg.Children))
+		}
+
+		rect := svg.Children[0]
+		expected := map[string]string{"x": "10", "y": "10", "width": "50", "height": "20", "fill": "#FF0000"}
+		for name, value := range expected {
+			if rect.attr(name) != value {
+				t.Errorf("expected %s=%q, got %q", name, value, rect.attr(name))
+			}
+		}
+		border := svg.Children[1]
+		expected = map[string]string{"d": "M10 10 L60 10 L60 30 L10 30 Z", "fill": "none", "stroke": "#0000FF", "stroke-width": "1.5"}
+		for name, value := range expected {
+			if border.XMLName.Local != "path" || border.attr(name) != value {
+				t.Errorf("expected the border path with %s=%q, got %s %q", name, value, border.XMLName.Local, border.attr(name))
+			}
+		}
+	})
+
+	t.Run("writes text lines", func(t *testing.T) {
+		centered := Text("Centered", Border(1))
+		centered.Horizontal = Center
+
+		page := Layout(Box(
+			Sizing(Fixed(200), Fixed(100)),
+			Direction(TopToBottom),
+			Alignment(Right, Top),
+			Children(
+				Text("Total <due> & paid", FontType("Courier"), FontSize(10), FontColor("#333333")),
+				centered,
+			),
+		))
+
+		_, svg := renderSVG(t, page)
+
+		var texts []svgElement
+		for _, element := range svg.Children {
+			if element.XMLName.Local == "text" {
+				texts = append(texts, element)
+			}
+		}
+		if len(texts) != 2 {
+			t.Fatalf("expected 2 text elements, got %d", len(texts))
+		}
+
+		total := texts[0]
+		if total.Content != "Total <due> & paid" || total.attr("fill") != "#333333" || total.attr("font-size") != "10" {
+			t.Errorf("unexpected text: %+v", total)
+		}
+		if total.attr("font-family") != "'Courier', monospace" {
+			t.Errorf("unexpected font family %q", total.attr("font-family"))
+		}
+
+		if centered := texts[1]; centered.attr("text-anchor") != "middle" || centered.attr("font-size") != "12" {
+			t.Errorf("expected a centered text with the default size, got %+v", centered.Attrs)
+		}
+	})
+
+	t.Run("embeds images and fonts", func(t *testing.T) {
+		if err := LoadFonts("Arial", "./examples/basic/Arial.ttf"); err != nil {
+			t.Skip("Arial.ttf not found in examples/basic")
+		}
+
+		path := filepath.Join(t.TempDir(), "logo.png")
+		var logo bytes.Buffer
+		if err := png.Encode(&logo, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, logo.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		page := Layout(Box(Sizing(Fixed(100), Fixed(100)), Children(
+			Image(path, Sizing(Fixed(40), Fixed(30))),
+			Text("Logo", FontType("Arial"), FontSize(12)),
+		)))
+
+		output, svg := renderSVG(t, page, EmbedFonts())
+
+		image := svg.Children[1]
+		if image.XMLName.Local != "image" || image.attr("width") != "40" || !strings.HasPrefix(image.attr("href"), "data:image/png;base64,") {
+			t.Errorf("unexpected image: %+v", image.Attrs)
+		}
+		if !strings.Contains(output, `@font-face { font-family: "Arial"; src: url(data:font/ttf;base64,`) {
+			t.Error("expected the font to be embedded")
+		}
+
+		if output, _ := renderSVG(t, page); strings.Contains(output, "@font-face") {
+			t.Error("expected fonts to be referenced by default")
+		}
+	})
+
+	t.Run("writes the debug overlay", func(t *testing.T) {
+		page := Layout(Box(Sizing(Fixed(100), Fixed(60)), Padding(10, 10, 10, 10)))
+
+		output, _ := renderSVG(t, page, DebugOverlay())
+		if !strings.Contains(output, `fill="#1E88E5" opacity="0.2"`) {
+			t.Errorf("expected the padding to be shaded:\n%s", output)
+		}
+		if !strings.Contains(output, ">Box 100.00x60.00</text>") {
+			t.Errorf("expected the label of the box:\n%s", output)
+		}
+	})
+
+	t.Run("reports errors", func(t *testing.T) {
+		page := Layout(Box(Sizing(Fixed(10), Fixed(10))))
+
+		var buf bytes.Buffer
+		if err := RenderToSVG(&buf, page, page); err == nil {
+			t.Error("expected an error for several pages")
+		}
+		if err := RenderToSVG(&buf, Layout(Box(Sizing(Fixed(10), Fixed(10)), BackgroundColor("red")))); err == nil {
+			t.Error("expected an error for an invalid color")
+		}
+	})
+}
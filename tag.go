@@ -0,0 +1,236 @@
+package sahar
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Role is the meaning of a node in the structure tree of a tagged PDF, which screen
+// readers and reflowing viewers read instead of the drawing. Nodes without a role get
+// one from the node tree when the document is tagged: text is a paragraph, images are
+// figures and the children of table rows are cells, while boxes only group their
+// children. The backgrounds and borders of the nodes are always artifacts
+type Role string
+
+const (
+	RoleDocument    Role = "Document"
+	RoleSection     Role = "Sect"
+	RoleDiv         Role = "Div"
+	RoleParagraph   Role = "P"
+	RoleHeading1    Role = "H1"
+	RoleHeading2    Role = "H2"
+	RoleHeading3    Role = "H3"
+	RoleHeading4    Role = "H4"
+	RoleHeading5    Role = "H5"
+	RoleHeading6    Role = "H6"
+	RoleQuote       Role = "BlockQuote"
+	RoleCaption     Role = "Caption"
+	RoleFigure      Role = "Figure" // Images, which need AltText
+	RoleList        Role = "L"
+	RoleListItem    Role = "LI"  // An item of a list, holding a label and a body
+	RoleListLabel   Role = "Lbl" // The bullet or number of a list item
+	RoleListBody    Role = "LBody"
+	RoleTable       Role = "Table" // The rows of a Grid table are made from the placement of its cells
+	RoleTableRow    Role = "TR"
+	RoleTableHeader Role = "TH"
+	RoleTableCell   Role = "TD"
+	RoleTOC         Role = "TOC"
+	RoleTOCItem     Role = "TOCI"
+	RoleArtifact    Role = "Artifact" // Decoration left out of the structure tree, with its children
+)
+
+// roles lists the known roles by name
+var roles = func() map[string]Role {
+	names := make(map[string]Role)
+	for _, role := range []Role{
+		RoleDocument, RoleSection, RoleDiv, RoleParagraph,
+		RoleHeading1, RoleHeading2, RoleHeading3, RoleHeading4, RoleHeading5, RoleHeading6,
+		RoleQuote, RoleCaption, RoleFigure, RoleList, RoleListItem, RoleListLabel, RoleListBody,
+		RoleTable, RoleTableRow, RoleTableHeader, RoleTableCell, RoleTOC, RoleTOCItem, RoleArtifact,
+	} {
+		names[string(role)] = role
+	}
+	return names
+}()
+
+// HeadingRole returns the role of a heading of level 1 to 6, levels out of range are
+// clamped
+func HeadingRole(level int) Role {
+	return Role(fmt.Sprintf("H%d", min(max(level, 1), 6)))
+}
+
+// holdsContent reports whether text without a role inside an element of the role is
+// part of its content instead of a paragraph of its own
+func (r Role) holdsContent() bool {
+	switch r {
+	case RoleParagraph, RoleHeading1, RoleHeading2, RoleHeading3, RoleHeading4, RoleHeading5, RoleHeading6,
+		RoleCaption, RoleListLabel, RoleTableHeader, RoleTableCell:
+		return true
+	}
+	return false
+}
+
+// Tag sets the role of the node in the structure tree of a tagged PDF, see Tagged
+func Tag(role Role) commonOpt {
+	return commonOptFunc(func(n *Node) {
+		n.Role = role
+	})
+}
+
+// AltText sets the text read instead of the node, tagged PDFs require it for images
+// unless they are tagged as RoleArtifact
+func AltText(text string) commonOpt {
+	return commonOptFunc(func(n *Node) {
+		n.Alt = text
+	})
+}
+
+// Tagged makes RenderPDF write a tagged PDF: the pages get a structure tree made of
+// the roles of the nodes, in the order of the node tree, with the alt text of images,
+// and decoration is marked as artifacts. Images without AltText are reported as errors
+// before anything is drawn
+func Tagged() RenderOption {
+	return renderOptFunc(func(c *renderConfig) {
+		c.tagged = true
+	})
+}
+
+// checkTags reports unknown roles and images without alt text of a tagged document
+func checkTags(pages []*Node, config renderConfig) error {
+	if !config.tagged {
+		return nil
+	}
+
+	var errs []error
+	var walk func(node *Node, path string, artifact bool)
+	walk = func(node *Node, path string, artifact bool) {
+		if node == nil {
+			return
+		}
+		if _, ok := roles[string(node.Role)]; node.Role != "" && !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown role %q", path, node.Role))
+		}
+		artifact = artifact || node.Role == RoleArtifact
+		if node.Type == ImageType && !artifact && node.Alt == "" {
+			errs = append(errs, fmt.Errorf("%s: image %q has no alt text, tag it as RoleArtifact when it is decoration", path, node.Value))
+		}
+		for i, child := range node.Children {
+			walk(child, fmt.Sprintf("%s/%s[%d]", path, child.Type, i), artifact)
+		}
+	}
+	for i, page := range pages {
+		walk(page, fmt.Sprintf("page %d: %s", i+1, page.Type), false)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("the document can't be tagged: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// pdfTag is an element of the structure tree of a tagged PDF
+type pdfTag struct {
+	role   Role
+	alt    string
+	parent *pdfTag
+	kids   []pdfTagKid
+}
+
+// pdfTagKid is a child element or a marked-content sequence of a page
+type pdfTagKid struct {
+	tag  *pdfTag
+	page int // The number of the page, starting at 1
+	mcid int
+}
+
+// empty reports whether no content was drawn inside the element
+func (t *pdfTag) empty() bool {
+	for _, kid := range t.kids {
+		if kid.tag == nil || !kid.tag.empty() {
+			return false
+		}
+	}
+	return true
+}
+
+// pdfStructure is the structure tree drawn by a pdfCanvas. Content is drawn inside
+// marked-content sequences numbered on every page, each belonging to an element
+type pdfStructure struct {
+	root  *pdfTag
+	stack []*pdfTag   // The open elements, nil for artifacts
+	open  bool        // Whether a marked-content sequence is open
+	pages [][]*pdfTag // The element of every sequence, by page
+}
+
+// tag adds the structure tree to the document: the catalog gets the tree and the pages
+// their entry in the parent tree, which maps the sequences back to their elements
+func (f *pdfFile) tag(structure *pdfStructure) error {
+	pages := f.pages()
+	if len(structure.pages) > len(pages) {
+		return errors.New("failed to tag the document: unexpected pages")
+	}
+
+	// The objects are numbered before they are added, as the elements refer to their parent
+	var tags []*pdfTag
+	var walk func(t *pdfTag)
+	walk = func(t *pdfTag) {
+		tags = append(tags, t)
+		for _, kid := range t.kids {
+			if kid.tag != nil && !kid.tag.empty() {
+				walk(kid.tag)
+			}
+		}
+	}
+	walk(structure.root)
+
+	tree := len(f.objects)
+	numbers := map[*pdfTag]int{}
+	for i, t := range tags {
+		numbers[t] = tree + 1 + i
+	}
+	parentTree := tree + 1 + len(tags)
+
+	f.add(fmt.Sprintf("<</Type /StructTreeRoot /K [%d 0 R] /ParentTree %d 0 R /ParentTreeNextKey %d>>", numbers[structure.root], parentTree, len(pages)), nil)
+	for _, t := range tags {
+		parent := tree
+		if t.parent != nil {
+			parent = numbers[t.parent]
+		}
+
+		var kids []string
+		for _, kid := range t.kids {
+			switch {
+			case kid.tag == nil:
+				kids = append(kids, fmt.Sprintf("<</Type /MCR /Pg %d 0 R /MCID %d>>", pages[kid.page-1], kid.mcid))
+			case numbers[kid.tag] != 0:
+				kids = append(kids, fmt.Sprintf("%d 0 R", numbers[kid.tag]))
+			}
+		}
+		alt := ""
+		if t.alt != "" {
+			alt = " /Alt " + pdfLiteral(t.alt)
+		}
+		f.add(fmt.Sprintf("<</Type /StructElem /S /%s /P %d 0 R /K [%s]%s>>", t.role, parent, strings.Join(kids, " "), alt), nil)
+	}
+
+	var nums []string
+	for i := range pages {
+		var refs []string
+		if i < len(structure.pages) {
+			for _, t := range structure.pages[i] {
+				refs = append(refs, fmt.Sprintf("%d 0 R", numbers[t]))
+			}
+		}
+		nums = append(nums, fmt.Sprintf("%d [%s]", i, strings.Join(refs, " ")))
+	}
+	f.add(fmt.Sprintf("<</Nums [%s]>>", strings.Join(nums, " ")), nil)
+
+	for i, page := range pages {
+		f.edit(page, "<</Type /Page\n", fmt.Sprintf("<</Type /Page\n/StructParents %d\n/Tabs /S\n", i))
+	}
+	f.catalog(fmt.Sprintf("/StructTreeRoot %d 0 R", tree))
+	f.catalog("/MarkInfo <</Marked true>>")
+	f.catalog("/ViewerPreferences <</DisplayDocTitle true>>")
+	return nil
+}
@@ -0,0 +1,203 @@
+package sahar
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTagged(t *testing.T) {
+	logo := filepath.Join(t.TempDir(), "logo.png")
+	file, err := os.Create(logo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, image.NewNRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	text := func(value string, opts ...textOpt) *Node {
+		return Text(value, append([]textOpt{FontType("Helvetica"), FontSize(10)}, opts...)...)
+	}
+	page := func() *Node {
+		return Layout(Box(
+			Sizing(Fixed(200), Fixed(200)),
+			Direction(TopToBottom),
+			BackgroundColor("#EEEEEE"),
+			Children(
+				text("Invoice", Tag(RoleHeading1)),
+				text("Thanks for your order"),
+				Image(logo, Sizing(Fixed(10), Fixed(10)), AltText("Logo")),
+				Image(logo, Sizing(Fixed(10), Fixed(10)), Tag(RoleArtifact)),
+				Grid(
+					Tag(RoleTable),
+					Columns(FractionTrack(1), FractionTrack(1)),
+					Children(text("Item", Tag(RoleTableHeader)), text("Price", Tag(RoleTableHeader)), text("Tea"), Box(Border(1), Children(text("4")))),
+				),
+			),
+		))
+	}
+
+	t.Run("draws the nodes inside their elements", func(t *testing.T) {
+		canvas := &recordingCanvas{}
+		if err := Render(canvas, page(), Tagged()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var tags []string
+		for _, call := range canvas.calls {
+			if strings.HasPrefix(call, "tag ") || call == "untag" {
+				tags = append(tags, call)
+			}
+		}
+		expected := []string{
+			"tag Artifact", "untag", // The background
+			"tag H1", "untag",
+			"tag P", "untag",
+			"tag Figure Logo", "untag",
+			"tag Artifact", "untag",
+			"tag Table",
+			"tag TR", "tag TH", "untag", "tag TH", "untag", "untag",
+			"tag TR", "tag TD", "untag", "tag TD", "tag Artifact", "untag", "untag", "untag",
+			"untag",
+		}
+		if strings.Join(tags, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("unexpected tags:\n%s", strings.Join(tags, "\n"))
+		}
+	})
+
+	t.Run("writes the structure tree", func(t *testing.T) {
+		render := func() []byte {
+			var buf bytes.Buffer
+			if err := RenderPDF(&buf, page(), Tagged(), Deterministic(), Metadata{Title: "Invoice", Language: "en"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return buf.Bytes()
+		}
+
+		data := render()
+		checkXref(t, data)
+		if !bytes.Equal(data, render()) {
+			t.Error("expected identical output in deterministic mode")
+		}
+
+		output := string(data)
+		for _, expected := range []string{
+			"/MarkInfo <</Marked true>>",
+			"/ViewerPreferences <</DisplayDocTitle true>>",
+			"<</Type /Page\n/StructParents 0\n/Tabs /S\n",
+			"/S /Document",
+			"/S /Figure /P ",
+			"/Alt (Logo)",
+		} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("expected %s in the PDF", expected)
+			}
+		}
+		for role, count := range map[string]int{"H1": 1, "P": 1, "Figure": 1, "Table": 1, "TR": 2, "TH": 2, "TD": 2} {
+			if n := strings.Count(output, "/S /"+role+" "); n != count {
+				t.Errorf("expected %d %s elements, got %d", count, role, n)
+			}
+		}
+
+		// Every marked-content sequence is in the parent tree
+		mcids := regexp.MustCompile(`/MCID \d+>>\]`).FindAllString(output, -1)
+		nums := regexp.MustCompile(`/Nums \[0 \[([^\]]*)\]\]`).FindStringSubmatch(output)
+		if nums == nil || len(strings.Fields(nums[1]))/3 != len(mcids) {
+			t.Errorf("expected %d elements in the parent tree, got %v", len(mcids), nums)
+		}
+	})
+
+	t.Run("is set by PDFOptions", func(t *testing.T) {
+		options := DefaultPDFOptions()
+		options.Tagged = true
+
+		var buf bytes.Buffer
+		root := Layout(Box(Sizing(Fixed(100), Fixed(100)), Children(Text("Total", FontType("Helvetica"), FontSize(10)))))
+		if err := RenderToPDFWithOptions(root, &buf, options); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		checkXref(t, buf.Bytes())
+		if !strings.Contains(buf.String(), "/S /P /P ") || !strings.Contains(buf.String(), "/StructParents 0") {
+			t.Error("expected the structure tree in the PDF")
+		}
+	})
+
+	t.Run("labels figures in SVG", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := RenderToSVG(&buf, page(), Tagged()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(buf.String(), `<g role="img" aria-label="Logo">`) || !strings.Contains(buf.String(), `<g aria-hidden="true">`) {
+			t.Errorf("expected the alt text and the hidden artifacts in:\n%s", buf.String())
+		}
+	})
+
+	t.Run("reports what can't be tagged", func(t *testing.T) {
+		root := Layout(Box(
+			Sizing(Fixed(100), Fixed(100)),
+			Children(Image(logo, Sizing(Fixed(10), Fixed(10))), Box(Tag("Headline"))),
+		))
+
+		err := RenderPDF(&bytes.Buffer{}, root, Tagged())
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		for _, expected := range []string{
+			"the document can't be tagged",
+			"page 1: Box/Image[0]: image " + `"` + logo + `"` + " has no alt text",
+			`page 1: Box/Box[1]: unknown role "Headline"`,
+		} {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("expected %q in the error, got:\n%v", expected, err)
+			}
+		}
+	})
+}
+
+func TestImportedRoles(t *testing.T) {
+	roles := func(t *testing.T, root *Node) string {
+		t.Helper()
+		canvas := &recordingCanvas{}
+		if err := Render(canvas, Layout(root), Tagged()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var tags []string
+		for _, call := range canvas.calls {
+			if tag, ok := strings.CutPrefix(call, "tag "); ok && tag != "Artifact" {
+				tags = append(tags, tag)
+			}
+		}
+		return strings.Join(tags, " ")
+	}
+
+	t.Run("markdown", func(t *testing.T) {
+		root, err := Markdown([]byte("# Title\n\nText\n\n- one\n\n| A | B |\n|---|---|\n| 1 | 2 |\n"), DefaultTheme())
+		if err != nil {
+			t.Fatal(err)
+		}
+		root.Width = Size{Type: FixedType, Value: 400, Min: minNotSet, Max: maxNotSet}
+		expected := "H1 P L LI Lbl LBody P Table TR TH TH TR TD TD"
+		if got := roles(t, root); got != expected {
+			t.Errorf("expected %q, got %q", expected, got)
+		}
+	})
+
+	t.Run("html", func(t *testing.T) {
+		root, _, err := ImportHTML(strings.NewReader(`<h2>Title</h2><p>Text</p><table><tr><th>A</th><td>1</td></tr></table>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		root.Width = Size{Type: FixedType, Value: 400, Min: minNotSet, Max: maxNotSet}
+		expected := "H2 P Table TR TH TD"
+		if got := roles(t, root); got != expected {
+			t.Errorf("expected %q, got %q", expected, got)
+		}
+	})
+}
@@ -0,0 +1,560 @@
+package sahar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// templateMaxDepth limits how deep components can be nested, so a component using
+// itself is reported instead of never ending
+const templateMaxDepth = 32
+
+// templateDirectives are the keys of a template node which control how the node is
+// evaluated, they are not part of the node format
+var templateDirectives = map[string]bool{"repeat": true, "if": true, "data": true, "component": true}
+
+// templateTextKeys always bind to the text of their expressions, so numbers can be
+// written in them as is
+var templateTextKeys = map[string]bool{"text": true, "src": true, "fontType": true, "link": true, "anchor": true, "bookmark": true, "alt": true}
+
+type templateOpt interface {
+	configureTemplate(*Template)
+}
+
+type templateOptFunc func(*Template)
+
+func (f templateOptFunc) configureTemplate(t *Template) {
+	f(t)
+}
+
+// TemplateFuncs adds functions to the expressions of a template, next to the functions
+// of text/template and the number, currency, date and root functions
+func TemplateFuncs(funcs map[string]any) templateOpt {
+	return templateOptFunc(func(t *Template) {
+		for name, fn := range funcs {
+			t.funcs[name] = fn
+		}
+	})
+}
+
+// Template is a document in the JSON or YAML node format whose values bind to data.
+//
+// Any string value can contain text/template expressions such as "{{.Customer.Name}}".
+// A value made of a single expression keeps the type of its result, so sizes, gaps and
+// other numbers can come from the data, while text, src and fontType always use the text
+// of the result. Missing fields and keys are reported as errors.
+//
+// Nodes can use these keys on top of the node format:
+//   - repeat: "{{.Items}}" repeats the node for every element of a list, with the element as data
+//   - if: "{{.Paid}}" keeps the node only when the result is true, it is evaluated for every repetition
+//   - data: "{{.Customer}}" sets the data of the node and its children
+//   - component: "row" uses the node of the same name in the components object of the root,
+//     the other keys of the node replace the keys of the component
+//
+// Expressions can format values with number, currency and date, and access the data given to
+// Execute with root:
+//
+//	{{number 2 .Weight}}           1,234.50
+//	{{.Total | currency "$"}}      $1,234.50
+//	{{date "Jan 2, 2006" .Due}}    Mar 5, 2025
+//	{{root.Currency}}
+type Template struct {
+	root        map[string]any
+	components  map[string]map[string]any
+	funcs       template.FuncMap
+	expressions *template.Template // The expressions of the document, named by their path
+}
+
+// LoadTemplate reads the template at path, using its extension to pick the format
+func LoadTemplate(path string, opts ...templateOpt) (*Template, error) {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	t, err := ParseTemplate(file, format, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// ParseTemplate reads a template in the given format and compiles its expressions.
+// The problems found in the template are returned as DocumentError values joined together
+func ParseTemplate(reader io.Reader, format Format, opts ...templateOpt) (*Template, error) {
+	value, err := readDocument(reader, format)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Template{
+		components: map[string]map[string]any{},
+		funcs: template.FuncMap{
+			"number":   formatNumber,
+			"currency": formatCurrency,
+			"date":     formatDate,
+		},
+	}
+	for _, opt := range opts {
+		opt.configureTemplate(t)
+	}
+
+	// root and _value are bound to the data and the evaluation by Execute
+	t.funcs["root"] = func() any { return nil }
+	t.funcs["_value"] = func(any) string { return "" }
+	t.expressions = template.New("").Option("missingkey=error").Funcs(t.funcs)
+
+	c := templateCompiler{template: t}
+	c.document(value)
+	if len(c.errs) > 0 {
+		return nil, errors.Join(c.errs...)
+	}
+	return t, nil
+}
+
+// Execute evaluates the template with data and returns the node tree ready for Layout.
+// All the problems found on the way are returned as DocumentError values joined together,
+// repeated nodes have the index of the repetition in their path, for example $.children[1][3]
+func (t *Template) Execute(data any) (*Node, error) {
+	expressions, err := t.expressions.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &templateEvaluation{components: t.components, expressions: expressions}
+	expressions.Funcs(template.FuncMap{
+		"root": func() any { return data },
+		"_value": func(value any) string {
+			e.captured = value
+			return ""
+		},
+	})
+
+	root := e.instance(t.root, "$", "$", data)
+	if len(e.errs) > 0 {
+		return nil, errors.Join(e.errs...)
+	}
+	return root, nil
+}
+
+// templateCompiler checks the structure of a template and compiles its expressions
+type templateCompiler struct {
+	documentDecoder
+	template *Template
+}
+
+func (c *templateCompiler) document(value any) {
+	root, ok := c.object(value, "$")
+	if !ok {
+		return
+	}
+
+	// Register every component first, so they can use each other
+	var components map[string]any
+	if value, ok := root["components"]; ok {
+		components, _ = c.object(value, "$.components")
+	}
+	for _, name := range sortedKeys(components) {
+		if component, ok := c.object(components[name], "$.components."+name); ok {
+			c.template.components[name] = component
+		}
+	}
+	for _, name := range sortedKeys(components) {
+		path := "$.components." + name
+		if component, ok := c.template.components[name]; ok {
+			for _, key := range []string{"repeat", "if", "data"} {
+				if _, ok := component[key]; ok {
+					c.errorf(path+"."+key, "components can't use %s at their root, use it where the component is used", key)
+				}
+			}
+			c.node(component, path)
+		}
+	}
+
+	c.template.root = map[string]any{}
+	for key, value := range root {
+		if key != "components" {
+			c.template.root[key] = value
+		}
+	}
+	for _, key := range []string{"repeat", "if"} {
+		if _, ok := root[key]; ok {
+			c.errorf("$."+key, "the root node can't use %s", key)
+		}
+	}
+	c.node(c.template.root, "$")
+}
+
+func (c *templateCompiler) node(object map[string]any, path string) {
+	for _, key := range sortedKeys(object) {
+		value, path := object[key], path+"."+key
+
+		switch key {
+		case "component":
+			if name, ok := c.string(value, path); ok && c.template.components[name] == nil {
+				c.errorf(path, "unknown component %q", name)
+			}
+		case "children":
+			children, ok := value.([]any)
+			if !ok {
+				// The children are evaluated one by one, an expression can't give them
+				c.errorf(path, "expected a list of nodes, got %s", describeValue(value))
+				continue
+			}
+			for i, child := range children {
+				path := fmt.Sprintf("%s[%d]", path, i)
+				if object, ok := c.object(child, path); ok {
+					c.node(object, path)
+				}
+			}
+		default:
+			c.value(value, path)
+		}
+	}
+}
+
+// value compiles the expressions found in the strings of value
+func (c *templateCompiler) value(value any, path string) {
+	switch v := value.(type) {
+	case string:
+		if strings.Contains(v, "{{") {
+			c.expression(v, path)
+		}
+	case []any:
+		for i, item := range v {
+			c.value(item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			c.value(v[key], path+"."+key)
+		}
+	}
+}
+
+func (c *templateCompiler) expression(text, path string) {
+	expression, err := c.template.expressions.New(path).Parse(text)
+	if err != nil {
+		c.errorf(path, "%s", templateErrorMessage(err, path))
+		return
+	}
+
+	// A single expression also gets a typed version, which passes its result to _value
+	if expression.Tree == nil || len(expression.Tree.Root.Nodes) != 1 {
+		return
+	}
+	action, ok := expression.Tree.Root.Nodes[0].(*parse.ActionNode)
+	if !ok || len(action.Pipe.Decl) > 0 {
+		return
+	}
+	typed := "{{" + action.Pipe.String() + " | _value}}"
+	if _, err := c.template.expressions.New(path + " value").Parse(typed); err != nil {
+		c.errorf(path, "%s", templateErrorMessage(err, path+" value"))
+	}
+}
+
+// templateErrorMessage removes the name of the expression, which is its path, from the
+// errors of text/template
+func templateErrorMessage(err error, name string) string {
+	message := err.Error()
+	if i := strings.Index(message, " at <"); i >= 0 {
+		return message[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimPrefix(message, "template: "+name+":"))
+}
+
+// templateField is a value of a node with the path of the template it comes from,
+// used to find its expressions, and the path used to report its errors
+type templateField struct {
+	value any
+	path  string
+	at    string
+}
+
+// templateEvaluation evaluates a template with data and collects every problem found on the way
+type templateEvaluation struct {
+	documentDecoder
+	components  map[string]map[string]any
+	expressions *template.Template
+	captured    any // The result of the last typed expression
+	depth       int // The number of components being evaluated
+}
+
+// node evaluates a node of the template, which gives any number of nodes when it is repeated.
+// path is the path of the node in the template and at the path used for errors
+func (e *templateEvaluation) node(object map[string]any, path, at string, data any) []*Node {
+	value, ok := object["repeat"]
+	if !ok {
+		if node := e.instance(object, path, at, data); node != nil {
+			return []*Node{node}
+		}
+		return nil
+	}
+
+	value, ok = e.value(value, path+".repeat", at+".repeat", data, false)
+	if !ok {
+		return nil
+	}
+	items := reflect.ValueOf(value)
+	if value != nil && items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+		e.errorf(at+".repeat", "expected a list to repeat, got %s", describeValue(value))
+		return nil
+	}
+
+	var nodes []*Node
+	for i := 0; value != nil && i < items.Len(); i++ {
+		if node := e.instance(object, path, fmt.Sprintf("%s[%d]", at, i), items.Index(i).Interface()); node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// instance evaluates a single repetition of a node, it returns nil when the node is hidden
+func (e *templateEvaluation) instance(object map[string]any, path, at string, data any) *Node {
+	if value, ok := object["if"]; ok {
+		condition, ok := e.value(value, path+".if", at+".if", data, false)
+		if truth, _ := template.IsTrue(condition); !ok || !truth {
+			return nil
+		}
+	}
+	if value, ok := object["data"]; ok {
+		if data, ok = e.value(value, path+".data", at+".data", data, false); !ok {
+			return nil
+		}
+	}
+
+	if _, ok := object["component"]; ok {
+		e.depth++
+		defer func() { e.depth-- }()
+	}
+	fields, ok := e.fields(object, path, at, e.depth)
+	if !ok {
+		return nil
+	}
+
+	document := map[string]any{}
+	var children templateField
+	for key, field := range fields {
+		if list, ok := field.value.([]any); ok && key == "children" {
+			// children are evaluated below, the decoder only checks they are allowed
+			children = field
+			children.value = list
+			document[key] = []any{}
+			continue
+		}
+		if value, ok := e.value(field.value, field.path, field.at, data, templateTextKeys[key]); ok {
+			document[key] = value
+		}
+	}
+
+	node := e.documentDecoder.node(document, at)
+	if node == nil || children.value == nil {
+		return node
+	}
+	for i, child := range children.value.([]any) {
+		path, at := fmt.Sprintf("%s[%d]", children.path, i), fmt.Sprintf("%s[%d]", children.at, i)
+		object, ok := e.object(child, at)
+		if !ok {
+			continue
+		}
+		for _, child := range e.node(object, path, at, data) {
+			child.configureNode(node)
+		}
+	}
+	return node
+}
+
+// fields gathers the keys of a node, starting with the keys of its component
+func (e *templateEvaluation) fields(object map[string]any, path, at string, depth int) (map[string]templateField, bool) {
+	fields := map[string]templateField{}
+
+	if name, ok := object["component"].(string); ok {
+		if depth > templateMaxDepth {
+			e.errorf(at+".component", "components are nested deeper than %d levels, does %q use itself?", templateMaxDepth, name)
+			return nil, false
+		}
+
+		componentPath := "$.components." + name
+		component, ok := e.fields(e.components[name], componentPath, componentPath, depth+1)
+		if !ok {
+			return nil, false
+		}
+		fields = component
+	}
+
+	for key, value := range object {
+		if !templateDirectives[key] {
+			fields[key] = templateField{value: value, path: path + "." + key, at: at + "." + key}
+		}
+	}
+	return fields, true
+}
+
+// value evaluates the expressions found in the strings of value. text makes single
+// expressions give their text instead of their typed result
+func (e *templateEvaluation) value(value any, path, at string, data any, text bool) (any, bool) {
+	switch v := value.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, true
+		}
+
+		if typed := e.expressions.Lookup(path + " value"); typed != nil && !text {
+			e.captured = nil
+			if err := typed.Execute(io.Discard, data); err != nil {
+				e.errorf(at, "%s", templateErrorMessage(err, typed.Name()))
+				return nil, false
+			}
+			return templateValue(e.captured), true
+		}
+
+		expression := e.expressions.Lookup(path)
+		if expression == nil {
+			e.errorf(at, "the expression was not compiled with the template")
+			return nil, false
+		}
+		var result strings.Builder
+		if err := expression.Execute(&result, data); err != nil {
+			e.errorf(at, "%s", templateErrorMessage(err, path))
+			return nil, false
+		}
+		return result.String(), true
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			var ok bool
+			if list[i], ok = e.value(item, fmt.Sprintf("%s[%d]", path, i), fmt.Sprintf("%s[%d]", at, i), data, text); !ok {
+				return nil, false
+			}
+		}
+		return list, true
+	case map[string]any:
+		object := make(map[string]any, len(v))
+		for key, item := range v {
+			var ok bool
+			if object[key], ok = e.value(item, path+"."+key, at+"."+key, data, text); !ok {
+				return nil, false
+			}
+		}
+		return object, true
+	default:
+		return value, true
+	}
+}
+
+// templateValue converts the result of an expression to the values of a decoded document
+func templateValue(value any) any {
+	if _, ok := value.(json.Number); ok {
+		return value
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	default:
+		return value
+	}
+}
+
+// templateNumber converts the numbers of the data to float64
+func templateNumber(value any) (float64, error) {
+	if n, ok := value.(json.Number); ok {
+		return n.Float64()
+	}
+
+	switch n := templateValue(value).(type) {
+	case float64:
+		return n, nil
+	default:
+		return 0, fmt.Errorf("expected a number, got %s", describeValue(value))
+	}
+}
+
+// formatNumber formats a number with the given decimals and thousands separators,
+// for example 1,234.50
+func formatNumber(decimals int, value any) (string, error) {
+	n, err := templateNumber(value)
+	if err != nil {
+		return "", err
+	}
+	if decimals < 0 {
+		return "", fmt.Errorf("expected positive decimals, got %d", decimals)
+	}
+
+	digits := strconv.FormatFloat(math.Abs(n), 'f', decimals, 64)
+	integer, fraction, _ := strings.Cut(digits, ".")
+
+	var result strings.Builder
+	if n < 0 && strings.Trim(digits, "0.") != "" {
+		result.WriteByte('-')
+	}
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			result.WriteByte(',')
+		}
+		result.WriteRune(digit)
+	}
+	if fraction != "" {
+		result.WriteString("." + fraction)
+	}
+	return result.String(), nil
+}
+
+// formatCurrency formats an amount with two decimals after the currency symbol,
+// for example $1,234.50 or -$3.00
+func formatCurrency(symbol string, value any) (string, error) {
+	amount, err := formatNumber(2, value)
+	if err != nil {
+		return "", err
+	}
+	if amount, ok := strings.CutPrefix(amount, "-"); ok {
+		return "-" + symbol + amount, nil
+	}
+	return symbol + amount, nil
+}
+
+// formatDate formats a time.Time, or a string in the 2006-01-02 or RFC 3339 format,
+// with a layout of the time package
+func formatDate(layout string, value any) (string, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(layout), nil
+	case *time.Time:
+		if v != nil {
+			return v.Format(layout), nil
+		}
+	case string:
+		for _, format := range []string{time.DateOnly, time.RFC3339} {
+			if date, err := time.Parse(format, v); err == nil {
+				return date.Format(layout), nil
+			}
+		}
+		return "", fmt.Errorf("expected a date such as 2006-01-02 or 2006-01-02T15:04:05Z, got %q", v)
+	}
+	return "", fmt.Errorf("expected a date, got %s", describeValue(value))
+}
@@ -0,0 +1,313 @@
+package sahar
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type templateInvoice struct {
+	Number   int
+	Customer struct{ Name string }
+	Due      time.Time
+	Paid     bool
+	Items    []templateItem
+	Width    float64
+}
+
+type templateItem struct {
+	Name  string
+	Price float64
+}
+
+func templateData() templateInvoice {
+	invoice := templateInvoice{
+		Number: 42,
+		Due:    time.Date(2025, time.March, 5, 0, 0, 0, 0, time.UTC),
+		Items:  []templateItem{{"Pen", 2.5}, {"Book", 1250}},
+		Width:  300,
+	}
+	invoice.Customer.Name = "Ada"
+	return invoice
+}
+
+func executeTemplate(t *testing.T, document string, data any, opts ...templateOpt) *Node {
+	t.Helper()
+
+	tmpl, err := ParseTemplate(strings.NewReader(document), YAMLFormat, opts...)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	root, err := tmpl.Execute(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return root
+}
+
+// templateErrorPaths returns the paths of the DocumentError values joined in err
+func templateErrorPaths(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	var paths []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var documentErr *DocumentError
+		if !errors.As(err, &documentErr) {
+			t.Fatalf("expected a DocumentError, got %v", err)
+		}
+		paths = append(paths, documentErr.Path)
+	}
+	return paths
+}
+
+func TestTemplate(t *testing.T) {
+	t.Run("binds text and attributes", func(t *testing.T) {
+		root := executeTemplate(t, `
+type: box
+width: "{{.Width}}"
+children:
+  - type: text
+    text: "Invoice {{.Number}} for {{.Customer.Name}}"
+  - type: text
+    text: "{{.Number}}"
+`, templateData())
+
+		if root.Width.Type != FixedType || root.Width.Value != 300 {
+			t.Errorf("expected width bound to 300, got %+v", root.Width)
+		}
+		if text := root.Children[0].Value; text != "Invoice 42 for Ada" {
+			t.Errorf("unexpected text %q", text)
+		}
+		if text := root.Children[1].Value; text != "42" {
+			t.Errorf("expected a single number expression to give text, got %q", text)
+		}
+	})
+
+	t.Run("repeats nodes and hides them", func(t *testing.T) {
+		root := executeTemplate(t, `
+type: box
+children:
+  - type: text
+    repeat: "{{.Items}}"
+    if: "{{gt .Price 10.0}}"
+    text: "{{.Name}}"
+  - type: text
+    repeat: "{{.Items}}"
+    text: "{{.Name}} of {{root.Customer.Name}}"
+  - type: text
+    if: "{{.Paid}}"
+    text: Paid
+`, templateData())
+
+		var texts []string
+		for _, child := range root.Children {
+			texts = append(texts, child.Value)
+		}
+		expected := []string{"Book", "Pen of Ada", "Book of Ada"}
+		if !reflect.DeepEqual(texts, expected) {
+			t.Errorf("expected %v, got %v", expected, texts)
+		}
+		for _, child := range root.Children {
+			if child.Parent != root {
+				t.Error("expected repeated nodes to point to their parent")
+			}
+		}
+	})
+
+	t.Run("uses components", func(t *testing.T) {
+		root := executeTemplate(t, `
+components:
+  row:
+    type: box
+    direction: leftToRight
+    childGap: 4
+    children:
+      - type: text
+        text: "{{.Name}}"
+      - type: text
+        text: "{{.Price | currency \"$\"}}"
+  total:
+    component: row
+    backgroundColor: "#EEEEEE"
+type: box
+children:
+  - component: row
+    repeat: "{{.Items}}"
+    childGap: 8
+  - component: total
+    data: "{{index .Items 0}}"
+`, templateData())
+
+		if len(root.Children) != 3 {
+			t.Fatalf("expected 3 rows, got %d", len(root.Children))
+		}
+
+		row := root.Children[1]
+		if row.Direction != LeftToRight || row.ChildGap != 8 {
+			t.Errorf("expected the component with its gap replaced, got %+v", row)
+		}
+		if row.Children[0].Value != "Book" || row.Children[1].Value != "$1,250.00" {
+			t.Errorf("unexpected row: %q %q", row.Children[0].Value, row.Children[1].Value)
+		}
+
+		total := root.Children[2]
+		if total.BackgroundColor != "#EEEEEE" || total.ChildGap != 4 || total.Children[0].Value != "Pen" {
+			t.Errorf("unexpected total: %+v", total)
+		}
+	})
+
+	t.Run("formats values", func(t *testing.T) {
+		root := executeTemplate(t, `
+type: box
+children:
+  - {type: text, text: "{{number 2 1234.5}}"}
+  - {type: text, text: "{{number 0 -1234567}}"}
+  - {type: text, text: "{{currency \"$\" -3}}"}
+  - {type: text, text: "{{date \"Jan 2, 2006\" .Due}}"}
+  - {type: text, text: "{{date \"02/01/2006\" \"2025-12-31\"}}"}
+  - {type: text, text: "{{upper .Customer.Name}}"}
+`, templateData(), TemplateFuncs(map[string]any{"upper": strings.ToUpper}))
+
+		var texts []string
+		for _, child := range root.Children {
+			texts = append(texts, child.Value)
+		}
+		expected := []string{"1,234.50", "-1,234,567", "-$3.00", "Mar 5, 2025", "31/12/2025", "ADA"}
+		if !reflect.DeepEqual(texts, expected) {
+			t.Errorf("expected %v, got %v", expected, texts)
+		}
+	})
+
+	t.Run("works with map data", func(t *testing.T) {
+		root := executeTemplate(t, `{"type": "box", "padding": ["{{.pad}}", 0, 0, 0], "children": [{"type": "text", "repeat": "{{.lines}}", "text": "{{.}}"}]}`,
+			map[string]any{"pad": 6, "lines": []any{"a", "b"}})
+
+		if root.Padding[0] != 6 || len(root.Children) != 2 || root.Children[1].Value != "b" {
+			t.Errorf("unexpected tree: %+v", root)
+		}
+	})
+
+	t.Run("evaluates for every execution", func(t *testing.T) {
+		tmpl, err := ParseTemplate(strings.NewReader(`{"type": "text", "text": "{{.}}"}`), JSONFormat)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, name := range []string{"first", "second"} {
+			root, err := tmpl.Execute(name)
+			if err != nil || root.Value != name {
+				t.Errorf("expected %q, got %v %v", name, root, err)
+			}
+		}
+	})
+}
+
+func TestTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		paths    []string
+	}{
+		{"invalid expression", `{"type": "text", "text": "{{.Name"}`, []string{"$.text"}},
+		{"unknown function", `{"type": "text", "text": "{{money .}}"}`, []string{"$.text"}},
+		{"unknown component", `{"type": "box", "children": [{"component": "row"}]}`, []string{"$.children[0].component"}},
+		{"repeated root", `{"type": "box", "repeat": "{{.Items}}"}`, []string{"$.repeat"}},
+		{"component with data", `{"type": "box", "components": {"row": {"type": "box", "data": "{{.}}"}}}`, []string{"$.components.row.data"}},
+		{"children from an expression", `{"type": "box", "children": "{{.Items}}"}`, []string{"$.children"}},
+		{"children of a component", `{"type": "box", "components": {"row": {"type": "box", "children": {"type": "text"}}}}`, []string{"$.components.row.children"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTemplate(strings.NewReader(tt.document), JSONFormat)
+			if paths := templateErrorPaths(t, err); !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("expected errors at %v, got %v", tt.paths, err)
+			}
+		})
+	}
+
+	executions := []struct {
+		name     string
+		document string
+		paths    []string
+	}{
+		{"missing field", `{"type": "text", "text": "{{.Customer.Nme}}"}`, []string{"$.text"}},
+		{"invalid bound value", `{"type": "box", "children": [{"type": "box", "repeat": "{{.Items}}", "width": "{{.Name}}"}]}`, []string{"$.children[0][0].width", "$.children[0][1].width"}},
+		{"repeat of a value", `{"type": "box", "children": [{"type": "box", "repeat": "{{.Number}}"}]}`, []string{"$.children[0].repeat"}},
+		{"formatter error", `{"type": "text", "text": "{{currency \"$\" .Customer.Name}}"}`, []string{"$.text"}},
+		{"recursive component", `{"type": "box", "components": {"a": {"type": "box", "children": [{"component": "a"}]}}, "children": [{"component": "a"}]}`, nil},
+	}
+
+	for _, tt := range executions {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(strings.NewReader(tt.document), JSONFormat)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+
+			_, err = tmpl.Execute(templateData())
+			paths := templateErrorPaths(t, err)
+			if tt.paths != nil && !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("expected errors at %v, got %v", tt.paths, err)
+			}
+		})
+	}
+
+	t.Run("reports the cause", func(t *testing.T) {
+		tmpl, _ := ParseTemplate(strings.NewReader(`{"type": "text", "text": "{{.Customer.Nme}}"}`), JSONFormat)
+		_, err := tmpl.Execute(templateData())
+		if err == nil || !strings.Contains(err.Error(), "$.text: at <.Customer.Nme>") {
+			t.Errorf("expected the path and the expression, got %v", err)
+		}
+	})
+}
+
+func TestLoadTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invoice.yaml")
+	document := `
+type: box
+width: 595.28
+height: 841.89
+padding: 40
+direction: topToBottom
+children:
+  - type: text
+    text: "Invoice for {{.Customer.Name}}"
+    fontSize: 18
+  - type: box
+    repeat: "{{.Items}}"
+    width: grow
+    children:
+      - {type: text, text: "{{.Name}}", width: grow}
+      - {type: text, text: "{{currency \"$\" .Price}}"}
+`
+	if err := os.WriteFile(path, []byte(document), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := LoadTemplate(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	root, err := tmpl.Execute(templateData())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := RenderToPDF(&buf, Layout(root)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if row := root.Children[2]; row.Width.Value != 595.28-80 {
+		t.Errorf("expected repeated rows to grow, got %f", row.Width.Value)
+	}
+}
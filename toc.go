@@ -0,0 +1,178 @@
+package sahar
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// tocIndent is the indent of the entries of DefaultTOCEntry per bookmark level
+const tocIndent = 12
+
+// TOCEntry is a bookmark listed by a table of contents
+type TOCEntry struct {
+	Title  string
+	Level  int
+	Page   int    // The number of the page of the bookmark, starting at 1
+	Anchor string // The anchor of the bookmarked node, entries can link to it
+}
+
+// TableOfContents returns a box listing the bookmarks of the pages rendered with it.
+// The renderers fill a copy of it with a node per bookmark built by entry,
+// DefaultTOCEntry when entry is nil, and lay out the copy of its page again, entries
+// which don't fit the page are reported as an error. Bookmarked nodes without an
+// anchor are given one in the copy, so the entries can link to them. The box grows to
+// the width of its parent and lists the entries from top to bottom, opts can change it
+// like any box
+func TableOfContents(entry func(TOCEntry) *Node, opts ...nodeOpt) *Node {
+	if entry == nil {
+		entry = DefaultTOCEntry
+	}
+
+	n := Box(append([]nodeOpt{Direction(TopToBottom), Sizing(Grow(), Fit()), Tag(RoleTOC)}, opts...)...)
+	n.contents = entry
+	return n
+}
+
+// DefaultTOCEntry is a row with the title indented by its level on the left and the
+// page number on the right, linking to the bookmarked node
+func DefaultTOCEntry(entry TOCEntry) *Node {
+	return Box(
+		Sizing(Grow(), Fit()),
+		Padding(0, 0, 0, float64(entry.Level)*tocIndent),
+		ChildGap(defaultFont.Size),
+		Link("#"+entry.Anchor),
+		Tag(RoleTOCItem),
+		Children(
+			Text(entry.Title, FontType(defaultFont.Type), FontSize(defaultFont.Size)),
+			Box(Sizing(Grow(), Fit())),
+			Text(strconv.Itoa(entry.Page), FontType(defaultFont.Type), FontSize(defaultFont.Size), Shrink(0)),
+		),
+	)
+}
+
+// fillContents returns the pages with their tables of contents filled with the
+// bookmarks of all the pages, and lays out the pages holding one again. The nodes
+// passed are left as they are: the tables are filled on copies of the pages, where the
+// bookmarked nodes without an anchor are given one unused by the document. The pages
+// are already split, so the entries don't move the bookmarks to other pages and a
+// single layout is enough, entries which don't fit their page are reported
+func fillContents(pages []*Node) ([]*Node, error) {
+	if !slices.ContainsFunc(pages, holdsContents) {
+		return pages, nil
+	}
+
+	copies := make([]*Node, len(pages))
+	for i, page := range pages {
+		copies[i] = cloneNode(page, page.Parent)
+	}
+	pages = copies
+
+	type table struct {
+		node     *Node
+		page     int
+		overflow float64 // The overflow around the table before it is filled
+	}
+	var tables []table
+	var bookmarks []*Node
+	var entries []TOCEntry
+	anchors := map[string]bool{}
+
+	var walk func(node *Node, page int)
+	walk = func(node *Node, page int) {
+		if node == nil {
+			return
+		}
+		if node.contents != nil {
+			tables = append(tables, table{node: node, page: page, overflow: contentsOverflow(node)})
+			return
+		}
+		if node.Anchor != "" {
+			anchors[node.Anchor] = true
+		}
+		if node.Bookmark != "" {
+			bookmarks = append(bookmarks, node)
+			entries = append(entries, TOCEntry{Title: node.Bookmark, Level: node.BookmarkLevel, Page: page + 1})
+		}
+		for _, child := range node.Children {
+			walk(child, page)
+		}
+	}
+	for i, page := range pages {
+		walk(page, i)
+	}
+
+	for i, node := range bookmarks {
+		if node.Anchor == "" {
+			name := fmt.Sprintf("toc-%d", i+1)
+			for n := 2; anchors[name]; n++ {
+				name = fmt.Sprintf("toc-%d-%d", i+1, n)
+			}
+			anchors[name] = true
+			node.Anchor = name
+		}
+		entries[i].Anchor = node.Anchor
+	}
+
+	relayout := make([]bool, len(pages))
+	for _, table := range tables {
+		table.node.Children = nil
+		for _, entry := range entries {
+			table.node.contents(entry).configureNode(table.node)
+		}
+		relayout[table.page] = true
+	}
+
+	for i, page := range pages {
+		if relayout[i] {
+			Layout(page)
+		}
+	}
+	for _, table := range tables {
+		if overflow := contentsOverflow(table.node) - table.overflow; overflow > layoutEpsilon {
+			return nil, fmt.Errorf("the table of contents on page %d overflows its page by %.2fpt, give it more room or a page of its own", table.page+1, overflow)
+		}
+	}
+	return pages, nil
+}
+
+// holdsContents reports whether there is a table of contents in the node tree
+func holdsContents(node *Node) bool {
+	return node != nil && (node.contents != nil || slices.ContainsFunc(node.Children, holdsContents))
+}
+
+// contentsOverflow returns the largest height by which the children of a table of
+// contents, or of the nodes holding it, overflow their content area
+func contentsOverflow(table *Node) float64 {
+	var overflow float64
+	for node := table; node != nil; node = node.Parent {
+		_, height := node.Overflow()
+		overflow = max(overflow, height)
+	}
+	return overflow
+}
+
+// cloneNode returns a copy of the node tree, with parent as the parent of the copy
+func cloneNode(node, parent *Node) *Node {
+	if node == nil {
+		return nil
+	}
+
+	clone := new(Node)
+	*clone = *node
+	clone.Parent = parent
+	clone.Children = slices.Clone(node.Children)
+	copies := make(map[*Node]*Node, len(node.Children))
+	for i, child := range node.Children {
+		clone.Children[i] = cloneNode(child, clone)
+		copies[child] = clone.Children[i]
+	}
+
+	clone.gridCells = slices.Clone(node.gridCells)
+	for i := range clone.gridCells {
+		clone.gridCells[i].child = copies[clone.gridCells[i].child]
+	}
+	clone.gridColumns = slices.Clone(node.gridColumns)
+	clone.gridRows = slices.Clone(node.gridRows)
+	return clone
+}
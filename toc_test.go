@@ -0,0 +1,168 @@
+package sahar
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// tocDocument returns pages with a table of contents on the first one
+func tocDocument(table *Node) []*Node {
+	return []*Node{
+		Layout(Box(Sizing(Fixed(300), Fixed(200)), Padding(20, 20, 20, 20), Children(table))),
+		Layout(Box(
+			Sizing(Fixed(300), Fixed(200)),
+			Direction(TopToBottom),
+			Children(
+				Text("Introduction", FontType("Arial"), FontSize(12), Bookmark("Introduction", 0)),
+				Text("Details", FontType("Arial"), FontSize(12), Anchor("details"), Bookmark("Details", 1)),
+			),
+		)),
+		Layout(Box(Sizing(Fixed(300), Fixed(200)), Children(Text("Appendix", FontType("Arial"), FontSize(12), Bookmark("Appendix", 0))))),
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	t.Run("lists the bookmarks with their page", func(t *testing.T) {
+		pages := tocDocument(TableOfContents(nil))
+
+		filled, err := fillContents(pages)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		table := filled[0].Children[0]
+
+		expected := []struct {
+			title, page, anchor string
+			indent              float64
+		}{
+			{"Introduction", "2", "toc-1", 0},
+			{"Details", "2", "details", tocIndent},
+			{"Appendix", "3", "toc-3", 0},
+		}
+		if len(table.Children) != len(expected) {
+			t.Fatalf("expected %d entries, got %d", len(expected), len(table.Children))
+		}
+
+		for i, e := range expected {
+			entry := table.Children[i]
+			title, page := entry.Children[0], entry.Children[2]
+			if title.Value != e.title || page.Value != e.page || entry.Link != "#"+e.anchor {
+				t.Errorf("entry %d: expected %s on page %s linking to %s, got %q %q %q", i, e.title, e.page, e.anchor, title.Value, page.Value, entry.Link)
+			}
+			if title.Position.X != 20+e.indent {
+				t.Errorf("entry %d: expected the title at %g, got %g", i, 20+e.indent, title.Position.X)
+			}
+			// The page was laid out again, the page numbers are on the right of the table
+			if right := page.Position.X + page.Width.Value; right != 280 {
+				t.Errorf("entry %d: expected the page number to end at 280, got %g", i, right)
+			}
+		}
+
+		if filled[1].Children[0].Anchor != "toc-1" || filled[2].Children[0].Anchor != "toc-3" {
+			t.Error("expected the bookmarks without anchor to be given one")
+		}
+
+		canvas := &recordingCanvas{}
+		if err := Render(canvas, pages[0], pages[1], pages[2]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(strings.Join(canvas.calls, "\n"), "anchor toc-3 0,0") {
+			t.Error("expected the anchors to be drawn")
+		}
+	})
+
+	t.Run("leaves the nodes passed as they are", func(t *testing.T) {
+		table := TableOfContents(nil)
+		pages := tocDocument(table)
+
+		for range 2 {
+			if err := Render(&recordingCanvas{}, pages[0], pages[1], pages[2]); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if len(table.Children) != 0 || table.Height.Value != 0 {
+			t.Errorf("expected the table to stay empty, got %d entries", len(table.Children))
+		}
+		if pages[1].Children[0].Anchor != "" || pages[2].Children[0].Anchor != "" {
+			t.Error("expected the bookmarks to keep no anchor")
+		}
+	})
+
+	t.Run("gives anchors unused by the document", func(t *testing.T) {
+		pages := []*Node{
+			Layout(Box(Sizing(Fixed(300), Fixed(200)), Children(TableOfContents(nil)))),
+			Layout(Box(
+				Sizing(Fixed(300), Fixed(200)),
+				Direction(TopToBottom),
+				Children(
+					Text("Introduction", Bookmark("Introduction", 0)),
+					Text("Summary", Anchor("toc-1")),
+				),
+			)),
+		}
+
+		filled, err := fillContents(pages)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if anchor := filled[1].Children[0].Anchor; anchor != "toc-1-2" {
+			t.Errorf("expected an anchor unused by the document, got %q", anchor)
+		}
+		if err := checkLinks(filled); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("reports entries overflowing their page", func(t *testing.T) {
+		chapters := Box(Sizing(Fixed(300), Fixed(200)), Direction(TopToBottom))
+		for i := range 20 {
+			Text(fmt.Sprintf("Chapter %d", i+1), FontSize(12), Bookmark(fmt.Sprintf("Chapter %d", i+1), 0)).configureNode(chapters)
+		}
+		pages := []*Node{Layout(Box(Sizing(Fixed(300), Fixed(100)), Children(TableOfContents(nil)))), Layout(chapters)}
+
+		_, err := fillContents(pages)
+		if err == nil || !strings.Contains(err.Error(), "the table of contents on page 1 overflows its page by") {
+			t.Errorf("expected the overflow to be reported, got %v", err)
+		}
+		if err := RenderToPDF(&bytes.Buffer{}, pages...); err == nil {
+			t.Error("expected RenderToPDF to report the overflow")
+		}
+	})
+
+	t.Run("builds custom entries", func(t *testing.T) {
+		var entries []TOCEntry
+		table := TableOfContents(func(entry TOCEntry) *Node {
+			entries = append(entries, entry)
+			return Text(entry.Title, FontType("Arial"), FontSize(10))
+		}, ChildGap(2))
+		pages := tocDocument(table)
+
+		filled, err := fillContents(pages)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(entries) != 3 || entries[1] != (TOCEntry{Title: "Details", Level: 1, Page: 2, Anchor: "details"}) {
+			t.Errorf("unexpected entries: %+v", entries)
+		}
+		table = filled[0].Children[0]
+		if second := table.Children[1]; second.Position.Y != table.Position.Y+table.Children[0].Height.Value+2 {
+			t.Errorf("expected the options of the table to apply, got the second entry at %g", second.Position.Y)
+		}
+	})
+
+	t.Run("links the PDF entries", func(t *testing.T) {
+		pages := tocDocument(TableOfContents(nil))
+
+		var buf bytes.Buffer
+		if err := RenderToPDF(&buf, pages[0], pages[1], pages[2]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Annotations end with the destination, unlike the entries of the outline
+		if count := strings.Count(buf.String(), "null]>>"); count != 3 {
+			t.Errorf("expected 3 internal links, got %d", count)
+		}
+	})
+}